@@ -11,13 +11,38 @@ token:
   key: "${AUTH_TOKEN_KEY}"
 
 acl:
+  - match:
+      origin: cr
+      catalog: "true"
+      type: registry
+      name: catalog
+    actions:
+    - "*"
+    comment: ImageReplication and catalog inventory accounts can list the catalog
+  - match:
+      origin: cr
+      accessMode: push
+      repositoryPrefix: "/.+/"
+      name: "${labels:repositoryPrefix}/*"
+    actions:
+    - pull
+    - push
+    - delete
+    comment: ImagePushSecret users with a repository prefix (e.g. build caches) can push/pull/delete below the prefix
+  - match:
+      origin: cr
+      accessMode: push
+      repositoryPrefix: "/.+/"
+    actions:
+    - pull
+    comment: ImagePushSecret users with a repository prefix can pull other repositories
   - match:
       origin: cr
       accessMode: push
     actions:
     - pull
     - push
-    comment: ImagePushSecret users can push/pull
+    comment: ImagePushSecret users can push/pull (writes are rejected by nginx in maintenance mode)
   - match:
       origin: cr
     actions:
@@ -57,7 +57,7 @@ func authenticator(kubeconfig, namespace string) *auth.Authenticator {
 	}
 	cfg.UserAgent = "Image Registry authn CLI"
 	errLogger := func(err error) { log.Println(err) }
-	a, err := auth.NewAuthenticator(cfg, namespace, errLogger)
+	a, err := auth.NewAuthenticator(cfg, namespace, "", errLogger)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -10,9 +10,11 @@ import (
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	//_ "k8s.io/client-go/plugin/pkg/client/auth"
 
-	certmgr "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha3"
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/mgoltzsche/image-registry-operator/pkg/apis"
 	"github.com/mgoltzsche/image-registry-operator/pkg/controller"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
+	"github.com/mgoltzsche/image-registry-operator/pkg/notifications"
 	"github.com/mgoltzsche/image-registry-operator/version"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
@@ -106,17 +108,33 @@ func main() {
 		log.Error(err, "")
 		os.Exit(1)
 	}
-	if err := certmgr.AddToScheme(mgr.GetScheme()); err != nil {
+	if err := monitoringv1.AddToScheme(mgr.GetScheme()); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	events := notifications.NewDispatcher()
+	if err := controller.AddToManager(mgr, events); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	// Setup the registry notification receiver
+	if addr := os.Getenv(notifications.EnvReceiverAddr); addr != "" {
+		recorder := mgr.GetEventRecorderFor("image-registry-operator")
+		token := os.Getenv(imageregistry.EnvNotificationsToken)
+		if token == "" {
+			log.Error(fmt.Errorf("%s not specified", imageregistry.EnvNotificationsToken), "cannot start notification receiver")
+			os.Exit(1)
+		}
+		receiver := notifications.NewReceiver(mgr.GetClient(), recorder, events.Notify, token, logf.Log.WithName("notifications"))
+		if err := mgr.Add(notifications.NewServer(addr, receiver)); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	if err = serveCRMetrics(cfg); err != nil {
 		log.Info("Could not generate and serve custom resource metrics", "error", err.Error())
 	}
@@ -2,17 +2,24 @@ package main
 
 import (
 	"io/ioutil"
+	"net/http"
 	"os"
 
 	"github.com/cesanta/docker_auth/auth_server/api"
 
 	"github.com/cesanta/glog"
 	"github.com/mgoltzsche/image-registry-operator/pkg/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-const pluginName = "k8s-authn"
+const (
+	pluginName        = "k8s-authn"
+	envMetricsAddress = "AUTH_METRICS_ADDR"
+	envReadOnlyFile   = "AUTH_READ_ONLY_FILE"
+)
 
 var (
 	// Export cesanta/docker_auth plugin
@@ -72,10 +79,20 @@ func newK8sDockerAuthnPlugin() k8sDockerAuthnPlugin {
 	}
 
 	cfg.UserAgent = "Image Registry Auth"
-	a, err := auth.NewAuthenticator(cfg, namespace, errLogger)
+	a, err := auth.NewAuthenticator(cfg, namespace, os.Getenv(envReadOnlyFile), errLogger)
 	if err != nil {
 		glog.Error(err)
 		os.Exit(4)
 	}
+
+	// Serve metrics
+	if addr := os.Getenv(envMetricsAddress); addr != "" {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(auth.Authentications)
+		go func() {
+			glog.Infof("serving metrics on %s", addr)
+			glog.Error(http.ListenAndServe(addr, promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+		}()
+	}
 	return k8sDockerAuthnPlugin{a}
 }
@@ -14,6 +14,7 @@ STATUS=0
 	kustomize cfg set $KUSTOMIZATION_DIR registry-manager-image mgoltzsche/image-registry-operator:${VERSION} &&
 	kustomize cfg set $KUSTOMIZATION_DIR registry-auth-image mgoltzsche/image-registry-operator:${VERSION}-auth &&
 	kustomize cfg set $KUSTOMIZATION_DIR registry-nginx-image mgoltzsche/image-registry-operator:${VERSION}-nginx &&
+	kustomize cfg set $KUSTOMIZATION_DIR registry-backup-image mgoltzsche/image-registry-operator:${VERSION}-backup &&
 	git add $KUSTOMIZATION_DIR &&
 	git commit -m"$RELEASE_NAME" &&
 	git tag -a "v$VERSION" -m"$RELEASE_NAME" &&
@@ -0,0 +1,22 @@
+package v1alpha1
+
+// Reasons of the Kubernetes Events emitted by the operator
+const (
+	EventReasonPasswordRotated    = "PasswordRotated"
+	EventReasonRotationFailed     = "PasswordRotationFailed"
+	EventReasonAccountDeleted     = "AccountDeleted"
+	EventReasonCertificateRenewed = "CertificateRenewed"
+	EventReasonCARotationStarted  = "CARotationStarted"
+	EventReasonCARotationFinished = "CARotationFinished"
+	EventReasonCASignerSwitched   = "CASignerSwitched"
+	EventReasonSecretAdopted      = "SecretAdopted"
+	EventReasonRegistryReady      = "RegistryReady"
+	EventReasonRegistryNotReady   = "RegistryNotReady"
+	EventReasonMissingSecret      = "MissingSecret"
+	EventReasonSecretNotAllowed   = "SecretNotAllowed"
+	EventReasonRedisPending       = "RedisPending"
+	EventReasonRedisReady         = "RedisReady"
+	EventReasonAuthConflict       = "AuthConflict"
+	EventReasonCachePurged        = "CachePurged"
+	EventReasonCachePurgeFailed   = "CachePurgeFailed"
+)
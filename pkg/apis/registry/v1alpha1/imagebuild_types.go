@@ -0,0 +1,105 @@
+package v1alpha1
+
+import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	BuildPhasePending   = BuildPhase("Pending")
+	BuildPhaseRunning   = BuildPhase("Running")
+	BuildPhaseSucceeded = BuildPhase("Succeeded")
+	BuildPhaseFailed    = BuildPhase("Failed")
+	// ReasonBuildEnvNotReady indicates that the referenced ImageBuildEnv does not exist or is not ready
+	ReasonBuildEnvNotReady = status.ConditionReason("BuildEnvNotReady")
+	ReasonInvalidSpec      = status.ConditionReason("InvalidSpec")
+)
+
+type BuildPhase string
+
+// ImageBuildSpec defines the desired state of ImageBuild.
+// The spec is read when the build Job is created: changes to a started ImageBuild are ignored.
+type ImageBuildSpec struct {
+	// BuildEnvRef refers to the ImageBuildEnv within the ImageBuild's namespace that provides the registry configuration
+	BuildEnvRef corev1.LocalObjectReference `json:"buildEnvRef"`
+	// Builder selects the build tool. It must be enabled within the ImageBuildEnv (default: the ImageBuildEnv's first builder)
+	Builder ImageBuilder     `json:"builder,omitempty"`
+	Context BuildContextSpec `json:"context"`
+	// Dockerfile is the Dockerfile's path relative to the context directory (default: Dockerfile)
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Image is the name of the image that is pushed (e.g. registry.example.org/myorg/myimage)
+	Image string `json:"image"`
+	// Tag is the pushed image's tag (default: latest)
+	Tag string `json:"tag,omitempty"`
+	// BuilderImage overrides the build Job's image (not supported for privileged builders)
+	BuilderImage string                      `json:"builderImage,omitempty"`
+	Resources    corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// BuildContextSpec specifies the build context: either a git repository, a ConfigMap or a PersistentVolumeClaim
+type BuildContextSpec struct {
+	Git *GitContextSpec `json:"git,omitempty"`
+	// ConfigMap refers to a ConfigMap within the ImageBuild's namespace whose keys are the context's files
+	ConfigMap             *corev1.LocalObjectReference `json:"configMap,omitempty"`
+	PersistentVolumeClaim *PVCContextSpec              `json:"persistentVolumeClaim,omitempty"`
+}
+
+// GitContextSpec specifies a git repository
+type GitContextSpec struct {
+	URL string `json:"url"`
+	// Ref is the branch, tag or commit that is checked out (default: the repository's default branch)
+	Ref string `json:"ref,omitempty"`
+	// Path is the context directory within the repository
+	Path string `json:"path,omitempty"`
+}
+
+// PVCContextSpec refers to a PersistentVolumeClaim within the ImageBuild's namespace
+type PVCContextSpec struct {
+	ClaimName string `json:"claimName"`
+	// Path is the context directory within the volume
+	Path string `json:"path,omitempty"`
+}
+
+// ImageBuildStatus defines the observed state of ImageBuild
+type ImageBuildStatus struct {
+	Conditions status.Conditions `json:"conditions,omitempty"`
+	Phase      BuildPhase        `json:"phase,omitempty"`
+	// ObservedGeneration is the spec's generation the build Job has been created for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Builder is the build tool the Job runs
+	Builder ImageBuilder `json:"builder,omitempty"`
+	JobName string       `json:"jobName,omitempty"`
+	// Image is the pushed image's name and tag
+	Image string `json:"image,omitempty"`
+	// ImageDigest is the pushed image's manifest digest
+	ImageDigest    string       `json:"imageDigest,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImageBuild is the Schema for the imagebuilds API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=imagebuilds,scope=Namespaced
+type ImageBuild struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageBuildSpec   `json:"spec,omitempty"`
+	Status ImageBuildStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImageBuildList contains a list of ImageBuild
+type ImageBuildList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImageBuild `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImageBuild{}, &ImageBuildList{})
+}
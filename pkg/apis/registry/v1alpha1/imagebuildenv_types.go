@@ -2,17 +2,43 @@ package v1alpha1
 
 import (
 	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
-	ReasonMissingSecret    = status.ConditionReason("MissingSecret")
-	ReasonInvalidSecret    = status.ConditionReason("InvalidSecret")
-	ReasonFailedUpdate     = status.ConditionReason("FailedUpdate")
-	ReasonPending          = status.ConditionReason("Pending")
-	SecretKeyMakisuYAML    = "makisu.yaml"
-	SecretKeyRedis         = "redis"
-	SecretKeyRedisPassword = "redis_password"
+	ReasonMissingSecret = status.ConditionReason("MissingSecret")
+	ReasonInvalidSecret = status.ConditionReason("InvalidSecret")
+	ReasonFailedUpdate  = status.ConditionReason("FailedUpdate")
+	ReasonPending       = status.ConditionReason("Pending")
+	// ReasonSecretNotAllowed indicates that an input Secret in another namespace does not allow the ImageBuildEnv's namespace to use it
+	ReasonSecretNotAllowed = status.ConditionReason("SecretNotAllowed")
+	// AnnotationAllowedNamespaces lists the namespaces (comma-separated, "*" for all) whose ImageBuildEnvs may use a Secret as input
+	AnnotationAllowedNamespaces = "registry.mgoltzsche.github.com/allowed-namespaces"
+	// ConditionAuthConflict is True when multiple input Secrets specify different credentials for the same registry
+	ConditionAuthConflict    = status.ConditionType("AuthConflict")
+	ReasonConflictingSecrets = status.ConditionReason("ConflictingSecrets")
+	SecretKeyMakisuYAML      = "makisu.yaml"
+	SecretKeyRedis           = "redis"
+	SecretKeyRedisPassword   = "redis_password"
+	SecretKeyKanikoArgs      = "kaniko.args"
+	SecretKeyAuthJSON        = "auth.json"
+	SecretKeyRegistriesConf  = "registries.conf"
+	SecretKeyCertsDir        = "certs.d.json"
+	SecretKeyBuildKitdTOML   = "buildkitd.toml"
+	SecretKeyCacheRepo       = "cache.repository"
+	SecretKeyBuildKitArgs    = "buildkit.args"
+)
+
+// ImageBuilder specifies an image build tool the build environment provides configuration for
+// +kubebuilder:validation:Enum=Makisu;Kaniko;Buildah;BuildKit
+type ImageBuilder string
+
+const (
+	ImageBuilderMakisu   = ImageBuilder("Makisu")
+	ImageBuilderKaniko   = ImageBuilder("Kaniko")
+	ImageBuilderBuildah  = ImageBuilder("Buildah")
+	ImageBuilderBuildKit = ImageBuilder("BuildKit")
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -20,12 +46,80 @@ const (
 
 // ImageBuildEnvSpec defines the desired state of ImageBuildEnv
 type ImageBuildEnvSpec struct {
-	Redis   bool             `json:"redis,omitempty"`
-	Secrets []ImageSecretRef `json:"secrets,omitempty"`
+	// Redis enables a redis cache the build environment's Secret refers to
+	Redis bool `json:"redis,omitempty"`
+	// RedisConfig configures the redis cache when enabled
+	RedisConfig *RedisSpec       `json:"redisConfig,omitempty"`
+	Secrets     []ImageSecretRef `json:"secrets,omitempty"`
+	// Cache configures a dedicated registry location the builders push their layer cache to
+	Cache *ImageBuildCacheSpec `json:"cache,omitempty"`
+	// Builders lists the build tools the merged Secret provides configuration for in addition to the docker config (default: Makisu)
+	Builders []ImageBuilder `json:"builders,omitempty"`
+	// AllowPrivilegedBuilds allows ImageBuilds to run builders that require a privileged container (Buildah).
+	// Privileged builds always run the operator's builder image.
+	AllowPrivilegedBuilds bool `json:"allowPrivilegedBuilds,omitempty"`
+}
+
+// HasBuilder returns true if the build environment provides configuration for the given builder
+func (s *ImageBuildEnvSpec) HasBuilder(b ImageBuilder) bool {
+	if len(s.Builders) == 0 {
+		return b == ImageBuilderMakisu
+	}
+	for _, builder := range s.Builders {
+		if builder == b {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageBuildCacheSpec specifies where the build cache is stored
+type ImageBuildCacheSpec struct {
+	// RegistryRef refers to the ImageRegistry the cache is pushed to.
+	// If omitted the operator provisions a dedicated ImageRegistry named imagebuildenv-<NAME>-cache within the ImageBuildEnv's namespace.
+	RegistryRef *ImageRegistryRef `json:"registryRef,omitempty"`
+	// PersistentVolumeClaim specifies the provisioned ImageRegistry's storage (ignored when registryRef is set)
+	PersistentVolumeClaim *PersistentVolumeClaimSpec `json:"persistentVolumeClaim,omitempty"`
+	// RepositoryPrefix is the repository prefix below the ImageBuildEnv's namespace the cache is pushed to (default: <NAMESPACE>/<NAME>/cache)
+	RepositoryPrefix string `json:"repositoryPrefix,omitempty"`
+	// MaxAge specifies how long cache entries are kept: entries older than maxAge are deleted periodically (default: 168h)
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// ImageBuildCacheStatus describes the build cache location
+type ImageBuildCacheStatus struct {
+	// Registry is the cache registry's hostname
+	Registry string `json:"registry,omitempty"`
+	// RepositoryPrefix is the prefix of the cache repositories within the registry
+	RepositoryPrefix string `json:"repositoryPrefix,omitempty"`
+	// PurgeTime is the time the cache has been purged last
+	PurgeTime *metav1.Time `json:"purgeTime,omitempty"`
+	// FirstSeen records when the operator first saw cache manifests that don't specify their creation time (key: <REPO>@<DIGEST>)
+	FirstSeen map[string]metav1.Time `json:"firstSeen,omitempty"`
+}
+
+// RedisSpec specifies the redis Deployment
+type RedisSpec struct {
+	// Image overrides the operator's default redis image (OPERATOR_IMAGE_REDIS)
+	Image     string                      `json:"image,omitempty"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// PersistentVolumeClaim enables persistence. The cache is kept in memory only if omitted.
+	PersistentVolumeClaim *RedisPersistentVolumeClaimSpec `json:"persistentVolumeClaim,omitempty"`
+}
+
+// RedisPersistentVolumeClaimSpec specifies the PersistentVolumeClaim redis stores its data in.
+// The claim is deleted when redis or its persistence is disabled.
+type RedisPersistentVolumeClaimSpec struct {
+	StorageClassName *string                             `json:"storageClassName,omitempty"`
+	AccessModes      []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	Resources        corev1.ResourceRequirements         `json:"resources,omitempty"`
 }
 
 type ImageSecretRef struct {
 	SecretName string `json:"secretName"`
+	// Namespace is the Secret's namespace (default: the ImageBuildEnv's namespace).
+	// A Secret in another namespace must allow the ImageBuildEnv's namespace within its registry.mgoltzsche.github.com/allowed-namespaces annotation.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // ImageBuildEnvStatus defines the observed state of ImageBuildEnv
@@ -33,8 +127,10 @@ type ImageBuildEnvStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 	// Conditions represent the latest available observations of an object's state
 	Conditions status.Conditions `json:"conditions,omitempty"`
-	// SecretRefs lists the watched input secrets
+	// SecretRefs lists the watched input secrets (prefixed with their namespace when it differs from the ImageBuildEnv's)
 	SecretRefs []string `json:"secretRefs,omitempty"`
+	// Cache describes the build cache location if enabled
+	Cache *ImageBuildCacheStatus `json:"cache,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
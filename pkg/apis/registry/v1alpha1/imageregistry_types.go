@@ -4,6 +4,7 @@ import (
 	//cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,6 +13,41 @@ const (
 	ConditionReady   = status.ConditionType("Ready")
 	ReasonFailedSync = status.ConditionReason("FailedSync")
 	ReasonUpdating   = status.ConditionReason("Updating")
+	// ConditionMaintenance is true while the registry is in read-only maintenance mode
+	ConditionMaintenance = status.ConditionType("Maintenance")
+	ReasonReadOnly       = status.ConditionReason("ReadOnly")
+
+	// ConditionTLSCertificate reports the state of the registry's TLS certificate
+	ConditionTLSCertificate = status.ConditionType("TLSCertificate")
+	// ConditionAuthCACertificate reports the state of the auth token CA certificate
+	ConditionAuthCACertificate = status.ConditionType("AuthCACertificate")
+	// ConditionCertificateReady is true if both the TLS and the auth token CA certificate are valid
+	ConditionCertificateReady = status.ConditionType("CertificateReady")
+	// ConditionCertificateExpiringSoon is true if a certificate expires soon, indicating that its renewal failed
+	ConditionCertificateExpiringSoon = status.ConditionType("CertificateExpiringSoon")
+	ReasonCertificateNotReady        = status.ConditionReason("CertificateNotReady")
+	ReasonCertificateExpiringSoon    = status.ConditionReason("CertificateExpiringSoon")
+	ReasonCertificateGenerated       = status.ConditionReason("Generated")
+	ReasonCertificateIssuer          = status.ConditionReason("Issuer")
+	ReasonCertificateAdopted         = status.ConditionReason("Adopted")
+	ReasonCertificateExternal        = status.ConditionReason("External")
+	ReasonCertificateIntermediateCA  = status.ConditionReason("IntermediateCA")
+	ReasonSecretNotFound             = status.ConditionReason("SecretNotFound")
+	ReasonUnmanagedSecret            = status.ConditionReason("UnmanagedSecret")
+	ReasonInvalidCertificate         = status.ConditionReason("InvalidCertificate")
+	ReasonCertificateExpired         = status.ConditionReason("CertificateExpired")
+	ReasonDNSNameMismatch            = status.ConditionReason("DNSNameMismatch")
+	ReasonCertManagerUnavailable     = status.ConditionReason("CertManagerUnavailable")
+
+	// CertificateSecretModeExternal makes the operator use the Secret as is and report its validity
+	CertificateSecretModeExternal = CertificateSecretMode("External")
+	// CertificateSecretModeAdopt makes the operator take over and renew the Secret
+	CertificateSecretModeAdopt = CertificateSecretMode("Adopt")
+	// CertificateSecretModeIntermediateCA makes the operator sign the generated certificate with the CA key pair within the Secret
+	CertificateSecretModeIntermediateCA = CertificateSecretMode("IntermediateCA")
+
+	IngressTLSPassthrough = IngressTLSMode("passthrough")
+	IngressTLSReencrypt   = IngressTLSMode("reencrypt")
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -19,12 +55,82 @@ const (
 
 // ImageRegistrySpec defines the desired state of ImageRegistry
 type ImageRegistrySpec struct {
-	Replicas              *int32                    `json:"replicas,omitempty"`
-	PersistentVolumeClaim PersistentVolumeClaimSpec `json:"persistentVolumeClaim"`
-	TLS                   CertificateSpec           `json:"tls,omitempty"`
-	Auth                  AuthSpec                  `json:"auth,omitempty"`
+	// Hostnames lists the registry's external hostnames: the first one is the primary hostname, the others are aliases.
+	// Defaults to <NAME>.<NAMESPACE>.<OPERATOR_DNS_ZONE>.
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Replicas > 1 enables the highly available mode which requires a ReadWriteMany PersistentVolumeClaim
+	Replicas              *int32                     `json:"replicas,omitempty"`
+	PersistentVolumeClaim PersistentVolumeClaimSpec  `json:"persistentVolumeClaim"`
+	TLS                   CertificateSpec            `json:"tls,omitempty"`
+	Auth                  AuthSpec                   `json:"auth,omitempty"`
+	Notifications         []NotificationEndpointSpec `json:"notifications,omitempty"`
+	Expose                ExposeSpec                 `json:"expose,omitempty"`
+	PodTemplate           *PodTemplateSpec           `json:"podTemplate,omitempty"`
+	// ReadOnly enables the maintenance mode: pulls are allowed, pushes and deletions are rejected
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// MaintenanceMessage is exposed within the Maintenance condition while the registry is read-only
+	MaintenanceMessage string `json:"maintenanceMessage,omitempty"`
+	// Catalog enables the periodic synchronization of the registry's repositories into ImageRepository resources
+	Catalog *CatalogSpec `json:"catalog,omitempty"`
+	// NetworkPolicy makes the operator maintain a NetworkPolicy that restricts the traffic to the registry Pods
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicySpec specifies the peers that are allowed to access the registry Pods.
+// The internal registry and auth ports are never exposed.
+type NetworkPolicySpec struct {
+	// From lists the peers allowed to access the registry's https and http (nginx) ports.
+	// All sources are allowed if empty.
+	From []networkingv1.NetworkPolicyPeer `json:"from,omitempty"`
+	// MetricsFrom lists the peers allowed to scrape the registry's metrics ports (e.g. Prometheus).
+	// Metrics cannot be accessed if empty.
+	MetricsFrom []networkingv1.NetworkPolicyPeer `json:"metricsFrom,omitempty"`
+}
+
+// CatalogSpec specifies the catalog synchronization
+type CatalogSpec struct {
+	// Interval specifies the time between two synchronizations (default: 10m).
+	// Repositories are also synchronized when the operator receives a push event.
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
+// PodTemplateSpec is merged over the registry Pod's defaults using strategic merge patch semantics
+type PodTemplateSpec struct {
+	Labels            map[string]string          `json:"labels,omitempty"`
+	Annotations       map[string]string          `json:"annotations,omitempty"`
+	NodeSelector      map[string]string          `json:"nodeSelector,omitempty"`
+	Tolerations       []corev1.Toleration        `json:"tolerations,omitempty"`
+	Affinity          *corev1.Affinity           `json:"affinity,omitempty"`
+	PriorityClassName string                     `json:"priorityClassName,omitempty"`
+	SecurityContext   *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// Containers are merged by name into the default containers (registry, auth, nginx)
+	Containers []corev1.Container `json:"containers,omitempty"`
+}
+
+// ExposeSpec specifies how the registry is exposed
+type ExposeSpec struct {
+	// ServiceType is the registry Service's type (ClusterIP, NodePort or LoadBalancer; default: LoadBalancer)
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+	// Annotations are added to the registry Service
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Ingress exposes the registry using an Ingress (optional)
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+}
+
+// IngressSpec specifies the Ingress the operator maintains for a registry
+type IngressSpec struct {
+	// Host is the registry's external hostname if spec.hostnames is not specified (defaults to <NAME>.<NAMESPACE>.<OPERATOR_DNS_ZONE>)
+	Host string `json:"host,omitempty"`
+	// ClassName is set as kubernetes.io/ingress.class annotation
+	ClassName string `json:"className,omitempty"`
+	// TLSMode specifies whether the ingress controller passes TLS through to the registry or terminates and re-encrypts it (default: passthrough)
+	TLSMode IngressTLSMode `json:"tlsMode,omitempty"`
+	// Annotations are added to the Ingress
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type IngressTLSMode string
+
 // PersistentVolumeClaimSpec specifies the PersistentVolumeClaim that should be maintained
 type PersistentVolumeClaimSpec struct {
 	StorageClassName *string                             `json:"storageClassName,omitempty"`
@@ -43,12 +149,51 @@ type AuthSpec struct {
 type CertificateSpec struct {
 	IssuerRef  *CertIssuerRefSpec `json:"issuerRef,omitempty"`
 	SecretName *string            `json:"secretName,omitempty"`
+	// SecretMode specifies how the Secret referred to by secretName is used unless an issuerRef is specified (default: External).
+	// External: The Secret is used as is, its expiry and DNS names are reported.
+	// Adopt: The operator takes over the (existing) Secret and renews it like a generated one.
+	// IntermediateCA: The Secret contains a CA key pair the operator uses to sign the generated TLS certificate (not supported for the auth CA).
+	// +kubebuilder:validation:Enum=External;Adopt;IntermediateCA
+	SecretMode CertificateSecretMode `json:"secretMode,omitempty"`
+	// Key specifies the private key to generate (defaults to the operator's configuration)
+	Key *KeySpec `json:"key,omitempty"`
+	// Duration is the generated certificate's validity period (defaults to the operator's configuration)
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// RenewBefore specifies how long before expiry the certificate is renewed (default: a quarter of the duration)
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+}
+
+type CertificateSecretMode string
+
+// KeySpec specifies a private key
+type KeySpec struct {
+	// Algorithm is the private key's algorithm.
+	// The auth token CA supports RSA and ECDSA only since docker token authentication cannot verify Ed25519 signatures.
+	// +kubebuilder:validation:Enum=RSA;ECDSA-P256;ECDSA-P384;Ed25519
+	Algorithm string `json:"algorithm,omitempty"`
+	// Size is the RSA key size in bits (default: 2048 for server certificates, 4096 for CAs)
+	Size int `json:"size,omitempty"`
 }
 
 // CertificateIssuerSpec refers to a certificate issuer
 type CertIssuerRefSpec struct {
 	Name string `json:"name"`
 	Kind string `json:"kind"`
+	// Group specifies the API group of an external issuer such as step-issuer (default: cert-manager.io)
+	Group string `json:"group,omitempty"`
+}
+
+// NotificationEndpointSpec specifies a webhook the registry sends events to
+type NotificationEndpointSpec struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// HeadersSecretRef refers to a Secret within the registry's namespace whose entries are sent as HTTP headers
+	HeadersSecretRef *corev1.LocalObjectReference `json:"headersSecretRef,omitempty"`
+	Timeout          *metav1.Duration             `json:"timeout,omitempty"`
+	// IgnoredActions lists the event actions (push, pull, delete) that are not sent
+	IgnoredActions []string `json:"ignoredActions,omitempty"`
+	// IgnoredMediaTypes lists the target media types that are not sent
+	IgnoredMediaTypes []string `json:"ignoredMediaTypes,omitempty"`
 }
 
 // ImageRegistryStatus defines the observed state of ImageRegistry
@@ -57,6 +202,48 @@ type ImageRegistryStatus struct {
 	Conditions         status.Conditions `json:"conditions,omitempty"`
 	Hostname           string            `json:"hostname,omitempty"`
 	TLSSecretName      string            `json:"tlsSecretName,omitempty"`
+	// Replicas is the number of registry Pods
+	Replicas int32 `json:"replicas,omitempty"`
+	// ReadyReplicas is the number of ready registry Pods
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// Pods lists the readiness of each registry Pod
+	Pods []RegistryPodStatus `json:"pods,omitempty"`
+	// CARotation reports the progress of a root CA rotation that is in progress
+	CARotation *CARotationStatus `json:"caRotation,omitempty"`
+	// TLSCertificate describes the registry's current TLS certificate
+	TLSCertificate *CertificateStatus `json:"tlsCertificate,omitempty"`
+	// AuthCACertificate describes the current auth token CA certificate
+	AuthCACertificate *CertificateStatus `json:"authCACertificate,omitempty"`
+}
+
+// CertificateStatus describes a certificate as it is found within its Secret
+type CertificateStatus struct {
+	SecretName string       `json:"secretName"`
+	NotBefore  *metav1.Time `json:"notBefore,omitempty"`
+	NotAfter   *metav1.Time `json:"notAfter,omitempty"`
+	// Issuer is the certificate issuer's distinguished name
+	Issuer   string   `json:"issuer,omitempty"`
+	DNSNames []string `json:"dnsNames,omitempty"`
+}
+
+// CARotationStatus reports the progress of a staged root CA rotation.
+// During the overlap period both the previous and the new root CA are trusted.
+type CARotationStatus struct {
+	// StartTime is the time the new root CA has been generated
+	StartTime metav1.Time `json:"startTime"`
+	// OverlapEndTime is the time the previous root CA is removed from the trust bundle
+	OverlapEndTime metav1.Time `json:"overlapEndTime"`
+	// ServerCertRenewed is true once the registry's TLS certificate has been signed by the new root CA
+	ServerCertRenewed bool `json:"serverCertRenewed"`
+}
+
+// RegistryPodStatus specifies the observed state of a registry Pod
+type RegistryPodStatus struct {
+	Name string `json:"name"`
+	// Ready is true if all of the Pod's containers are ready
+	Ready bool `json:"ready"`
+	// Updated is true if the Pod runs the StatefulSet's latest revision
+	Updated bool `json:"updated"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
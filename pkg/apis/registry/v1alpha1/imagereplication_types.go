@@ -0,0 +1,82 @@
+package v1alpha1
+
+import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ReplicationTriggerPush     = ReplicationTrigger("push")
+	ReplicationTriggerSchedule = ReplicationTrigger("schedule")
+	ReasonFailedReplication    = status.ConditionReason("FailedReplication")
+)
+
+type ReplicationTrigger string
+
+// ImageReplicationSpec defines the desired state of ImageReplication
+type ImageReplicationSpec struct {
+	Source ReplicationEndpointSpec `json:"source"`
+	Target ReplicationEndpointSpec `json:"target"`
+	// Repositories lists regular expressions a repository name must match to be replicated (all by default)
+	Repositories []string `json:"repositories,omitempty"`
+	// Trigger specifies whether replication happens on push events (requires the operator's notification receiver) or periodically
+	Trigger ReplicationTrigger `json:"trigger,omitempty"`
+	// Interval specifies the time between two scheduled replications (default: 1h)
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ReplicationEndpointSpec refers to either an ImageRegistry or an external registry
+type ReplicationEndpointSpec struct {
+	RegistryRef *ImageRegistryRef     `json:"registryRef,omitempty"`
+	External    *ExternalRegistrySpec `json:"external,omitempty"`
+}
+
+// ExternalRegistrySpec specifies a registry that is not maintained by the operator
+type ExternalRegistrySpec struct {
+	Hostname string `json:"hostname"`
+	// SecretName refers to a docker config Secret within the ImageReplication's namespace (optionally providing a ca.crt)
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// ImageReplicationStatus defines the observed state of ImageReplication
+type ImageReplicationStatus struct {
+	ObservedGeneration int64             `json:"observedGeneration,omitempty"`
+	Conditions         status.Conditions `json:"conditions,omitempty"`
+	LastSyncTime       *metav1.Time      `json:"lastSyncTime,omitempty"`
+	// Repositories lists the sync state per repository
+	Repositories []RepositorySyncStatus `json:"repositories,omitempty"`
+}
+
+// RepositorySyncStatus specifies the last replication result of a repository
+type RepositorySyncStatus struct {
+	Name         string       `json:"name"`
+	Tags         int          `json:"tags"`
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImageReplication is the Schema for the imagereplications API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=imagereplications,scope=Namespaced
+type ImageReplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageReplicationSpec   `json:"spec,omitempty"`
+	Status ImageReplicationStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImageReplicationList contains a list of ImageReplication
+type ImageReplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImageReplication `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImageReplication{}, &ImageReplicationList{})
+}
@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LabelImageRegistry refers to the ImageRegistry (within the same namespace) an object belongs to
+	LabelImageRegistry = "registry.mgoltzsche.github.com/imageregistry"
+)
+
+// ImageRepositoryStatus defines the observed state of ImageRepository
+type ImageRepositoryStatus struct {
+	// Registry is the registry's hostname
+	Registry string `json:"registry"`
+	// Repository is the repository's name within the registry
+	Repository string           `json:"repository"`
+	Tags       []ImageTagStatus `json:"tags,omitempty"`
+	// TagCount is the number of tags
+	TagCount     int          `json:"tagCount"`
+	LastPushTime *metav1.Time `json:"lastPushTime,omitempty"`
+	// LastSyncTime is the time the synchronization changed the status last
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// ImageTagStatus describes a tag's manifest
+type ImageTagStatus struct {
+	Name      string `json:"name"`
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType,omitempty"`
+	// Size is the sum of the manifest's config and layer sizes in bytes (0 for manifest lists)
+	Size int64 `json:"size,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImageRepository is a read-only representation of a repository within an ImageRegistry, maintained by the operator
+// +kubebuilder:resource:path=imagerepositories,scope=Namespaced
+// +kubebuilder:printcolumn:name="Registry",type="string",JSONPath=".status.registry"
+// +kubebuilder:printcolumn:name="Repository",type="string",JSONPath=".status.repository"
+// +kubebuilder:printcolumn:name="Tags",type="integer",JSONPath=".status.tagCount"
+// +kubebuilder:printcolumn:name="Last Push",type="date",JSONPath=".status.lastPushTime"
+type ImageRepository struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status ImageRepositoryStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImageRepositoryList contains a list of ImageRepository
+type ImageRepositoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImageRepository `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImageRepository{}, &ImageRepositoryList{})
+}
@@ -23,6 +23,7 @@ type ImageSecretInterface interface {
 	metav1.Object
 	GetRegistryRef() *ImageRegistryRef
 	GetRegistryAccessMode() ImageSecretType
+	GetRepositoryPrefix() string
 	GetStatus() *ImageSecretStatus
 }
 
@@ -38,6 +39,10 @@ func (s *ImageSecret) GetRegistryRef() *ImageRegistryRef {
 	return s.Spec.RegistryRef
 }
 
+func (s *ImageSecret) GetRepositoryPrefix() string {
+	return s.Spec.RepositoryPrefix
+}
+
 func (s *ImageSecret) GetStatus() *ImageSecretStatus {
 	return &s.Status
 }
@@ -45,6 +50,9 @@ func (s *ImageSecret) GetStatus() *ImageSecretStatus {
 // ImageSecretSpec defines the desired state of ImagePushSecret/ImagePullSecret
 type ImageSecretSpec struct {
 	RegistryRef *ImageRegistryRef `json:"registryRef,omitempty"`
+	// RepositoryPrefix restricts an ImagePushSecret's push and delete permissions to the repositories below the prefix.
+	// The prefix must start with the ImagePushSecret's namespace followed by a slash.
+	RepositoryPrefix string `json:"repositoryPrefix,omitempty"`
 }
 
 // ImageRegistryRef refers to an ImageRegistry
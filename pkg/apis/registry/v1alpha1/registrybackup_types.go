@@ -0,0 +1,98 @@
+package v1alpha1
+
+import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupPhasePending indicates that the registry is being switched into read-only mode
+	BackupPhasePending   = BackupPhase("Pending")
+	BackupPhaseRunning   = BackupPhase("Running")
+	BackupPhaseSucceeded = BackupPhase("Succeeded")
+	BackupPhaseFailed    = BackupPhase("Failed")
+	ReasonJobFailed      = status.ConditionReason("JobFailed")
+)
+
+type BackupPhase string
+
+// RegistryBackupSpec defines the desired state of RegistryBackup
+type RegistryBackupSpec struct {
+	// RegistryRef refers to the ImageRegistry within the RegistryBackup's namespace that should be backed up
+	RegistryRef corev1.LocalObjectReference `json:"registryRef"`
+	Target      BackupStorageSpec           `json:"target"`
+	// Image overrides the backup Job's image
+	Image string `json:"image,omitempty"`
+}
+
+// BackupStorageSpec specifies where backup archives are stored: either a PersistentVolumeClaim or an S3 bucket
+type BackupStorageSpec struct {
+	PersistentVolumeClaim *BackupPVCSpec `json:"persistentVolumeClaim,omitempty"`
+	S3                    *BackupS3Spec  `json:"s3,omitempty"`
+}
+
+// BackupPVCSpec refers to a PersistentVolumeClaim within the RegistryBackup's namespace
+type BackupPVCSpec struct {
+	ClaimName string `json:"claimName"`
+	// Path is the directory within the volume the archives are written to
+	Path string `json:"path,omitempty"`
+}
+
+// BackupS3Spec specifies an S3 bucket
+type BackupS3Spec struct {
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the archive's object key
+	Prefix string `json:"prefix,omitempty"`
+	// Endpoint specifies the URL of an S3 compatible service (defaults to AWS)
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+	// SecretName refers to a Secret providing the keys AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// RegistryBackupStatus defines the observed state of RegistryBackup
+type RegistryBackupStatus struct {
+	Conditions          status.Conditions `json:"conditions,omitempty"`
+	Phase               BackupPhase       `json:"phase,omitempty"`
+	BackupArchiveStatus `json:",inline"`
+}
+
+// BackupArchiveStatus describes a backup archive and the Job that processed it
+type BackupArchiveStatus struct {
+	JobName string `json:"jobName,omitempty"`
+	// Archive is the archive's file name or object key within the backup storage
+	Archive string `json:"archive,omitempty"`
+	// Size is the archive's size in bytes
+	Size int64 `json:"size,omitempty"`
+	// Checksum is the archive's sha256 checksum
+	Checksum       string       `json:"checksum,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RegistryBackup is the Schema for the registrybackups API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=registrybackups,scope=Namespaced
+type RegistryBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RegistryBackupSpec   `json:"spec,omitempty"`
+	Status RegistryBackupStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RegistryBackupList contains a list of RegistryBackup
+type RegistryBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RegistryBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RegistryBackup{}, &RegistryBackupList{})
+}
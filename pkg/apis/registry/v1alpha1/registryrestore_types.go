@@ -0,0 +1,62 @@
+package v1alpha1
+
+import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegistryRestoreSpec defines the desired state of RegistryRestore
+type RegistryRestoreSpec struct {
+	// RegistryRef refers to the ImageRegistry within the RegistryRestore's namespace whose storage should be replaced
+	RegistryRef corev1.LocalObjectReference `json:"registryRef"`
+	// Registry specifies the ImageRegistry that is created if the referred one does not exist (optional)
+	Registry *ImageRegistrySpec `json:"registry,omitempty"`
+	// BackupRef refers to a succeeded RegistryBackup within the same namespace
+	BackupRef *corev1.LocalObjectReference `json:"backupRef,omitempty"`
+	// Source specifies the archive explicitly if no backupRef is specified
+	Source *RestoreSourceSpec `json:"source,omitempty"`
+	// Image overrides the restore Job's image
+	Image string `json:"image,omitempty"`
+}
+
+// RestoreSourceSpec specifies a backup archive
+type RestoreSourceSpec struct {
+	Storage BackupStorageSpec `json:"storage"`
+	Archive string            `json:"archive"`
+	// Checksum is the archive's expected sha256 checksum (optional)
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// RegistryRestoreStatus defines the observed state of RegistryRestore
+type RegistryRestoreStatus struct {
+	Conditions          status.Conditions `json:"conditions,omitempty"`
+	Phase               BackupPhase       `json:"phase,omitempty"`
+	BackupArchiveStatus `json:",inline"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RegistryRestore is the Schema for the registryrestores API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=registryrestores,scope=Namespaced
+type RegistryRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RegistryRestoreSpec   `json:"spec,omitempty"`
+	Status RegistryRestoreStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RegistryRestoreList contains a list of RegistryRestore
+type RegistryRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RegistryRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RegistryRestore{}, &RegistryRestoreList{})
+}
@@ -2,10 +2,12 @@ package auth
 
 import (
 	"context"
+	"os"
 	"sync"
 	"time"
 
 	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/crypto/bcrypt"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
@@ -16,10 +18,20 @@ import (
 )
 
 const (
-	Origin = "cr"
+	Origin         = "cr"
+	labelAccess    = "accessMode"
+	accessModePull = "pull"
 )
 
-var originCR = []string{Origin}
+var (
+	originCR = []string{Origin}
+	// Authentications counts the authentication attempts per result (success, failure, error)
+	Authentications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "image_registry_auth",
+		Name:      "authentications_total",
+		Help:      "Number of authentication attempts against ImageRegistryAccounts",
+	}, []string{"result"})
+)
 
 type cachedAccount struct {
 	HashedPassword
@@ -48,9 +60,11 @@ type Authenticator struct {
 	lock      sync.Locker
 	log       ErrorLogger
 	namespace string
+	// readOnlyFile denies pushes while it exists (optional)
+	readOnlyFile string
 }
 
-func NewAuthenticator(cfg *rest.Config, namespace string, log ErrorLogger) (a *Authenticator, err error) {
+func NewAuthenticator(cfg *rest.Config, namespace, readOnlyFile string, log ErrorLogger) (a *Authenticator, err error) {
 	scheme, err := registryapi.SchemeBuilder.Build()
 	if err != nil {
 		return
@@ -63,7 +77,7 @@ func NewAuthenticator(cfg *rest.Config, namespace string, log ErrorLogger) (a *A
 	if err != nil {
 		return
 	}
-	return &Authenticator{reader, map[string]*cachedAccount{}, &sync.Mutex{}, log, namespace}, nil
+	return &Authenticator{reader, map[string]*cachedAccount{}, &sync.Mutex{}, log, namespace, readOnlyFile}, nil
 }
 
 func (a *Authenticator) Authenticate(user, passwd string) (labels map[string][]string, err error) {
@@ -72,11 +86,45 @@ func (a *Authenticator) Authenticate(user, passwd string) (labels map[string][]s
 		account, err = a.findAccount(user)
 		if err == nil && account != nil && account.MatchPassword(passwd) {
 			labels = account.Labels
+			if a.readOnly() {
+				labels = readOnlyLabels(labels)
+			}
 		}
 	}
+	switch {
+	case err != nil:
+		Authentications.WithLabelValues("error").Inc()
+	case labels == nil:
+		Authentications.WithLabelValues("failure").Inc()
+	default:
+		Authentications.WithLabelValues("success").Inc()
+	}
 	return
 }
 
+// readOnly returns true while the registry is in maintenance mode.
+// The file is synced from a ConfigMap which allows to toggle the mode without a restart.
+func (a *Authenticator) readOnly() bool {
+	if a.readOnlyFile == "" {
+		return false
+	}
+	_, err := os.Stat(a.readOnlyFile)
+	return err == nil
+}
+
+// readOnlyLabels returns a copy of the labels that matches pull rules only
+func readOnlyLabels(labels map[string][]string) map[string][]string {
+	if _, ok := labels[labelAccess]; !ok {
+		return labels
+	}
+	l := make(map[string][]string, len(labels))
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[labelAccess] = []string{accessModePull}
+	return l
+}
+
 func (a *Authenticator) findAccount(username string) (account *cachedAccount, _ error) {
 	if account = a.cache[username]; account != nil && !account.CacheExpired() {
 		return // cached
@@ -0,0 +1,112 @@
+package backrefs
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	refSeparator  = ","
+	kindSeparator = ":"
+)
+
+// AnnotationReferences returns a BackReferenceStrategy that lists the owners
+// within an annotation of the referenced object as <KIND>:<NAMESPACE>/<NAME> entries.
+// Unlike OwnerReferences it supports references across namespaces
+// and owners of different kinds may share the same annotation.
+func AnnotationReferences(annotation string) BackReferenceStrategy {
+	return &annotationRefs{annotation}
+}
+
+// AnnotationReferencesToRequests maps an object to requests for the owners of the given type
+// listed within the object's annotation (see AnnotationReferences).
+func AnnotationReferencesToRequests(annotation string, ownerType runtime.Object) handler.Mapper {
+	return &annotationRefsToRequests{annotation, kindOf(ownerType)}
+}
+
+type annotationRefs struct {
+	annotation string
+}
+
+func (s *annotationRefs) AddReference(from metav1.Object, to Object) bool {
+	entry := annotationRefEntry(to)
+	refs := parseAnnotationRefs(from.GetAnnotations()[s.annotation])
+	for _, ref := range refs {
+		if ref == entry {
+			return false
+		}
+	}
+	s.setRefs(from, append(refs, entry))
+	return true
+}
+
+func (s *annotationRefs) DelReference(from metav1.Object, to Object) bool {
+	entry := annotationRefEntry(to)
+	refs := parseAnnotationRefs(from.GetAnnotations()[s.annotation])
+	for i, ref := range refs {
+		if ref == entry {
+			s.setRefs(from, append(refs[:i], refs[i+1:]...))
+			return true
+		}
+	}
+	return false
+}
+
+func (s *annotationRefs) setRefs(o metav1.Object, refs []string) {
+	a := o.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	if len(refs) == 0 {
+		delete(a, s.annotation)
+	} else {
+		sort.Strings(refs)
+		a[s.annotation] = strings.Join(refs, refSeparator)
+	}
+	o.SetAnnotations(a)
+}
+
+type annotationRefsToRequests struct {
+	annotation string
+	kind       string
+}
+
+func (m *annotationRefsToRequests) Map(o handler.MapObject) (r []reconcile.Request) {
+	for _, ref := range parseAnnotationRefs(o.Meta.GetAnnotations()[m.annotation]) {
+		s := strings.SplitN(ref, kindSeparator, 2)
+		if len(s) < 2 || s[0] != m.kind {
+			continue
+		}
+		nsName := strings.SplitN(s[1], "/", 2)
+		if len(nsName) < 2 || nsName[0] == "" || nsName[1] == "" {
+			continue
+		}
+		r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nsName[0], Name: nsName[1]}})
+	}
+	return
+}
+
+func parseAnnotationRefs(value string) (refs []string) {
+	for _, ref := range strings.Split(value, refSeparator) {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return
+}
+
+func annotationRefEntry(o Object) string {
+	return kindOf(o) + kindSeparator + o.GetNamespace() + "/" + o.GetName()
+}
+
+// kindOf returns the object's Go type name which corresponds to the kind of typed objects
+func kindOf(o runtime.Object) string {
+	return reflect.TypeOf(o).Elem().Name()
+}
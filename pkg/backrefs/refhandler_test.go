@@ -9,15 +9,37 @@ import (
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-const testAnnotation = "backrefhandler"
+const (
+	testAnnotation    = "backrefhandler"
+	testRefAnnotation = "example.org/backrefs"
+)
 
 func TestBackReferenceHandler(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		strategy   BackReferenceStrategy
+		namespaces []string
+		backRefs   func(refs []Object, owner Object) []string
+	}{
+		{"ownerReferences", OwnerReferences(), []string{"ns0"}, secretsByOwnerRef},
+		{"annotations", AnnotationReferences(testRefAnnotation), []string{"ns0", "ns1"}, secretsByAnnotation},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testBackReferenceHandler(t, c.strategy, c.namespaces, c.backRefs)
+		})
+	}
+}
+
+func testBackReferenceHandler(t *testing.T, strategy BackReferenceStrategy, namespaces []string, backRefs func([]Object, Object) []string) {
 	logger := logf.Log
 	ctx := context.TODO()
 	client := fake.NewFakeClient()
@@ -28,7 +50,7 @@ func TestBackReferenceHandler(t *testing.T) {
 	for i := 0; i < 6; i++ {
 		if i%2 == 0 {
 			ref := &corev1.Secret{}
-			ref.Namespace = fmt.Sprintf("ns%d", i%2)
+			ref.Namespace = namespaces[(i/2)%len(namespaces)]
 			ref.Name = fmt.Sprintf("secret%d", i%5)
 			refs = append(refs, ref)
 		}
@@ -40,7 +62,7 @@ func TestBackReferenceHandler(t *testing.T) {
 	}
 	loadObjects(t, client, allObj)
 
-	testee := NewBackReferencesHandler(client, OwnerReferences())
+	testee := NewBackReferencesHandler(client, strategy)
 
 	retrievedRefs := owner.GetStatusReferences()
 	require.Equal(t, 0, len(retrievedRefs), "initial len(refs)")
@@ -64,7 +86,7 @@ func TestBackReferenceHandler(t *testing.T) {
 		loadObjects(t, client, retrievedRefs)
 		require.Equal(t, keys(c.refs), keys(retrievedRefs), "owner status refs")
 
-		backRefSecrets := secretsByOwnerRef(c.refs, owner.GetObject())
+		backRefSecrets := backRefs(c.refs, owner.GetObject())
 		require.Equal(t, keys(c.refs), backRefSecrets, "back references (secrets->configmap)")
 
 		err = testee.UpdateReferences(ctx, logger, owner, c.refs)
@@ -162,3 +184,85 @@ func ownerRefCount(s, o Object) (c int) {
 	}
 	return
 }
+
+func secretsByAnnotation(secrets []Object, o Object) (r []string) {
+	mapper := AnnotationReferencesToRequests(testRefAnnotation, o)
+	for _, s := range secrets {
+		for _, req := range mapper.Map(handler.MapObject{Meta: s, Object: s}) {
+			if req.Name == o.GetName() && req.Namespace == o.GetNamespace() {
+				r = append(r, key(s))
+			}
+		}
+	}
+	sort.Strings(r)
+	return
+}
+
+func TestAnnotationReferences(t *testing.T) {
+	owner := func(o Object, ns, name string) Object {
+		o.SetNamespace(ns)
+		o.SetName(name)
+		return o
+	}
+	cm1 := owner(&corev1.ConfigMap{}, "ns1", "cm1")
+	cm2 := owner(&corev1.ConfigMap{}, "ns2", "cm2")
+	secret := owner(&corev1.Secret{}, "ns1", "secret")
+	for _, c := range []struct {
+		name        string
+		annotation  string
+		add         bool
+		owner       Object
+		expected    string
+		expectedMod bool
+	}{
+		{"add first", "", true, cm1, "ConfigMap:ns1/cm1", true},
+		{"add existing", "ConfigMap:ns1/cm1", true, cm1, "ConfigMap:ns1/cm1", false},
+		{"add cross-namespace", "ConfigMap:ns1/cm1", true, cm2, "ConfigMap:ns1/cm1,ConfigMap:ns2/cm2", true},
+		{"add other kind", "ConfigMap:ns2/cm2", true, secret, "ConfigMap:ns2/cm2,Secret:ns1/secret", true},
+		{"delete", "ConfigMap:ns1/cm1,ConfigMap:ns2/cm2", false, cm1, "ConfigMap:ns2/cm2", true},
+		{"delete other kind", "ConfigMap:ns1/cm1,Secret:ns1/secret", false, secret, "ConfigMap:ns1/cm1", true},
+		{"delete last", "ConfigMap:ns1/cm1", false, cm1, "", true},
+		{"delete missing", "ConfigMap:ns1/cm1", false, cm2, "ConfigMap:ns1/cm1", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testee := AnnotationReferences(testRefAnnotation)
+			o := &metav1.ObjectMeta{Annotations: map[string]string{"other": "value"}}
+			if c.annotation != "" {
+				o.Annotations[testRefAnnotation] = c.annotation
+			}
+			var modified bool
+			if c.add {
+				modified = testee.AddReference(o, c.owner)
+			} else {
+				modified = testee.DelReference(o, c.owner)
+			}
+			require.Equal(t, c.expectedMod, modified, "modified")
+			expected := map[string]string{"other": "value"}
+			if c.expected != "" {
+				expected[testRefAnnotation] = c.expected
+			}
+			require.Equal(t, expected, o.Annotations, "annotations")
+		})
+	}
+}
+
+func TestAnnotationReferencesToRequests(t *testing.T) {
+	testee := AnnotationReferencesToRequests(testRefAnnotation, &corev1.ConfigMap{})
+	for _, c := range []struct {
+		annotation string
+		expected   []reconcile.Request
+	}{
+		{"ConfigMap:ns1/cm1,Secret:ns1/secret,ConfigMap:ns2/cm2", []reconcile.Request{
+			{NamespacedName: types.NamespacedName{Namespace: "ns1", Name: "cm1"}},
+			{NamespacedName: types.NamespacedName{Namespace: "ns2", Name: "cm2"}},
+		}},
+		{"Secret:ns1/secret", nil},
+		{"ConfigMap:ns1/", nil},
+		{"ConfigMap:cm1", nil},
+		{"ConfigMap", nil},
+		{"", nil},
+	} {
+		o := handler.MapObject{Meta: &metav1.ObjectMeta{Annotations: map[string]string{testRefAnnotation: c.annotation}}}
+		require.Equal(t, c.expected, testee.Map(o), "mapped requests of %q", c.annotation)
+	}
+}
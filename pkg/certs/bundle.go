@@ -0,0 +1,27 @@
+package certs
+
+import (
+	"bytes"
+	"encoding/pem"
+)
+
+// MergeCertificates concatenates the PEM encoded certificates omitting duplicates and other PEM blocks
+func MergeCertificates(pems [][]byte) []byte {
+	var buf bytes.Buffer
+	seen := map[string]bool{}
+	for _, b := range pems {
+		for {
+			var block *pem.Block
+			block, b = pem.Decode(b)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" || seen[string(block.Bytes)] {
+				continue
+			}
+			seen[string(block.Bytes)] = true
+			pem.Encode(&buf, block)
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,20 @@
+package certs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeCertificates(t *testing.T) {
+	params := KeyParams{Algorithm: KeyAlgorithmECDSAP256}
+	ca1, err := NewSelfSignedCAKeyPair("ca1", params)
+	require.NoError(t, err)
+	ca2, err := NewSelfSignedCAKeyPair("ca2", params)
+	require.NoError(t, err)
+	rotationBundle := append(append([]byte{}, ca2.CertPEM()...), ca1.CertPEM()...)
+	merged := MergeCertificates([][]byte{rotationBundle, ca1.CertPEM(), nil, ca1.KeyPEM(), ca2.CertPEM()})
+	require.Equal(t, string(rotationBundle), string(merged))
+	require.Equal(t, 1, bytes.Count(merged, ca1.CertPEM()), "ca1 occurrences")
+}
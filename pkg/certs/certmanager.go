@@ -1,16 +1,21 @@
 package certs
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/metrics"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -44,38 +49,67 @@ func RootCASecretName() types.NamespacedName {
 type CertManager struct {
 	client           client.Client
 	scheme           *runtime.Scheme
+	recorder         record.EventRecorder
 	rootCASecretName types.NamespacedName
+	rotationOverlap  time.Duration
+	rootCAParams     KeyParams
+	tokenCAParams    KeyParams
+	serverCertParams KeyParams
 }
 
-func NewCertManager(client client.Client, scheme *runtime.Scheme, rootCASecretName types.NamespacedName) *CertManager {
-	return &CertManager{client, scheme, rootCASecretName}
+func NewCertManager(client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, rootCASecretName types.NamespacedName) *CertManager {
+	return &CertManager{
+		client:           client,
+		scheme:           scheme,
+		recorder:         recorder,
+		rootCASecretName: rootCASecretName,
+		rotationOverlap:  caRotationOverlap(),
+		rootCAParams:     keyParamsFromEnv(EnvPrefixRootCA, defaultCAKeyParams()),
+		tokenCAParams:    tokenCAKeyParamsFromEnv(),
+		serverCertParams: keyParamsFromEnv(EnvPrefixServerCert, defaultServerKeyParams()),
+	}
+}
+
+// TokenCAKeyParams completes the given auth token CA key parameters with the operator's defaults
+func (r *CertManager) TokenCAKeyParams(p KeyParams) KeyParams {
+	return p.WithDefaults(r.tokenCAParams)
+}
+
+// ServerCertKeyParams completes the given server certificate key parameters with the operator's defaults
+func (r *CertManager) ServerCertKeyParams(p KeyParams) KeyParams {
+	return p.WithDefaults(r.serverCertParams)
 }
 
 func (r *CertManager) RootCACert() (*KeyPair, error) {
 	return r.KeyPair(r.rootCASecretName)
 }
 
-func (r *CertManager) RenewRootCACertSecret() (*KeyPair, error) {
-	ca, err := r.RenewCACertSecret(r.rootCASecretName, nil, rootCASecretLabels, rootCACommonName)
-	if err != nil && (errors.Unwrap(err) != ErrUnmanagedValidSecretExists || ca == nil) {
-		return nil, err
-	}
-	return ca, nil
+func (r *CertManager) RenewCACertSecret(key types.NamespacedName, owner metav1.Object, labels map[string]string, commonName string, params KeyParams) (cert *KeyPair, err error) {
+	cond := func(cert *KeyPair) bool { return !params.matches(cert) || condCACert(cert) }
+	return r.renewCertSecret(key, owner, labels, cond, params, nil, func() (*KeyPair, error) {
+		return NewSelfSignedCAKeyPair(commonName, params)
+	})
 }
 
-func (r *CertManager) RenewCACertSecret(key types.NamespacedName, owner metav1.Object, labels map[string]string, commonName string) (cert *KeyPair, err error) {
-	return r.renewCertSecret(key, owner, labels, condCACert, func() (*KeyPair, error) {
-		return NewSelfSignedCAKeyPair(commonName)
+func (r *CertManager) RenewServerCertSecret(key types.NamespacedName, owner metav1.Object, labels map[string]string, dnsNames []string, ca *KeyPair, params KeyParams) (cert *KeyPair, err error) {
+	cond := func(cert *KeyPair) bool { return !params.matches(cert) || condServerCert(dnsNames, ca)(cert) }
+	return r.renewCertSecret(key, owner, labels, cond, params, ca, func() (*KeyPair, error) {
+		return NewServerKeyPair(dnsNames, ca, params)
 	})
 }
 
-func (r *CertManager) RenewServerCertSecret(key types.NamespacedName, owner metav1.Object, labels map[string]string, dnsNames []string, ca *KeyPair) (cert *KeyPair, err error) {
-	return r.renewCertSecret(key, owner, labels, condServerCert(dnsNames), func() (*KeyPair, error) {
-		return NewServerKeyPair(dnsNames, ca)
+// RenewAdoptedServerCertSecret renews the server certificate within an adopted Secret
+// only when it expires or is not valid for all DNS names.
+// Since the certificate may have been issued by another CA neither its signer nor its key parameters are checked.
+func (r *CertManager) RenewAdoptedServerCertSecret(key types.NamespacedName, labels map[string]string, dnsNames []string, ca *KeyPair, params KeyParams) (cert *KeyPair, err error) {
+	return r.renewCertSecret(key, nil, labels, condAdoptedServerCert(dnsNames), params, ca, func() (*KeyPair, error) {
+		return NewServerKeyPair(dnsNames, ca, params)
 	})
 }
 
-func (r *CertManager) renewCertSecret(key types.NamespacedName, owner metav1.Object, labels map[string]string, cond func(*KeyPair) bool, factory func() (*KeyPair, error)) (cert *KeyPair, err error) {
+// renewCertSecret (re)generates the certificate within the given Secret if necessary.
+// When a CA is provided the Secret's ca.crt is kept in sync with its bundle as long as the certificate is signed by it.
+func (r *CertManager) renewCertSecret(key types.NamespacedName, owner metav1.Object, labels map[string]string, cond func(*KeyPair) bool, params KeyParams, ca *KeyPair, factory func() (*KeyPair, error)) (cert *KeyPair, err error) {
 	secret := &corev1.Secret{}
 	secret.Name = key.Name
 	secret.Namespace = key.Namespace
@@ -84,8 +118,12 @@ func (r *CertManager) renewCertSecret(key types.NamespacedName, owner metav1.Obj
 		secretLabels[k] = v
 	}
 	secretLabels[labelManagedBy] = operatorName
+	renewed := false
 	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, secret, func() (e error) {
 		cert = certFromMap(secret.Data)
+		if cert != nil {
+			cert.renewBefore = params.RenewBefore
+		}
 		if owner != nil {
 			if e = controllerutil.SetControllerReference(owner, secret, r.scheme); e != nil {
 				return
@@ -99,22 +137,49 @@ func (r *CertManager) renewCertSecret(key types.NamespacedName, owner metav1.Obj
 			}
 		}
 		secret.Labels = secretLabels
-		secret.Type = corev1.SecretTypeTLS
+		if secret.UID == "" {
+			// The type of an existing (adopted) Secret cannot be changed
+			secret.Type = corev1.SecretTypeTLS
+		}
 		if cert == nil || cert.NeedsRenewal() || cond(cert) {
-			cert, e = factory()
+			if cert, e = factory(); e != nil {
+				return
+			}
 			secret.Data = certToMap(cert)
+			renewed = true
+		}
+		if ca != nil && cert.SignedBy(ca) && !bytes.Equal(secret.Data[secretKeyCACrt], ca.CACertPEM()) {
+			cert.caCertPEM = ca.CACertPEM()
+			secret.Data[secretKeyCACrt] = ca.CACertPEM()
 		}
 		return
 	})
 	if err != nil {
 		err = fmt.Errorf("upsert certificate secret %s/%s: %w", secret.Namespace, secret.Name, err)
 	}
+	if cert != nil {
+		metrics.CertificateExpiry.Set(cert.NotAfter(), key.Namespace, key.Name)
+	}
+	if err == nil && renewed {
+		var obj runtime.Object = secret
+		if o, ok := owner.(runtime.Object); ok {
+			obj = o
+		}
+		r.recorder.Eventf(obj, corev1.EventTypeNormal, registryapi.EventReasonCertificateRenewed,
+			"Renewed certificate in Secret %s (valid until %s)", key.Name, cert.NotAfter().Format(time.RFC3339))
+	}
 	return
 }
 
-func condServerCert(dnsNames []string) func(*KeyPair) bool {
+func condServerCert(dnsNames []string, ca *KeyPair) func(*KeyPair) bool {
+	return func(cert *KeyPair) bool {
+		return cert.IsCA() || !equalNames(cert.DNSNames(), dnsNames) || !cert.SignedBy(ca)
+	}
+}
+
+func condAdoptedServerCert(dnsNames []string) func(*KeyPair) bool {
 	return func(cert *KeyPair) bool {
-		return cert.IsCA() || !equalNames(cert.DNSNames(), dnsNames)
+		return cert.IsCA() || len(cert.MissingDNSNames(dnsNames)) > 0
 	}
 }
 
@@ -122,6 +187,45 @@ func condCACert(cert *KeyPair) bool {
 	return !cert.IsCA()
 }
 
+// AdoptSecret labels an existing Secret as managed by the operator
+// so that the operator renews the certificate it contains from now on.
+func (r *CertManager) AdoptSecret(key types.NamespacedName) error {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), key, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if secret.Labels[labelManagedBy] == operatorName {
+		return nil
+	}
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[labelManagedBy] = operatorName
+	if err := r.client.Update(context.TODO(), secret); err != nil {
+		return fmt.Errorf("adopt secret %s/%s: %w", key.Namespace, key.Name, err)
+	}
+	r.recorder.Event(secret, corev1.EventTypeNormal, registryapi.EventReasonSecretAdopted, "Adopted Secret")
+	return nil
+}
+
+// IntermediateCA loads a user-provided CA key pair from the given Secret to sign server certificates.
+// The signed certificates are bundled with the CA certificate
+// and the CA Secret's ca.crt (or tls.crt if not provided) is distributed as trust anchor.
+func (r *CertManager) IntermediateCA(key types.NamespacedName) (*KeyPair, error) {
+	ca, err := r.KeyPair(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ca.IsCA() {
+		return nil, fmt.Errorf("certificate in Secret %s/%s is not a CA", key.Namespace, key.Name)
+	}
+	ca.intermediate = true
+	if len(ca.caCertPEM) == 0 {
+		ca.caCertPEM = ca.CertPEM()
+	}
+	return ca, nil
+}
+
 func (r *CertManager) KeyPair(name types.NamespacedName) (cert *KeyPair, err error) {
 	secret := &corev1.Secret{}
 	err = r.client.Get(context.TODO(), name, secret)
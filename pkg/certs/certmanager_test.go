@@ -0,0 +1,88 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAdoptSecretAndIntermediateCA(t *testing.T) {
+	ns := "myns"
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ca, err := NewSelfSignedCAKeyPair("user-ca", KeyParams{Algorithm: KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	caSecret := &corev1.Secret{Data: certToMap(ca)}
+	caSecret.Name = "user-ca"
+	caSecret.Namespace = ns
+	unmanaged := &corev1.Secret{Type: corev1.SecretTypeOpaque}
+	unmanaged.Name = "user-tls"
+	unmanaged.Namespace = ns
+	unmanaged.UID = "user-tls-uid"
+	c := fake.NewFakeClientWithScheme(scheme, caSecret, unmanaged)
+	testee := NewCertManager(c, scheme, record.NewFakeRecorder(10), types.NamespacedName{Name: "root-ca", Namespace: ns})
+	dnsNames := []string{"registry.example.org"}
+	params := KeyParams{Algorithm: KeyAlgorithmECDSAP256}
+	key := types.NamespacedName{Name: unmanaged.Name, Namespace: ns}
+
+	// Unmanaged Secret
+	intermediate, err := testee.IntermediateCA(types.NamespacedName{Name: caSecret.Name, Namespace: ns})
+	require.NoError(t, err, "IntermediateCA")
+	_, err = testee.RenewServerCertSecret(key, nil, nil, dnsNames, intermediate, params)
+	require.Error(t, err, "renew unmanaged secret")
+
+	// Adopted Secret signed by intermediate CA
+	require.NoError(t, testee.AdoptSecret(key), "AdoptSecret")
+	cert, err := testee.RenewServerCertSecret(key, nil, nil, dnsNames, intermediate, params)
+	require.NoError(t, err, "renew adopted secret")
+	require.True(t, cert.SignedBy(ca), "signed by intermediate CA")
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(context.TODO(), key, secret))
+	require.Equal(t, corev1.SecretTypeOpaque, secret.Type, "adopted secret type")
+	require.Equal(t, string(ca.CertPEM()), string(secret.Data[secretKeyCACrt]), "ca.crt")
+	require.True(t, bytes.HasSuffix(secret.Data[secretKeyTLSCrt], ca.CertPEM()), "tls.crt should contain the CA chain")
+}
+
+func TestRenewAdoptedServerCertSecret(t *testing.T) {
+	ns := "myns"
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	foreignCA, err := NewSelfSignedCAKeyPair("foreign-ca", KeyParams{Algorithm: KeyAlgorithmECDSAP384})
+	require.NoError(t, err)
+	dnsNames := []string{"registry.example.org"}
+	foreignCert, err := NewServerKeyPair(dnsNames, foreignCA, KeyParams{Algorithm: KeyAlgorithmECDSAP384})
+	require.NoError(t, err)
+	secret := &corev1.Secret{Type: corev1.SecretTypeTLS, Data: certToMap(foreignCert)}
+	secret.Name = "user-tls"
+	secret.Namespace = ns
+	c := fake.NewFakeClientWithScheme(scheme, secret)
+	testee := NewCertManager(c, scheme, record.NewFakeRecorder(10), types.NamespacedName{Name: "root-ca", Namespace: ns})
+	ca, err := NewSelfSignedCAKeyPair("root-ca", KeyParams{Algorithm: KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	params := KeyParams{Algorithm: KeyAlgorithmECDSAP256}
+	key := types.NamespacedName{Name: secret.Name, Namespace: ns}
+	require.NoError(t, testee.AdoptSecret(key), "AdoptSecret")
+
+	// Certificate issued by another CA with other key parameters
+	cert, err := testee.RenewAdoptedServerCertSecret(key, nil, dnsNames, ca, params)
+	require.NoError(t, err, "renew adopted secret")
+	require.True(t, cert.SignedBy(foreignCA), "should keep the certificate signed by another CA")
+	require.NoError(t, c.Get(context.TODO(), key, secret))
+	require.Equal(t, string(foreignCert.CACertPEM()), string(secret.Data[secretKeyCACrt]), "ca.crt should not be replaced")
+
+	// Certificate that is not valid for all DNS names
+	dnsNames = append(dnsNames, "registry.other.example.org")
+	cert, err = testee.RenewAdoptedServerCertSecret(key, nil, dnsNames, ca, params)
+	require.NoError(t, err, "renew adopted secret with additional DNS name")
+	require.True(t, cert.SignedBy(ca), "should renew the certificate")
+	require.Empty(t, cert.MissingDNSNames(dnsNames), "missing DNS names")
+	require.NoError(t, c.Get(context.TODO(), key, secret))
+	require.Equal(t, string(ca.CACertPEM()), string(secret.Data[secretKeyCACrt]), "ca.crt")
+}
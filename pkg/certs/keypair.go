@@ -2,12 +2,17 @@ package certs
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"math/big"
 	"time"
 )
@@ -15,6 +20,8 @@ import (
 const (
 	pemTypeCertificate   = "CERTIFICATE"
 	pemTypeRSAPrivateKey = "RSA PRIVATE KEY"
+	pemTypeECPrivateKey  = "EC PRIVATE KEY"
+	pemTypePrivateKey    = "PRIVATE KEY"
 )
 
 var (
@@ -27,27 +34,47 @@ type KeyPair struct {
 	caCertPEM     []byte
 	signedCertPEM []byte
 	x509Cert      *x509.Certificate
-	rsaKey        *rsa.PrivateKey
+	key           crypto.Signer
+	renewBefore   time.Duration
+	// intermediate is true for a CA whose certificate is appended to the certificates it signs
+	intermediate bool
 }
 
 func X509KeyPair(keyPEM, certPEM, caCertPEM []byte) (*KeyPair, error) {
-	pb, _ := pem.Decode(keyPEM)
-	if pb.Type != pemTypeRSAPrivateKey {
-		return nil, errors.New("unexpected PEM type " + pb.Type + " - expected " + pemTypeRSAPrivateKey)
-	}
-	rsaKey, err := x509.ParsePKCS1PrivateKey(pb.Bytes)
+	key, err := parsePrivateKeyPEM(keyPEM)
 	if err != nil {
 		return nil, err
 	}
-	pb, _ = pem.Decode(certPEM)
-	if pb.Type != pemTypeCertificate {
-		return nil, errors.New("unexpected PEM type " + pb.Type + " - expected " + pemTypeCertificate)
-	}
-	cert, err := x509.ParseCertificate(pb.Bytes)
+	cert, err := parseCertificatePEM(certPEM)
 	if err != nil {
 		return nil, err
 	}
-	return &KeyPair{rsaKey: rsaKey, x509Cert: cert, signedCertPEM: certPEM, caCertPEM: caCertPEM}, nil
+	return &KeyPair{key: key, x509Cert: cert, signedCertPEM: certPEM, caCertPEM: caCertPEM}, nil
+}
+
+func parsePrivateKeyPEM(keyPEM []byte) (crypto.Signer, error) {
+	pb, _ := pem.Decode(keyPEM)
+	if pb == nil {
+		return nil, errors.New("no PEM encoded private key provided")
+	}
+	switch pb.Type {
+	case pemTypeRSAPrivateKey:
+		return x509.ParsePKCS1PrivateKey(pb.Bytes)
+	case pemTypeECPrivateKey:
+		return x509.ParseECPrivateKey(pb.Bytes)
+	case pemTypePrivateKey:
+		key, err := x509.ParsePKCS8PrivateKey(pb.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, errors.New("unexpected PEM type " + pb.Type + " - expected private key")
+	}
 }
 
 func (p *KeyPair) CACertPEM() []byte {
@@ -58,15 +85,49 @@ func (p *KeyPair) CertPEM() []byte {
 	return p.signedCertPEM
 }
 
+// KeyPEM returns the PEM encoded private key.
+// RSA and ECDSA keys are encoded as PKCS#1 and SEC 1 respectively since docker_auth cannot read them as PKCS#8.
 func (p *KeyPair) KeyPEM() []byte {
+	var block *pem.Block
+	switch key := p.key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: pemTypeRSAPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			panic(err)
+		}
+		block = &pem.Block{Type: pemTypeECPrivateKey, Bytes: b}
+	default:
+		b, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			panic(err)
+		}
+		block = &pem.Block{Type: pemTypePrivateKey, Bytes: b}
+	}
 	privKeyPEM := new(bytes.Buffer)
-	pem.Encode(privKeyPEM, &pem.Block{
-		Type:  pemTypeRSAPrivateKey,
-		Bytes: x509.MarshalPKCS1PrivateKey(p.rsaKey),
-	})
+	pem.Encode(privKeyPEM, block)
 	return privKeyPEM.Bytes()
 }
 
+// Algorithm returns the private key's algorithm
+func (p *KeyPair) Algorithm() KeyAlgorithm {
+	switch key := p.key.(type) {
+	case *rsa.PrivateKey:
+		return KeyAlgorithmRSA
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return KeyAlgorithmECDSAP256
+		case elliptic.P384():
+			return KeyAlgorithmECDSAP384
+		}
+	case ed25519.PrivateKey:
+		return KeyAlgorithmEd25519
+	}
+	return ""
+}
+
 func (p *KeyPair) DNSNames() []string {
 	return p.x509Cert.DNSNames
 }
@@ -75,9 +136,56 @@ func (p *KeyPair) IsCA() bool {
 	return p.x509Cert.IsCA
 }
 
+// MissingDNSNames returns the provided names the certificate is not valid for
+func (p *KeyPair) MissingDNSNames(names []string) (missing []string) {
+	for _, name := range names {
+		if p.x509Cert.VerifyHostname(name) != nil {
+			missing = append(missing, name)
+		}
+	}
+	return
+}
+
+// SignedBy returns true if the certificate has been signed by the given CA
+func (p *KeyPair) SignedBy(ca *KeyPair) bool {
+	cert, err := parseCertificatePEM(p.signedCertPEM)
+	if err != nil {
+		return false
+	}
+	caCert, err := parseCertificatePEM(ca.signedCertPEM)
+	if err != nil {
+		return false
+	}
+	return cert.CheckSignatureFrom(caCert) == nil
+}
+
+func parseCertificatePEM(certPEM []byte) (*x509.Certificate, error) {
+	pb, _ := pem.Decode(certPEM)
+	if pb == nil || pb.Type != pemTypeCertificate {
+		return nil, errors.New("no PEM encoded certificate provided")
+	}
+	return x509.ParseCertificate(pb.Bytes)
+}
+
+func (p *KeyPair) NotBefore() time.Time {
+	return p.x509Cert.NotBefore
+}
+
+// Issuer returns the certificate issuer's distinguished name
+func (p *KeyPair) Issuer() string {
+	return p.x509Cert.Issuer.String()
+}
+
+func (p *KeyPair) NotAfter() time.Time {
+	return p.x509Cert.NotAfter
+}
+
 func (p *KeyPair) NextRenewal() time.Time {
 	cert := p.x509Cert
 	ttl := cert.NotAfter.Sub(cert.NotBefore)
+	if p.renewBefore > 0 && p.renewBefore < ttl {
+		return cert.NotAfter.Add(-p.renewBefore)
+	}
 	return cert.NotAfter.Add(ttl / -4)
 }
 
@@ -85,7 +193,8 @@ func (p *KeyPair) NeedsRenewal() bool {
 	return timeNow().After(p.NextRenewal())
 }
 
-func NewSelfSignedCAKeyPair(commonName string) (*KeyPair, error) {
+func NewSelfSignedCAKeyPair(commonName string, params KeyParams) (*KeyPair, error) {
+	params = params.WithDefaults(defaultCAKeyParams())
 	now := timeNow()
 	ca := &x509.Certificate{
 		SerialNumber: big.NewInt(now.Unix()),
@@ -94,19 +203,20 @@ func NewSelfSignedCAKeyPair(commonName string) (*KeyPair, error) {
 			CommonName:   commonName,
 		},
 		NotBefore:             now,
-		NotAfter:              now.Add(caTTL),
+		NotAfter:              now.Add(params.Validity),
 		IsCA:                  true,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		BasicConstraintsValid: true,
 	}
-	return genKeyPair(ca, 4096, nil)
+	return genKeyPair(ca, params, nil)
 }
 
-func NewServerKeyPair(dnsNames []string, ca *KeyPair) (*KeyPair, error) {
+func NewServerKeyPair(dnsNames []string, ca *KeyPair, params KeyParams) (*KeyPair, error) {
 	if len(dnsNames) == 0 {
 		return nil, errors.New("gen server key pair: no dns names provided")
 	}
+	params = params.WithDefaults(defaultServerKeyParams())
 	now := timeNow()
 	cert := &x509.Certificate{
 		SerialNumber: big.NewInt(now.Unix()),
@@ -115,16 +225,19 @@ func NewServerKeyPair(dnsNames []string, ca *KeyPair) (*KeyPair, error) {
 			CommonName: dnsNames[0],
 		},
 		NotBefore:             now,
-		NotAfter:              now.Add(certTTL),
+		NotAfter:              now.Add(params.Validity),
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:              x509.KeyUsageDigitalSignature,
 		BasicConstraintsValid: true,
 	}
-	return genKeyPair(cert, 2048, ca)
+	return genKeyPair(cert, params, ca)
 }
 
-func genKeyPair(cert *x509.Certificate, bits int, ca *KeyPair) (*KeyPair, error) {
-	privKey, err := rsa.GenerateKey(rand.Reader, bits)
+func genKeyPair(cert *x509.Certificate, params KeyParams, ca *KeyPair) (*KeyPair, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	privKey, err := generateKey(params)
 	if err != nil {
 		return nil, err
 	}
@@ -133,10 +246,10 @@ func genKeyPair(cert *x509.Certificate, bits int, ca *KeyPair) (*KeyPair, error)
 	var caCertPEM []byte
 	if ca != nil {
 		caCert = ca.x509Cert
-		signKey = ca.rsaKey
+		signKey = ca.key
 		caCertPEM = ca.CertPEM()
 	}
-	certBytes, err := x509.CreateCertificate(rand.Reader, cert, caCert, &privKey.PublicKey, signKey)
+	certBytes, err := x509.CreateCertificate(rand.Reader, cert, caCert, privKey.Public(), signKey)
 	if err != nil {
 		return nil, err
 	}
@@ -147,9 +260,12 @@ func genKeyPair(cert *x509.Certificate, bits int, ca *KeyPair) (*KeyPair, error)
 	})
 	if ca == nil {
 		caCertPEM = certPEM.Bytes()
+	} else if ca.intermediate {
+		certPEM.Write(ca.CertPEM())
 	}
 	return &KeyPair{
-		rsaKey:        privKey,
+		key:           privKey,
+		renewBefore:   params.RenewBefore,
 		x509Cert:      cert,
 		signedCertPEM: certPEM.Bytes(),
 		caCertPEM:     caCertPEM,
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"regexp"
 	"testing"
 	"time"
@@ -20,7 +21,7 @@ func TestCertGen(t *testing.T) {
 
 	startTime := time.Now()
 	mockTimeNow(startTime)
-	ca, err := NewSelfSignedCAKeyPair("registry.operator.fake.root")
+	ca, err := NewSelfSignedCAKeyPair("registry.operator.fake.root", KeyParams{})
 	require.NoError(t, err, "NewSelfSignedCA")
 	require.True(t, startTime.Add(8*time.Second).Before(ca.x509Cert.NotAfter) && startTime.Add(ttl).Add(time.Second).After(ca.x509Cert.NotAfter), "ca.notAfter")
 	require.False(t, ca.NeedsRenewal(), "CA shouldn't need renewal after initialization")
@@ -34,7 +35,7 @@ func TestCertGen(t *testing.T) {
 	_, err = tls.X509KeyPair(ca.CertPEM(), ca.KeyPEM())
 	require.NoError(t, err, "tls.X509KeyPair")
 
-	cert, err := NewServerKeyPair([]string{"localhost"}, ca)
+	cert, err := NewServerKeyPair([]string{"localhost"}, ca, KeyParams{})
 	require.NoError(t, err, "NewServerKeyPair")
 	mockTimeNow(startTime)
 	require.False(t, cert.NeedsRenewal(), "cert needs renewal after initialization")
@@ -77,3 +78,36 @@ func TestCertGen(t *testing.T) {
 func mockTimeNow(now time.Time) {
 	timeNow = func() time.Time { return now }
 }
+
+func TestKeyAlgorithms(t *testing.T) {
+	mockTimeNow(time.Now())
+	defer func() { timeNow = time.Now }()
+	for _, alg := range []KeyAlgorithm{KeyAlgorithmRSA, KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384, KeyAlgorithmEd25519} {
+		params := KeyParams{Algorithm: alg, Validity: 10 * time.Hour, RenewBefore: time.Hour}
+		ca, err := NewSelfSignedCAKeyPair("registry.operator.fake.root", params)
+		require.NoError(t, err, "%s: NewSelfSignedCAKeyPair", alg)
+		cert, err := NewServerKeyPair([]string{"localhost"}, ca, params)
+		require.NoError(t, err, "%s: NewServerKeyPair", alg)
+		require.True(t, cert.SignedBy(ca), "%s: SignedBy", alg)
+		parsed, err := X509KeyPair(cert.KeyPEM(), cert.CertPEM(), cert.CACertPEM())
+		require.NoError(t, err, "%s: X509KeyPair", alg)
+		require.Equal(t, alg, parsed.Algorithm(), "parsed algorithm")
+		require.True(t, params.WithDefaults(defaultServerKeyParams()).matches(parsed), "%s: params should match", alg)
+		_, err = tls.X509KeyPair(parsed.CertPEM(), parsed.KeyPEM())
+		require.NoError(t, err, "%s: tls.X509KeyPair", alg)
+		require.Equal(t, cert.NotAfter().Add(-time.Hour), cert.NextRenewal(), "%s: NextRenewal", alg)
+		_, err = JWTAlgorithm(alg)
+		require.Equal(t, alg == KeyAlgorithmEd25519, err != nil, "%s: JWTAlgorithm should fail for Ed25519 only", alg)
+	}
+	require.Error(t, KeyParams{Algorithm: KeyAlgorithmRSA, RSAKeySize: 1024, Validity: time.Hour}.Validate(), "RSA key size 1024")
+	require.Error(t, KeyParams{Algorithm: KeyAlgorithmECDSAP256, Validity: time.Hour, RenewBefore: time.Hour}.Validate(), "renewBefore >= validity")
+}
+
+func TestTokenCAKeyParamsFromEnv(t *testing.T) {
+	envKey := EnvPrefixTokenCA + envSuffixKeyAlgorithm
+	defer os.Unsetenv(envKey)
+	os.Setenv(envKey, string(KeyAlgorithmECDSAP256))
+	require.Equal(t, KeyAlgorithmECDSAP256, tokenCAKeyParamsFromEnv().Algorithm, "algorithm")
+	os.Setenv(envKey, string(KeyAlgorithmEd25519))
+	require.Panics(t, func() { tokenCAKeyParamsFromEnv() }, "Ed25519 should be rejected")
+}
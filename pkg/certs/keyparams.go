@@ -0,0 +1,171 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// KeyAlgorithm specifies the type of a private key
+type KeyAlgorithm string
+
+const (
+	KeyAlgorithmRSA       = KeyAlgorithm("RSA")
+	KeyAlgorithmECDSAP256 = KeyAlgorithm("ECDSA-P256")
+	KeyAlgorithmECDSAP384 = KeyAlgorithm("ECDSA-P384")
+	KeyAlgorithmEd25519   = KeyAlgorithm("Ed25519")
+
+	// EnvPrefixRootCA, EnvPrefixTokenCA and EnvPrefixServerCert prefix the env vars
+	// that specify the default key parameters of the corresponding certificates:
+	// <PREFIX>_KEY_ALGORITHM, <PREFIX>_RSA_KEY_SIZE, <PREFIX>_VALIDITY and <PREFIX>_RENEW_BEFORE
+	EnvPrefixRootCA       = "OPERATOR_ROOT_CA"
+	EnvPrefixTokenCA      = "OPERATOR_TOKEN_CA"
+	EnvPrefixServerCert   = "OPERATOR_TLS_CERT"
+	envSuffixKeyAlgorithm = "_KEY_ALGORITHM"
+	envSuffixRSAKeySize   = "_RSA_KEY_SIZE"
+	envSuffixValidity     = "_VALIDITY"
+	envSuffixRenewBefore  = "_RENEW_BEFORE"
+)
+
+// KeyParams specifies how a key pair is generated.
+// Zero values are replaced with defaults.
+type KeyParams struct {
+	Algorithm  KeyAlgorithm
+	RSAKeySize int
+	Validity   time.Duration
+	// RenewBefore specifies how long before expiry a certificate is renewed (default: a quarter of its validity)
+	RenewBefore time.Duration
+}
+
+func defaultCAKeyParams() KeyParams {
+	return KeyParams{Algorithm: KeyAlgorithmRSA, RSAKeySize: 4096, Validity: caTTL}
+}
+
+func defaultServerKeyParams() KeyParams {
+	return KeyParams{Algorithm: KeyAlgorithmRSA, RSAKeySize: 2048, Validity: certTTL}
+}
+
+// WithDefaults returns a copy of the parameters with zero values replaced by the provided defaults
+func (p KeyParams) WithDefaults(d KeyParams) KeyParams {
+	if p.Algorithm == "" {
+		p.Algorithm = d.Algorithm
+		if p.RSAKeySize == 0 {
+			p.RSAKeySize = d.RSAKeySize
+		}
+	}
+	if p.Algorithm == KeyAlgorithmRSA && p.RSAKeySize == 0 {
+		p.RSAKeySize = 2048
+		if d.Algorithm == KeyAlgorithmRSA && d.RSAKeySize != 0 {
+			p.RSAKeySize = d.RSAKeySize
+		}
+	}
+	if p.Validity == 0 {
+		p.Validity = d.Validity
+	}
+	if p.RenewBefore == 0 {
+		p.RenewBefore = d.RenewBefore
+	}
+	return p
+}
+
+// Validate returns an error if the parameters are not supported
+func (p KeyParams) Validate() error {
+	switch p.Algorithm {
+	case KeyAlgorithmRSA:
+		if p.RSAKeySize < 2048 || p.RSAKeySize > 8192 {
+			return fmt.Errorf("unsupported RSA key size %d, must be between 2048 and 8192", p.RSAKeySize)
+		}
+	case KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384, KeyAlgorithmEd25519:
+	default:
+		return fmt.Errorf("unsupported key algorithm %q", p.Algorithm)
+	}
+	if p.Validity <= 0 {
+		return errors.New("certificate validity must be positive")
+	}
+	if p.RenewBefore < 0 || p.RenewBefore >= p.Validity {
+		return fmt.Errorf("renewBefore (%s) must be positive and less than the validity (%s)", p.RenewBefore, p.Validity)
+	}
+	return nil
+}
+
+// matches returns true if the key pair's private key has been generated with the parameters
+func (p KeyParams) matches(cert *KeyPair) bool {
+	if cert.Algorithm() != p.Algorithm {
+		return false
+	}
+	if rsaKey, ok := cert.key.(*rsa.PrivateKey); ok {
+		return rsaKey.N.BitLen() == p.RSAKeySize
+	}
+	return true
+}
+
+// JWTAlgorithm returns the JWT signature algorithm docker token authentication uses with the given key type.
+// Docker token authentication supports RSA and ECDSA keys only.
+func JWTAlgorithm(alg KeyAlgorithm) (string, error) {
+	switch alg {
+	case KeyAlgorithmRSA:
+		return "RS256", nil
+	case KeyAlgorithmECDSAP256:
+		return "ES256", nil
+	case KeyAlgorithmECDSAP384:
+		return "ES384", nil
+	default:
+		return "", fmt.Errorf("key algorithm %q is not supported by docker token authentication", alg)
+	}
+}
+
+func generateKey(p KeyParams) (crypto.Signer, error) {
+	switch p.Algorithm {
+	case KeyAlgorithmRSA:
+		return rsa.GenerateKey(rand.Reader, p.RSAKeySize)
+	case KeyAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyAlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", p.Algorithm)
+	}
+}
+
+// tokenCAKeyParamsFromEnv returns the auth token CA's key parameters
+// and rejects key algorithms docker token authentication does not support at startup.
+func tokenCAKeyParamsFromEnv() KeyParams {
+	p := keyParamsFromEnv(EnvPrefixTokenCA, defaultCAKeyParams())
+	if _, err := JWTAlgorithm(p.Algorithm); err != nil {
+		panic(fmt.Sprintf("Unsupported value in env var %s: %v", EnvPrefixTokenCA+envSuffixKeyAlgorithm, err))
+	}
+	return p
+}
+
+func keyParamsFromEnv(prefix string, defaults KeyParams) KeyParams {
+	p := KeyParams{Algorithm: KeyAlgorithm(os.Getenv(prefix + envSuffixKeyAlgorithm))}
+	var err error
+	if v := os.Getenv(prefix + envSuffixRSAKeySize); v != "" {
+		if p.RSAKeySize, err = strconv.Atoi(v); err != nil {
+			panic(fmt.Sprintf("Unsupported value in env var %s: %v", prefix+envSuffixRSAKeySize, err))
+		}
+	}
+	for suffix, d := range map[string]*time.Duration{envSuffixValidity: &p.Validity, envSuffixRenewBefore: &p.RenewBefore} {
+		if v := os.Getenv(prefix + suffix); v != "" {
+			if *d, err = time.ParseDuration(v); err != nil {
+				panic(fmt.Sprintf("Unsupported value in env var %s: %v", prefix+suffix, err))
+			}
+		}
+	}
+	p = p.WithDefaults(defaults)
+	if err = p.Validate(); err != nil {
+		panic(fmt.Sprintf("Unsupported key parameters in env vars %s_*: %v", prefix, err))
+	}
+	return p
+}
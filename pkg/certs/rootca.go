@@ -0,0 +1,182 @@
+package certs
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// EnvCARotationOverlap specifies how long the previous root CA is trusted after a rotation
+	EnvCARotationOverlap         = "OPERATOR_CA_ROTATION_OVERLAP"
+	secretKeyPreviousCACrt       = "previous-ca.crt"
+	secretKeyPreviousCAKey       = "previous-ca.key"
+	annotationRotationStart      = "registry.mgoltzsche.github.com/ca-rotation-start"
+	annotationRotationOverlapEnd = "registry.mgoltzsche.github.com/ca-rotation-overlap-end"
+	defaultCARotationOverlap     = 30 * 24 * time.Hour
+)
+
+// RootCARotation describes a root CA rotation in progress
+type RootCARotation struct {
+	StartTime time.Time
+	// SignerSwitchTime is the time after which the new root CA signs server certificates
+	SignerSwitchTime time.Time
+	OverlapEndTime   time.Time
+}
+
+func caRotationOverlap() time.Duration {
+	overlapStr := os.Getenv(EnvCARotationOverlap)
+	if overlapStr == "" {
+		return defaultCARotationOverlap
+	}
+	overlap, err := time.ParseDuration(overlapStr)
+	if err == nil && overlap < 0 {
+		err = fmt.Errorf("overlap < 0")
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Unsupported value in env var %s: %v", EnvCARotationOverlap, err))
+	}
+	return overlap
+}
+
+// RenewRootCACertSecret creates the root CA or rotates it in stages when it needs renewal:
+// The trust bundle (ca.crt) contains both the new and the previous root CA until the overlap period has passed.
+// The previous root CA keeps signing server certificates during the first half of the overlap period
+// while the new trust bundle propagates to the clients.
+// When the root CA is rotated again within the overlap period the older root CAs remain trusted.
+func (r *CertManager) RenewRootCACertSecret() (*KeyPair, error) {
+	key := r.rootCASecretName
+	secret := &corev1.Secret{}
+	secret.Name = key.Name
+	secret.Namespace = key.Namespace
+	var cert *KeyPair
+	var eventReason, eventMsg string
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, secret, func() (e error) {
+		cert = certFromMap(secret.Data)
+		if cert != nil {
+			cert.renewBefore = r.rootCAParams.RenewBefore
+		}
+		if secret.UID != "" && (secret.Labels == nil || secret.Labels[labelManagedBy] != operatorName) {
+			if cert == nil || cert.NeedsRenewal() {
+				return ErrUnmanagedInvalidSecretExists
+			}
+			return ErrUnmanagedValidSecretExists
+		}
+		secret.Labels = map[string]string{labelManagedBy: operatorName}
+		for k, v := range rootCASecretLabels {
+			secret.Labels[k] = v
+		}
+		secret.Type = corev1.SecretTypeTLS
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		rotation := rotationFromAnnotations(secret.Annotations)
+		now := timeNow()
+		switch {
+		case cert == nil || !cert.IsCA():
+			// Create initial root CA
+			if cert, e = NewSelfSignedCAKeyPair(rootCACommonName, r.rootCAParams); e != nil {
+				return
+			}
+			secret.Data = certToMap(cert)
+			delete(secret.Annotations, annotationRotationStart)
+			delete(secret.Annotations, annotationRotationOverlapEnd)
+			eventReason, eventMsg = registryapi.EventReasonCertificateRenewed, "Created root CA"
+		case cert.NeedsRenewal() || !r.rootCAParams.matches(cert):
+			// Start rotation: keep signing with the previous CA, trust both CAs
+			previousKeyPEM := cert.KeyPEM()
+			previousCertPEM := cert.CertPEM()
+			if rotation != nil && now.Before(rotation.OverlapEndTime) {
+				// Keep trusting the CAs of the rotation in progress
+				previousCertPEM = MergeCertificates([][]byte{previousCertPEM, secret.Data[secretKeyPreviousCACrt]})
+			}
+			if cert, e = NewSelfSignedCAKeyPair(rootCACommonName, r.rootCAParams); e != nil {
+				return
+			}
+			cert.caCertPEM = MergeCertificates([][]byte{cert.CertPEM(), previousCertPEM})
+			overlapEnd := now.Add(r.rotationOverlap)
+			secret.Data = certToMap(cert)
+			secret.Data[secretKeyPreviousCACrt] = previousCertPEM
+			secret.Data[secretKeyPreviousCAKey] = previousKeyPEM
+			secret.Annotations[annotationRotationStart] = now.Format(time.RFC3339)
+			secret.Annotations[annotationRotationOverlapEnd] = overlapEnd.Format(time.RFC3339)
+			eventReason = registryapi.EventReasonCARotationStarted
+			eventMsg = fmt.Sprintf("Rotated root CA, trusting the previous root CA until %s", overlapEnd.Format(time.RFC3339))
+		case rotation != nil && !now.Before(rotation.OverlapEndTime):
+			// Finish rotation: remove previous CA from trust bundle
+			cert.caCertPEM = cert.CertPEM()
+			secret.Data = certToMap(cert)
+			delete(secret.Annotations, annotationRotationStart)
+			delete(secret.Annotations, annotationRotationOverlapEnd)
+			eventReason, eventMsg = registryapi.EventReasonCARotationFinished, "Removed previous root CA from trust bundle"
+		case rotation != nil && !now.Before(rotation.SignerSwitchTime) && secret.Data[secretKeyPreviousCAKey] != nil:
+			// Switch signer: the new trust bundle has propagated, sign server certificates with the new CA
+			delete(secret.Data, secretKeyPreviousCAKey)
+			eventReason, eventMsg = registryapi.EventReasonCASignerSwitched, "Signing server certificates with the new root CA"
+		}
+		return
+	})
+	if err != nil {
+		if errors.Is(err, ErrUnmanagedValidSecretExists) && cert != nil {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("upsert root CA secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	metrics.CertificateExpiry.Set(cert.NotAfter(), key.Namespace, key.Name)
+	if eventReason != "" {
+		r.recorder.Event(secret, corev1.EventTypeNormal, eventReason, eventMsg)
+	}
+	return cert, nil
+}
+
+// SigningCA returns the CA that signs server certificates:
+// the previous root CA during the first half of a rotation's overlap period, the current root CA otherwise.
+func (r *CertManager) SigningCA() (*KeyPair, error) {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), r.rootCASecretName, secret); err != nil {
+		return nil, err
+	}
+	if secret.Data[secretKeyPreviousCAKey] != nil {
+		certPEM := firstCertificatePEM(secret.Data[secretKeyPreviousCACrt])
+		return X509KeyPair(secret.Data[secretKeyPreviousCAKey], certPEM, secret.Data[secretKeyCACrt])
+	}
+	cert := certFromMap(secret.Data)
+	if cert == nil {
+		return nil, fmt.Errorf("invalid certificate in Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	return cert, nil
+}
+
+// RootCARotation returns the root CA rotation that is in progress or nil
+func (r *CertManager) RootCARotation() (*RootCARotation, error) {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), r.rootCASecretName, secret); err != nil {
+		return nil, err
+	}
+	return rotationFromAnnotations(secret.Annotations), nil
+}
+
+func rotationFromAnnotations(a map[string]string) *RootCARotation {
+	start, err1 := time.Parse(time.RFC3339, a[annotationRotationStart])
+	end, err2 := time.Parse(time.RFC3339, a[annotationRotationOverlapEnd])
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	return &RootCARotation{StartTime: start, SignerSwitchTime: start.Add(end.Sub(start) / 2), OverlapEndTime: end}
+}
+
+func firstCertificatePEM(b []byte) []byte {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil
+	}
+	return pem.EncodeToMemory(block)
+}
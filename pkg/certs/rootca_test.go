@@ -0,0 +1,122 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRootCARotation(t *testing.T) {
+	caTTL = 40 * time.Hour
+	startTime := time.Now()
+	mockTimeNow(startTime)
+	defer func() { timeNow = time.Now }()
+	key := types.NamespacedName{Name: "root-ca", Namespace: "myns"}
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	c := fake.NewFakeClientWithScheme(scheme)
+	testee := NewCertManager(c, scheme, record.NewFakeRecorder(10), key)
+	testee.rotationOverlap = 5 * time.Hour
+	loadSecret := func() *corev1.Secret {
+		secret := &corev1.Secret{}
+		require.NoError(t, c.Get(context.TODO(), key, secret))
+		return secret
+	}
+
+	// Create initial root CA
+	ca1, err := testee.RenewRootCACertSecret()
+	require.NoError(t, err, "create root CA")
+	secret := loadSecret()
+	require.Equal(t, string(ca1.CertPEM()), string(secret.Data[secretKeyCACrt]), "initial ca.crt")
+	server, err := NewServerKeyPair([]string{"registry.example.org"}, ca1, KeyParams{})
+	require.NoError(t, err)
+
+	// Rotate: previous CA keeps signing, both CAs are trusted
+	mockTimeNow(startTime.Add(31 * time.Hour))
+	ca2, err := testee.RenewRootCACertSecret()
+	require.NoError(t, err, "rotate root CA")
+	require.NotEqual(t, string(ca1.CertPEM()), string(ca2.CertPEM()), "root CA should have been rotated")
+	secret = loadSecret()
+	bundle := secret.Data[secretKeyCACrt]
+	require.True(t, bytes.Contains(bundle, ca1.CertPEM()) && bytes.Contains(bundle, ca2.CertPEM()), "ca.crt should contain previous and new CA")
+	require.Equal(t, string(ca1.CertPEM()), string(secret.Data[secretKeyPreviousCACrt]), "previous-ca.crt")
+	rotation, err := testee.RootCARotation()
+	require.NoError(t, err)
+	require.NotNil(t, rotation, "rotation in progress")
+	loaded, err := testee.RootCACert()
+	require.NoError(t, err)
+	require.Equal(t, string(bundle), string(loaded.CACertPEM()), "loaded root CA's ca.crt")
+	require.True(t, server.SignedBy(ca1), "server cert should be signed by previous CA")
+	signer, err := testee.SigningCA()
+	require.NoError(t, err)
+	require.Equal(t, string(ca1.CertPEM()), string(signer.CertPEM()), "signing CA during the first half of the overlap period")
+	require.Equal(t, string(bundle), string(signer.CACertPEM()), "signing CA's ca.crt")
+	require.False(t, condServerCert(server.DNSNames(), signer)(server), "server cert signed by previous CA should not be renewed before the trust bundle propagated")
+
+	// Switch signer within the second half of the overlap period
+	mockTimeNow(startTime.Add(34 * time.Hour))
+	_, err = testee.RenewRootCACertSecret()
+	require.NoError(t, err)
+	secret = loadSecret()
+	require.Equal(t, string(bundle), string(secret.Data[secretKeyCACrt]), "ca.crt within overlap period")
+	require.Nil(t, secret.Data[secretKeyPreviousCAKey], "previous-ca.key after signer switch")
+	signer, err = testee.SigningCA()
+	require.NoError(t, err)
+	require.Equal(t, string(ca2.CertPEM()), string(signer.CertPEM()), "signing CA during the second half of the overlap period")
+	require.True(t, condServerCert(server.DNSNames(), signer)(server), "server cert signed by previous CA should be renewed after signer switch")
+
+	// Finish rotation: previous CA is not trusted anymore
+	mockTimeNow(startTime.Add(36 * time.Hour))
+	ca3, err := testee.RenewRootCACertSecret()
+	require.NoError(t, err)
+	require.Equal(t, string(ca2.CertPEM()), string(ca3.CertPEM()), "root CA after overlap")
+	secret = loadSecret()
+	require.Equal(t, string(ca2.CertPEM()), string(secret.Data[secretKeyCACrt]), "ca.crt after overlap")
+	require.Nil(t, secret.Data[secretKeyPreviousCACrt], "previous-ca.crt after overlap")
+	rotation, err = testee.RootCARotation()
+	require.NoError(t, err)
+	require.Nil(t, rotation, "rotation after overlap")
+}
+
+func TestRootCARotationWithinOverlap(t *testing.T) {
+	caTTL = 40 * time.Hour
+	startTime := time.Now()
+	mockTimeNow(startTime)
+	defer func() { timeNow = time.Now }()
+	key := types.NamespacedName{Name: "root-ca", Namespace: "myns"}
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	c := fake.NewFakeClientWithScheme(scheme)
+	testee := NewCertManager(c, scheme, record.NewFakeRecorder(10), key)
+	testee.rotationOverlap = 5 * time.Hour
+	testee.rootCAParams = KeyParams{Algorithm: KeyAlgorithmECDSAP256, Validity: caTTL}
+	ca1, err := testee.RenewRootCACertSecret()
+	require.NoError(t, err, "create root CA")
+
+	// Rotate twice within the overlap period
+	mockTimeNow(startTime.Add(31 * time.Hour))
+	ca2, err := testee.RenewRootCACertSecret()
+	require.NoError(t, err, "rotate root CA")
+	mockTimeNow(startTime.Add(32 * time.Hour))
+	testee.rootCAParams.Algorithm = KeyAlgorithmECDSAP384
+	ca3, err := testee.RenewRootCACertSecret()
+	require.NoError(t, err, "rotate root CA again")
+
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(context.TODO(), key, secret))
+	bundle := secret.Data[secretKeyCACrt]
+	for i, ca := range []*KeyPair{ca1, ca2, ca3} {
+		require.True(t, bytes.Contains(bundle, ca.CertPEM()), "ca.crt should contain root CA %d", i+1)
+	}
+	signer, err := testee.SigningCA()
+	require.NoError(t, err)
+	require.Equal(t, string(ca2.CertPEM()), string(signer.CertPEM()), "signing CA should be the most recent previous CA")
+}
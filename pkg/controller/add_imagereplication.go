@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagereplication"
+)
+
+func init() {
+	// AddWithNotificationsToManagerFuncs is a list of functions to create controllers that listen to registry notifications and add them to a manager.
+	AddWithNotificationsToManagerFuncs = append(AddWithNotificationsToManagerFuncs, imagereplication.Add)
+}
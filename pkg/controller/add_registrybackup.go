@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/registrybackup"
+)
+
+func init() {
+	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
+	AddToManagerFuncs = append(AddToManagerFuncs, registrybackup.Add, registrybackup.AddRestore)
+}
@@ -7,6 +7,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -31,12 +32,13 @@ func Add(mgr manager.Manager) error {
 	if err != nil {
 		return err
 	}
-	certMan := certs.NewCertManager(cl, mgr.GetScheme(), certs.RootCASecretName())
+	recorder := mgr.GetEventRecorderFor("caroot-controller")
+	certMan := certs.NewCertManager(cl, mgr.GetScheme(), recorder, certs.RootCASecretName())
 	if _, err := certMan.RenewRootCACertSecret(); err != nil {
 		return err
 	}
 
-	r := newReconciler(mgr)
+	r := newReconciler(mgr, recorder)
 
 	// Create a new controller
 	c, err := controller.New("caroot-controller", mgr, controller.Options{Reconciler: r})
@@ -55,8 +57,8 @@ type ReconcileCARootSecret struct {
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	certMan := certs.NewCertManager(mgr.GetClient(), mgr.GetScheme(), certs.RootCASecretName())
+func newReconciler(mgr manager.Manager, recorder record.EventRecorder) reconcile.Reconciler {
+	certMan := certs.NewCertManager(mgr.GetClient(), mgr.GetScheme(), recorder, certs.RootCASecretName())
 	return &ReconcileCARootSecret{
 		client:      mgr.GetClient(),
 		scheme:      mgr.GetScheme(),
@@ -74,7 +76,20 @@ func (r *ReconcileCARootSecret) Reconcile(request reconcile.Request) (reconcile.
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{RequeueAfter: cert.NextRenewal().Sub(time.Now()) + 30*time.Second}, nil
+	next := cert.NextRenewal()
+	rotation, err := r.certManager.RootCARotation()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if rotation != nil && rotation.OverlapEndTime.Before(next) {
+		// Remove the previous CA from the trust bundle once the overlap period passed
+		next = rotation.OverlapEndTime
+	}
+	if rotation != nil && rotation.SignerSwitchTime.After(time.Now()) && rotation.SignerSwitchTime.Before(next) {
+		// Sign server certificates with the new CA once the trust bundle has propagated
+		next = rotation.SignerSwitchTime
+	}
+	return reconcile.Result{RequeueAfter: next.Sub(time.Now()) + 30*time.Second}, nil
 }
 
 type secretNameFilter struct {
@@ -1,18 +1,28 @@
 package controller
 
 import (
+	"github.com/mgoltzsche/image-registry-operator/pkg/notifications"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
 var AddToManagerFuncs []func(manager.Manager) error
 
+// AddWithNotificationsToManagerFuncs is a list of functions to add Controllers
+// that listen to registry notifications to the Manager
+var AddWithNotificationsToManagerFuncs []func(manager.Manager, *notifications.Dispatcher) error
+
 // AddToManager adds all Controllers to the Manager
-func AddToManager(m manager.Manager) error {
+func AddToManager(m manager.Manager, events *notifications.Dispatcher) error {
 	for _, f := range AddToManagerFuncs {
 		if err := f(m); err != nil {
 			return err
 		}
 	}
+	for _, f := range AddWithNotificationsToManagerFuncs {
+		if err := f(m, events); err != nil {
+			return err
+		}
+	}
 	return nil
 }
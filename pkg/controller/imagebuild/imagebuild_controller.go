@@ -0,0 +1,342 @@
+package imagebuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagebuildenv"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registriesconf"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registryclient"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_imagebuild")
+
+const (
+	requeueDelayBuildEnvNotReady = 30 * time.Second
+	// maxJobNameLength is the max length of a label value since the Job's name is used as its Pods' job-name label value
+	maxJobNameLength = 63
+)
+
+// Add creates a new ImageBuild Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	r := &ReconcileImageBuild{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+
+	// Create a new controller
+	c, err := controller.New("imagebuild-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource ImageBuild
+	err = c.Watch(&source.Kind{Type: &registryapi.ImageBuild{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource Job and requeue the owner ImageBuild
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryapi.ImageBuild{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for ImageBuildEnv changes to start pending builds once their env is ready
+	return c.Watch(&source.Kind{Type: &registryapi.ImageBuildEnv{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: buildEnvToRequests(mgr.GetClient()),
+	})
+}
+
+// blank assignment to verify that ReconcileImageBuild implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileImageBuild{}
+
+// ReconcileImageBuild reconciles a ImageBuild object
+type ReconcileImageBuild struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// Reconcile reads that state of the cluster for a ImageBuild object and makes changes based on the state read
+// and what is in the ImageBuild.Spec
+func (r *ReconcileImageBuild) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling ImageBuild")
+
+	// Fetch the ImageBuild instance
+	instance := &registryapi.ImageBuild{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if isFinished(instance) {
+		return reconcile.Result{}, nil
+	}
+
+	// Start the Job once the ImageBuildEnv is ready
+	if instance.Status.JobName == "" {
+		return r.startJob(instance, reqLogger)
+	}
+
+	// Wait for the Job to finish
+	job := &batchv1.Job{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Status.JobName, Namespace: instance.Namespace}, job)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.finish(instance, registryapi.BuildPhaseFailed, registryapi.ReasonJobFailed, "job has been deleted")
+		}
+		return reconcile.Result{}, err
+	}
+	finished, failed, msg := jobFinished(job)
+	if !finished {
+		return reconcile.Result{}, nil
+	}
+	if failed {
+		return reconcile.Result{}, r.finish(instance, registryapi.BuildPhaseFailed, registryapi.ReasonJobFailed, msg)
+	}
+
+	// Record the pushed image's digest as reported by the builder.
+	// Resolve the tag only if the builder does not report it since the tag may have been overwritten in the meantime.
+	digest, err := r.reportedImageDigest(job)
+	if err == nil && digest == "" {
+		digest, err = r.imageDigest(instance)
+	}
+	if err != nil {
+		err = fmt.Errorf("resolve pushed image digest: %w", err)
+		r.setCondition(instance, corev1.ConditionFalse, registryapi.ReasonFailedSync, err.Error())
+		if e := r.client.Status().Update(context.TODO(), instance); e != nil {
+			return reconcile.Result{}, e
+		}
+		return reconcile.Result{}, err
+	}
+	instance.Status.ImageDigest = digest
+	return reconcile.Result{}, r.finish(instance, registryapi.BuildPhaseSucceeded, "", "")
+}
+
+// startJob creates the build Job
+func (r *ReconcileImageBuild) startJob(cr *registryapi.ImageBuild, reqLogger logr.Logger) (reconcile.Result, error) {
+	env, secret, err := r.loadBuildEnv(cr)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = r.updateStatus(cr, registryapi.BuildPhasePending, corev1.ConditionFalse, registryapi.ReasonBuildEnvNotReady, err.Error())
+			return reconcile.Result{RequeueAfter: requeueDelayBuildEnvNotReady}, err
+		}
+		return reconcile.Result{}, err
+	}
+	if !env.Status.Conditions.IsTrueFor(registryapi.ConditionReady) || env.Status.ObservedGeneration != env.Generation {
+		err = r.updateStatus(cr, registryapi.BuildPhasePending, corev1.ConditionFalse, registryapi.ReasonBuildEnvNotReady, fmt.Sprintf("waiting for ImageBuildEnv %s to become ready", env.Name))
+		return reconcile.Result{}, err
+	}
+	builder := builderForCR(cr, env)
+	if err = validateBuilder(cr, env, builder); err != nil {
+		return reconcile.Result{}, r.finish(cr, registryapi.BuildPhaseFailed, registryapi.ReasonInvalidSpec, err.Error())
+	}
+	job, err := newJob(&jobSpec{
+		Name:        jobNameForCR(cr),
+		Namespace:   cr.Namespace,
+		Builder:     builder,
+		Image:       cr.Spec.BuilderImage,
+		Destination: destinationForCR(cr),
+		Dockerfile:  cr.Spec.Dockerfile,
+		Context:     &cr.Spec.Context,
+		Resources:   cr.Spec.Resources,
+		SecretName:  secret.Name,
+		SecretData:  secret.Data,
+	})
+	if err != nil {
+		return reconcile.Result{}, r.finish(cr, registryapi.BuildPhaseFailed, registryapi.ReasonInvalidSpec, err.Error())
+	}
+	if err = controllerutil.SetControllerReference(cr, job, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+	reqLogger.Info("Creating Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+	if err = r.client.Create(context.TODO(), job); err != nil && !errors.IsAlreadyExists(err) {
+		return reconcile.Result{}, err
+	}
+	now := metav1.Now()
+	cr.Status.JobName = job.Name
+	cr.Status.ObservedGeneration = cr.Generation
+	cr.Status.Builder = builder
+	cr.Status.Image = destinationForCR(cr)
+	cr.Status.StartTime = &now
+	err = r.updateStatus(cr, registryapi.BuildPhaseRunning, corev1.ConditionFalse, registryapi.ReasonPending, "job running")
+	return reconcile.Result{}, err
+}
+
+// loadBuildEnv loads the ImageBuildEnv and its merged Secret
+func (r *ReconcileImageBuild) loadBuildEnv(cr *registryapi.ImageBuild) (env *registryapi.ImageBuildEnv, secret *corev1.Secret, err error) {
+	env = &registryapi.ImageBuildEnv{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: cr.Spec.BuildEnvRef.Name, Namespace: cr.Namespace}, env)
+	if err != nil {
+		return
+	}
+	secret = &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: imagebuildenv.SecretNameForCR(env), Namespace: cr.Namespace}, secret)
+	return
+}
+
+// reportedImageDigest returns the digest the Job's builder reported within its termination message
+func (r *ReconcileImageBuild) reportedImageDigest(job *batchv1.Job) (string, error) {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, client.InNamespace(job.Namespace), client.MatchingLabels{labelJobName: job.Name})
+	if err != nil {
+		return "", err
+	}
+	return reportedImageDigest(pods.Items), nil
+}
+
+// imageDigest resolves the pushed image's tag's digest using the ImageBuildEnv's credentials
+func (r *ReconcileImageBuild) imageDigest(cr *registryapi.ImageBuild) (digest string, err error) {
+	_, secret, err := r.loadBuildEnv(cr)
+	if err != nil {
+		return
+	}
+	host, repo := splitImageName(cr.Spec.Image)
+	dockerConf, err := registriesconf.ParseDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+	if err != nil {
+		return
+	}
+	var credentials registryclient.Credentials
+	for k, v := range dockerConf.Auths {
+		if registriesconf.RegistryHost(k) == host {
+			auth, e := registriesconf.ToMakisuBasicAuth(v.Auth)
+			if e != nil {
+				return "", fmt.Errorf("registry %s basic auth: %w", host, e)
+			}
+			credentials = registryclient.Credentials{Username: auth.Username, Password: auth.Password}
+			break
+		}
+	}
+	c, err := registryclient.New(host, credentials, secret.Data[registriesconf.CAFileName(host)])
+	if err != nil {
+		return
+	}
+	return c.ManifestDigest(repo, tagForCR(cr))
+}
+
+// finish writes the final status
+func (r *ReconcileImageBuild) finish(cr *registryapi.ImageBuild, phase registryapi.BuildPhase, reason status.ConditionReason, msg string) error {
+	now := metav1.Now()
+	cr.Status.CompletionTime = &now
+	ready := corev1.ConditionTrue
+	if phase == registryapi.BuildPhaseFailed {
+		ready = corev1.ConditionFalse
+	}
+	return r.updateStatus(cr, phase, ready, reason, msg)
+}
+
+func (r *ReconcileImageBuild) updateStatus(cr *registryapi.ImageBuild, phase registryapi.BuildPhase, ready corev1.ConditionStatus, reason status.ConditionReason, msg string) error {
+	changed := r.setCondition(cr, ready, reason, msg)
+	if !changed && cr.Status.Phase == phase {
+		return nil
+	}
+	cr.Status.Phase = phase
+	return r.client.Status().Update(context.TODO(), cr)
+}
+
+func (r *ReconcileImageBuild) setCondition(cr *registryapi.ImageBuild, ready corev1.ConditionStatus, reason status.ConditionReason, msg string) bool {
+	return cr.Status.Conditions.SetCondition(status.Condition{
+		Type:    registryapi.ConditionReady,
+		Status:  ready,
+		Reason:  reason,
+		Message: msg,
+	})
+}
+
+// buildEnvToRequests maps an ImageBuildEnv to the pending builds referring to it
+func buildEnvToRequests(c client.Client) handler.ToRequestsFunc {
+	return func(o handler.MapObject) (requests []reconcile.Request) {
+		l := &registryapi.ImageBuildList{}
+		if err := c.List(context.TODO(), l, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+			log.Error(err, "failed to map ImageBuildEnv to requests")
+			return nil
+		}
+		for _, b := range l.Items {
+			if b.Spec.BuildEnvRef.Name == o.Meta.GetName() && b.Status.JobName == "" && !isFinished(&b) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: b.Name, Namespace: b.Namespace}})
+			}
+		}
+		return
+	}
+}
+
+// validateBuilder returns an error if the ImageBuildEnv does not allow the ImageBuild to run the builder.
+// Privileged builders must be allowed explicitly and run the operator's builder image only.
+func validateBuilder(cr *registryapi.ImageBuild, env *registryapi.ImageBuildEnv, builder registryapi.ImageBuilder) error {
+	if !env.Spec.HasBuilder(builder) {
+		return fmt.Errorf("builder %s is not enabled within ImageBuildEnv %s", builder, env.Name)
+	}
+	if isPrivileged(builder) {
+		if !env.Spec.AllowPrivilegedBuilds {
+			return fmt.Errorf("builder %s requires a privileged container which ImageBuildEnv %s does not allow (allowPrivilegedBuilds)", builder, env.Name)
+		}
+		if cr.Spec.BuilderImage != "" {
+			return fmt.Errorf("builderImage is not supported for the privileged builder %s", builder)
+		}
+	}
+	return nil
+}
+
+// builderForCR returns the ImageBuild's builder or the ImageBuildEnv's default builder
+func builderForCR(cr *registryapi.ImageBuild, env *registryapi.ImageBuildEnv) registryapi.ImageBuilder {
+	if cr.Spec.Builder != "" {
+		return cr.Spec.Builder
+	}
+	if len(env.Spec.Builders) > 0 {
+		return env.Spec.Builders[0]
+	}
+	return registryapi.ImageBuilderMakisu
+}
+
+func isFinished(cr *registryapi.ImageBuild) bool {
+	return cr.Status.Phase == registryapi.BuildPhaseSucceeded || cr.Status.Phase == registryapi.BuildPhaseFailed
+}
+
+func destinationForCR(cr *registryapi.ImageBuild) string {
+	return cr.Spec.Image + ":" + tagForCR(cr)
+}
+
+func tagForCR(cr *registryapi.ImageBuild) string {
+	if cr.Spec.Tag != "" {
+		return cr.Spec.Tag
+	}
+	return defaultTag
+}
+
+// jobNameForCR returns the build Job's name.
+// Names that exceed the label value length are truncated and suffixed with a hash of the ImageBuild's name to keep them unique.
+func jobNameForCR(cr *registryapi.ImageBuild) string {
+	name := "imagebuild-" + cr.Name
+	if len(name) <= maxJobNameLength {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(cr.Name)))[:8]
+	return strings.TrimRight(name[:maxJobNameLength-len(hash)-1], "-.") + "-" + hash
+}
@@ -0,0 +1,288 @@
+package imagebuild
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileImageBuildJob(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		builder   registryapi.ImageBuilder
+		context   registryapi.BuildContextSpec
+		image     string
+		command   []string
+		args      []string
+		mounts    map[string]string
+		initImage string
+	}{
+		{
+			name:    "makisu-git",
+			builder: registryapi.ImageBuilderMakisu,
+			context: registryapi.BuildContextSpec{Git: &registryapi.GitContextSpec{URL: "https://github.com/example/app.git", Ref: "v1.0.0", Path: "src"}},
+			image:   "mgoltzsche/image-registry-operator:latest-makisu",
+			args: []string{"build", "--modifyfs=true", "--registry-config=$REGISTRY_CONFIG", "--push=registry.example.org", "-t=myns/app:v1",
+				"-f=/workspace/src/build/Dockerfile", "--redis-cache-addr=$REDIS", "--redis-cache-password=$REDIS_PASSWORD", "/workspace/src"},
+			mounts:    map[string]string{"workspace": "/workspace", "buildenv": "/makisu-internal/config"},
+			initImage: defaultImageGit,
+		},
+		{
+			name:    "kaniko-configmap",
+			builder: registryapi.ImageBuilderKaniko,
+			context: registryapi.BuildContextSpec{ConfigMap: &corev1.LocalObjectReference{Name: "app-src"}},
+			image:   "gcr.io/kaniko-project/executor:v1.6.0",
+			args: []string{"--context=/workspace", "--dockerfile=/workspace/build/Dockerfile", "--destination=registry.example.org/myns/app:v1",
+				"--digest-file=/dev/termination-log", "--registry-certificate=registry.example.org=/kaniko/.docker/ca_registry.example.org.crt"},
+			mounts: map[string]string{"workspace": "/workspace", "buildenv": "/kaniko/.docker"},
+		},
+		{
+			name:    "buildah-pvc",
+			builder: registryapi.ImageBuilderBuildah,
+			context: registryapi.BuildContextSpec{PersistentVolumeClaim: &registryapi.PVCContextSpec{ClaimName: "app-src", Path: "app"}},
+			image:   "quay.io/buildah/stable:v1.21.0",
+			command: []string{"/bin/sh", "-c", `buildah bud --storage-driver=vfs --isolation=chroot -f "$DOCKERFILE" -t "$IMAGE" "$CONTEXT" && buildah push --storage-driver=vfs --digestfile="$DIGEST_FILE" "$IMAGE"`},
+			mounts:  map[string]string{"workspace": "/workspace", "buildenv": "/buildenv", "containers-certs": "/etc/containers/certs.d"},
+		},
+		{
+			name:    "buildkit-configmap",
+			builder: registryapi.ImageBuilderBuildKit,
+			context: registryapi.BuildContextSpec{ConfigMap: &corev1.LocalObjectReference{Name: "app-src"}},
+			image:   "moby/buildkit:v0.9.0-rootless",
+			command: []string{"/bin/sh", "-c", `buildctl-daemonless.sh "$@" --metadata-file="$METADATA_FILE" && ` +
+				`sed -n 's/.*"containerimage.digest": *"\([^"]*\)".*/\1/p' "$METADATA_FILE" > "$DIGEST_FILE"`, "buildctl-daemonless.sh"},
+			args: []string{"build", "--frontend=dockerfile.v0", "--local=context=/workspace", "--local=dockerfile=/workspace/build", "--opt=filename=Dockerfile",
+				"--output=type=image,name=registry.example.org/myns/app:v1,push=true", "--export-cache=type=registry,ref=cache"},
+			mounts: map[string]string{"workspace": "/workspace", "buildenv": "/etc/buildkit", "docker-config": "/buildenv-docker"},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			env := &registryapi.ImageBuildEnv{}
+			env.Name = "myenv"
+			env.Namespace = "myns"
+			env.Spec.Builders = []registryapi.ImageBuilder{c.builder}
+			env.Spec.AllowPrivilegedBuilds = true
+			env.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionReady, Status: corev1.ConditionTrue})
+			secret := &corev1.Secret{Data: map[string][]byte{
+				corev1.DockerConfigJsonKey:        []byte(`{"auths":{}}`),
+				registryapi.SecretKeyRedis:        []byte("imagebuildenv-myenv-redis:6379"),
+				registryapi.SecretKeyKanikoArgs:   []byte("--registry-certificate=registry.example.org=/kaniko/.docker/ca_registry.example.org.crt\n"),
+				registryapi.SecretKeyCertsDir:     []byte(`{"registry.example.org/ca.crt":"ca_registry.example.org.crt"}`),
+				registryapi.SecretKeyBuildKitArgs: []byte("--export-cache=type=registry,ref=cache\n"),
+				"ca_registry.example.org.crt":     []byte("cert"),
+			}}
+			secret.Name = "imagebuildenv-myenv-conf"
+			secret.Namespace = "myns"
+			build := &registryapi.ImageBuild{}
+			build.Name = "mybuild"
+			build.Namespace = "myns"
+			build.Spec.BuildEnvRef.Name = env.Name
+			build.Spec.Context = c.context
+			build.Spec.Dockerfile = "build/Dockerfile"
+			build.Spec.Image = "registry.example.org/myns/app"
+			build.Spec.Tag = "v1"
+			build.Generation = 2
+			scheme := runtime.NewScheme()
+			require.NoError(t, corev1.AddToScheme(scheme))
+			require.NoError(t, batchv1.AddToScheme(scheme))
+			require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+			cl := fake.NewFakeClientWithScheme(scheme, env, secret, build)
+			r := &ReconcileImageBuild{client: cl, scheme: scheme}
+			key := types.NamespacedName{Name: build.Name, Namespace: build.Namespace}
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+			require.NoError(t, err, "reconcile")
+
+			require.NoError(t, cl.Get(context.TODO(), key, build))
+			require.Equal(t, registryapi.BuildPhaseRunning, build.Status.Phase, "phase")
+			require.Equal(t, "imagebuild-mybuild", build.Status.JobName, "status.jobName")
+			require.Equal(t, int64(2), build.Status.ObservedGeneration, "status.observedGeneration")
+			require.Equal(t, "registry.example.org/myns/app:v1", build.Status.Image, "status.image")
+			job := &batchv1.Job{}
+			require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Name: build.Status.JobName, Namespace: build.Namespace}, job))
+			require.Len(t, job.OwnerReferences, 1, "job owner")
+			podSpec := job.Spec.Template.Spec
+			require.Equal(t, corev1.RestartPolicyNever, podSpec.RestartPolicy, "restartPolicy")
+			require.Len(t, podSpec.Containers, 1, "containers")
+			container := podSpec.Containers[0]
+			require.Equal(t, c.image, container.Image, "image")
+			require.Equal(t, c.command, container.Command, "command")
+			require.Equal(t, corev1.TerminationMessageReadFile, container.TerminationMessagePolicy, "terminationMessagePolicy")
+			if c.args != nil {
+				require.Equal(t, c.args, container.Args, "args")
+			}
+			mounts := map[string]string{}
+			for _, m := range container.VolumeMounts {
+				mounts[m.Name] = m.MountPath
+			}
+			require.Equal(t, c.mounts, mounts, "volume mounts")
+			volumes := map[string]corev1.VolumeSource{}
+			for _, v := range podSpec.Volumes {
+				volumes[v.Name] = v.VolumeSource
+			}
+			for name := range mounts {
+				require.Contains(t, volumes, name, "volume")
+			}
+			require.NotNil(t, volumes["buildenv"].Secret, "buildenv secret volume")
+			require.Equal(t, secret.Name, volumes["buildenv"].Secret.SecretName, "buildenv secret name")
+			if c.initImage != "" {
+				require.Len(t, podSpec.InitContainers, 1, "initContainers")
+				require.Equal(t, c.initImage, podSpec.InitContainers[0].Image, "init container image")
+				require.True(t, strings.Contains(podSpec.InitContainers[0].Command[2], "git clone"), "init container should clone the git repo")
+			} else {
+				require.Empty(t, podSpec.InitContainers, "initContainers")
+			}
+		})
+	}
+}
+
+func TestReconcileImageBuildReportedDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	build := &registryapi.ImageBuild{}
+	build.Name = "mybuild"
+	build.Namespace = "myns"
+	build.Spec.BuildEnvRef.Name = "missing-env"
+	build.Spec.Image = "registry.example.org/myns/app"
+	build.Status.Phase = registryapi.BuildPhaseRunning
+	build.Status.JobName = "imagebuild-mybuild"
+	job := &batchv1.Job{}
+	job.Name = build.Status.JobName
+	job.Namespace = build.Namespace
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	newPod := func(name string, phase corev1.PodPhase, msg string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Name = name
+		pod.Namespace = build.Namespace
+		pod.Labels = map[string]string{labelJobName: job.Name}
+		pod.Status.Phase = phase
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  containerBuild,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: msg}},
+		}}
+		return pod
+	}
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, batchv1.AddToScheme(scheme))
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	cl := fake.NewFakeClientWithScheme(scheme, build, job,
+		newPod("failed", corev1.PodFailed, "sha256:"+strings.Repeat("b", 64)),
+		newPod("succeeded", corev1.PodSucceeded, digest+"\n"),
+	)
+	r := &ReconcileImageBuild{client: cl, scheme: scheme}
+	key := types.NamespacedName{Name: build.Name, Namespace: build.Namespace}
+
+	// The ImageBuildEnv is missing which lets the tag lookup fail - the reported digest must be used
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+	require.NoError(t, err, "reconcile")
+	require.NoError(t, cl.Get(context.TODO(), key, build))
+	require.Equal(t, registryapi.BuildPhaseSucceeded, build.Status.Phase, "phase")
+	require.Equal(t, digest, build.Status.ImageDigest, "status.imageDigest")
+}
+
+func TestReportedImageDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	for _, c := range []struct {
+		name      string
+		container string
+		msg       string
+		expected  string
+	}{
+		{"digest", containerBuild, digest, digest},
+		{"no message", containerBuild, "", ""},
+		{"invalid message", containerBuild, "build failed", ""},
+		{"other container", "sidecar", digest, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			pod := corev1.Pod{}
+			pod.Status.Phase = corev1.PodSucceeded
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:  c.container,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: c.msg}},
+			}}
+			require.Equal(t, c.expected, reportedImageDigest([]corev1.Pod{pod}))
+		})
+	}
+}
+
+func TestReconcileImageBuildPendingBuildEnv(t *testing.T) {
+	env := &registryapi.ImageBuildEnv{}
+	env.Name = "myenv"
+	env.Namespace = "myns"
+	build := &registryapi.ImageBuild{}
+	build.Name = "mybuild"
+	build.Namespace = "myns"
+	build.Spec.BuildEnvRef.Name = env.Name
+	build.Spec.Context.ConfigMap = &corev1.LocalObjectReference{Name: "app-src"}
+	build.Spec.Image = "registry.example.org/myns/app"
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, batchv1.AddToScheme(scheme))
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	cl := fake.NewFakeClientWithScheme(scheme, env, build)
+	r := &ReconcileImageBuild{client: cl, scheme: scheme}
+	key := types.NamespacedName{Name: build.Name, Namespace: build.Namespace}
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+	require.NoError(t, err, "reconcile")
+	require.NoError(t, cl.Get(context.TODO(), key, build))
+	require.Equal(t, registryapi.BuildPhasePending, build.Status.Phase, "phase")
+	require.Empty(t, build.Status.JobName, "status.jobName")
+	c := build.Status.Conditions.GetCondition(registryapi.ConditionReady)
+	require.NotNil(t, c, "ready condition")
+	require.Equal(t, registryapi.ReasonBuildEnvNotReady, c.Reason, "ready condition reason")
+}
+
+func TestValidateBuilder(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		builder      registryapi.ImageBuilder
+		privileged   bool
+		builderImage string
+		valid        bool
+	}{
+		{"kaniko", registryapi.ImageBuilderKaniko, false, "", true},
+		{"kaniko with builder image", registryapi.ImageBuilderKaniko, false, "example.org/kaniko", true},
+		{"not enabled", registryapi.ImageBuilderMakisu, true, "", false},
+		{"buildah not allowed", registryapi.ImageBuilderBuildah, false, "", false},
+		{"buildah allowed", registryapi.ImageBuilderBuildah, true, "", true},
+		{"buildah with builder image", registryapi.ImageBuilderBuildah, true, "example.org/buildah", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			env := &registryapi.ImageBuildEnv{}
+			env.Name = "myenv"
+			env.Spec.Builders = []registryapi.ImageBuilder{registryapi.ImageBuilderKaniko, registryapi.ImageBuilderBuildah}
+			env.Spec.AllowPrivilegedBuilds = c.privileged
+			build := &registryapi.ImageBuild{}
+			build.Spec.BuilderImage = c.builderImage
+			err := validateBuilder(build, env, c.builder)
+			require.Equal(t, c.valid, err == nil, "valid (error: %v)", err)
+		})
+	}
+}
+
+func TestJobNameForCR(t *testing.T) {
+	build := &registryapi.ImageBuild{}
+	build.Name = "mybuild"
+	require.Equal(t, "imagebuild-mybuild", jobNameForCR(build), "short name")
+	names := map[string]bool{}
+	for _, name := range []string{strings.Repeat("a", 60), strings.Repeat("a", 61), strings.Repeat("a", 52) + "-b"} {
+		build.Name = name
+		jobName := jobNameForCR(build)
+		require.Empty(t, validation.IsValidLabelValue(jobName), "invalid label value %q", jobName)
+		require.Empty(t, validation.IsDNS1123Label(jobName), "invalid name %q", jobName)
+		require.False(t, names[jobName], "name %q is not unique", jobName)
+		names[jobName] = true
+	}
+}
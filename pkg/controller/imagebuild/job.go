@@ -0,0 +1,317 @@
+package imagebuild
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registriesconf"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	EnvImageMakisu        = "OPERATOR_IMAGE_MAKISU"
+	EnvImageKaniko        = "OPERATOR_IMAGE_KANIKO"
+	EnvImageBuildah       = "OPERATOR_IMAGE_BUILDAH"
+	EnvImageBuildKit      = "OPERATOR_IMAGE_BUILDKIT"
+	EnvImageGit           = "OPERATOR_IMAGE_GIT"
+	workspaceDir          = "/workspace"
+	buildEnvDir           = "/buildenv"
+	dockerConfigDir       = "/buildenv-docker"
+	containersCertsDir    = "/etc/containers/certs.d"
+	buildKitUID           = int64(1000)
+	jobBackoffLimit       = int32(2)
+	defaultDockerfile     = "Dockerfile"
+	defaultTag            = "latest"
+	dockerHubHost         = "index.docker.io"
+	defaultImageGit       = "alpine/git:v2.30.2"
+	volumeWorkspace       = "workspace"
+	volumeBuildEnv        = "buildenv"
+	volumeDockerConfig    = "docker-config"
+	volumeContainersCerts = "containers-certs"
+	containerBuild        = "build"
+	labelJobName          = "job-name"
+	buildKitMetadataFile  = "/tmp/buildkit-metadata.json"
+)
+
+var digestRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// isPrivileged returns true if the builder runs within a privileged container
+func isPrivileged(builder registryapi.ImageBuilder) bool {
+	return builder == registryapi.ImageBuilderBuildah
+}
+
+// builderImages specifies the default image per builder
+var builderImages = map[registryapi.ImageBuilder]struct{ env, image string }{
+	registryapi.ImageBuilderMakisu:   {EnvImageMakisu, "mgoltzsche/image-registry-operator:latest-makisu"},
+	registryapi.ImageBuilderKaniko:   {EnvImageKaniko, "gcr.io/kaniko-project/executor:v1.6.0"},
+	registryapi.ImageBuilderBuildah:  {EnvImageBuildah, "quay.io/buildah/stable:v1.21.0"},
+	registryapi.ImageBuilderBuildKit: {EnvImageBuildKit, "moby/buildkit:v0.9.0-rootless"},
+}
+
+// jobSpec specifies a build Job
+type jobSpec struct {
+	Name      string
+	Namespace string
+	Builder   registryapi.ImageBuilder
+	// Image is the builder image (defaults to the operator's builder image)
+	Image string
+	// Destination is the pushed image's name and tag
+	Destination string
+	Dockerfile  string
+	Context     *registryapi.BuildContextSpec
+	Resources   corev1.ResourceRequirements
+	// SecretName refers to the ImageBuildEnv's merged Secret
+	SecretName string
+	// SecretData is the ImageBuildEnv's merged Secret's data
+	SecretData map[string][]byte
+}
+
+func newJob(spec *jobSpec) (*batchv1.Job, error) {
+	podSpec := corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever}
+	contextDir, err := addContext(&podSpec, spec)
+	if err != nil {
+		return nil, err
+	}
+	image := spec.Image
+	if image == "" {
+		image = builderImage(spec.Builder)
+	} else if isPrivileged(spec.Builder) {
+		return nil, fmt.Errorf("the privileged builder %s must run the operator's builder image", spec.Builder)
+	}
+	dockerfile := spec.Dockerfile
+	if dockerfile == "" {
+		dockerfile = defaultDockerfile
+	}
+	dockerfile = path.Join(contextDir, dockerfile)
+	// Builders that support it write the pushed image's digest into the termination message
+	c := corev1.Container{
+		Name:                     containerBuild,
+		Image:                    image,
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+		Resources:                spec.Resources,
+		VolumeMounts:             []corev1.VolumeMount{{Name: volumeWorkspace, MountPath: workspaceDir}},
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+	}
+	secretVolume := func(name string, items ...corev1.KeyToPath) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: spec.SecretName, Items: items},
+		}}
+	}
+	podSpec.Volumes = append(podSpec.Volumes, secretVolume(volumeBuildEnv))
+	annotations := map[string]string{}
+	switch spec.Builder {
+	case registryapi.ImageBuilderMakisu:
+		host, repo := splitImageName(spec.Destination)
+		c.Args = []string{
+			"build",
+			"--modifyfs=true",
+			"--registry-config=$REGISTRY_CONFIG",
+			"--push=" + host,
+			"-t=" + repo,
+			"-f=" + dockerfile,
+		}
+		if len(spec.SecretData[registryapi.SecretKeyRedis]) > 0 {
+			c.Args = append(c.Args, "--redis-cache-addr=$REDIS", "--redis-cache-password=$REDIS_PASSWORD")
+		}
+		c.Args = append(c.Args, contextDir)
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: volumeBuildEnv, MountPath: registriesconf.MakisuConfigDir})
+	case registryapi.ImageBuilderKaniko:
+		c.Args = []string{
+			"--context=" + contextDir,
+			"--dockerfile=" + dockerfile,
+			"--destination=" + spec.Destination,
+			"--digest-file=" + corev1.TerminationMessagePathDefault,
+		}
+		c.Args = append(c.Args, argLines(spec.SecretData[registryapi.SecretKeyKanikoArgs])...)
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: volumeBuildEnv, MountPath: registriesconf.KanikoDir})
+	case registryapi.ImageBuilderBuildah:
+		certsDir := map[string]string{}
+		if certsDirJSON := spec.SecretData[registryapi.SecretKeyCertsDir]; len(certsDirJSON) > 0 {
+			if err := json.Unmarshal(certsDirJSON, &certsDir); err != nil {
+				return nil, fmt.Errorf("read build env secret key %s: %w", registryapi.SecretKeyCertsDir, err)
+			}
+		}
+		if len(certsDir) > 0 {
+			items := make([]corev1.KeyToPath, 0, len(certsDir))
+			for p, key := range certsDir {
+				items = append(items, corev1.KeyToPath{Key: key, Path: p})
+			}
+			sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+			podSpec.Volumes = append(podSpec.Volumes, secretVolume(volumeContainersCerts, items...))
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: volumeContainersCerts, MountPath: containersCertsDir})
+		}
+		// buildah requires privileges to mount its storage
+		privileged := true
+		c.SecurityContext = &corev1.SecurityContext{Privileged: &privileged}
+		c.Command = []string{"/bin/sh", "-c", `buildah bud --storage-driver=vfs --isolation=chroot -f "$DOCKERFILE" -t "$IMAGE" "$CONTEXT" && buildah push --storage-driver=vfs --digestfile="$DIGEST_FILE" "$IMAGE"`}
+		c.Env = []corev1.EnvVar{
+			{Name: "DOCKERFILE", Value: dockerfile},
+			{Name: "IMAGE", Value: spec.Destination},
+			{Name: "CONTEXT", Value: contextDir},
+			{Name: "DIGEST_FILE", Value: corev1.TerminationMessagePathDefault},
+			{Name: "REGISTRY_AUTH_FILE", Value: path.Join(buildEnvDir, registryapi.SecretKeyAuthJSON)},
+			{Name: "CONTAINERS_REGISTRIES_CONF", Value: path.Join(buildEnvDir, registryapi.SecretKeyRegistriesConf)},
+		}
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: volumeBuildEnv, MountPath: buildEnvDir})
+	case registryapi.ImageBuilderBuildKit:
+		// The digest is extracted from the metadata file since the whole file may exceed the termination message size limit
+		c.Command = []string{"/bin/sh", "-c", `buildctl-daemonless.sh "$@" --metadata-file="$METADATA_FILE" && ` +
+			`sed -n 's/.*"containerimage.digest": *"\([^"]*\)".*/\1/p' "$METADATA_FILE" > "$DIGEST_FILE"`, "buildctl-daemonless.sh"}
+		c.Args = []string{
+			"build",
+			"--frontend=dockerfile.v0",
+			"--local=context=" + contextDir,
+			"--local=dockerfile=" + path.Dir(dockerfile),
+			"--opt=filename=" + path.Base(dockerfile),
+			"--output=type=image,name=" + spec.Destination + ",push=true",
+		}
+		c.Args = append(c.Args, argLines(spec.SecretData[registryapi.SecretKeyBuildKitArgs])...)
+		c.Env = []corev1.EnvVar{
+			{Name: "BUILDKITD_FLAGS", Value: "--config=" + path.Join(registriesconf.BuildKitDir, registryapi.SecretKeyBuildKitdTOML) + " --oci-worker-no-process-sandbox"},
+			{Name: "DOCKER_CONFIG", Value: dockerConfigDir},
+			{Name: "METADATA_FILE", Value: buildKitMetadataFile},
+			{Name: "DIGEST_FILE", Value: corev1.TerminationMessagePathDefault},
+		}
+		uid := buildKitUID
+		c.SecurityContext = &corev1.SecurityContext{RunAsUser: &uid, RunAsGroup: &uid}
+		podSpec.Volumes = append(podSpec.Volumes, secretVolume(volumeDockerConfig, corev1.KeyToPath{Key: corev1.DockerConfigJsonKey, Path: "config.json"}))
+		c.VolumeMounts = append(c.VolumeMounts,
+			corev1.VolumeMount{Name: volumeBuildEnv, MountPath: registriesconf.BuildKitDir},
+			corev1.VolumeMount{Name: volumeDockerConfig, MountPath: dockerConfigDir},
+		)
+		// rootless buildkit requires unconfined seccomp and apparmor profiles
+		annotations["container.apparmor.security.beta.kubernetes.io/"+c.Name] = "unconfined"
+		annotations["container.seccomp.security.alpha.kubernetes.io/"+c.Name] = "unconfined"
+	default:
+		return nil, fmt.Errorf("unsupported builder %q", spec.Builder)
+	}
+	podSpec.Containers = []corev1.Container{c}
+	backoffLimit := jobBackoffLimit
+	job := &batchv1.Job{}
+	job.Name = spec.Name
+	job.Namespace = spec.Namespace
+	job.Spec.BackoffLimit = &backoffLimit
+	if len(annotations) > 0 {
+		job.Spec.Template.Annotations = annotations
+	}
+	job.Spec.Template.Spec = podSpec
+	return job, nil
+}
+
+// addContext adds the build context volume to the Pod and returns the context directory
+func addContext(podSpec *corev1.PodSpec, spec *jobSpec) (contextDir string, err error) {
+	ctx := spec.Context
+	volume := corev1.Volume{Name: volumeWorkspace}
+	subPath := ""
+	switch {
+	case ctx.Git != nil:
+		if ctx.Git.URL == "" {
+			return "", fmt.Errorf("no git url specified")
+		}
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		subPath = ctx.Git.Path
+		podSpec.InitContainers = []corev1.Container{
+			{
+				Name:            "git-clone",
+				Image:           envOrDefault(EnvImageGit, defaultImageGit),
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Command:         []string{"/bin/sh", "-c", `git clone "$GIT_URL" "$GIT_DIR" && if [ "$GIT_REF" ]; then git -C "$GIT_DIR" -c advice.detachedHead=false checkout "$GIT_REF"; fi`},
+				Env: []corev1.EnvVar{
+					{Name: "GIT_URL", Value: ctx.Git.URL},
+					{Name: "GIT_REF", Value: ctx.Git.Ref},
+					{Name: "GIT_DIR", Value: workspaceDir},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: volumeWorkspace, MountPath: workspaceDir}},
+			},
+		}
+	case ctx.ConfigMap != nil:
+		volume.ConfigMap = &corev1.ConfigMapVolumeSource{LocalObjectReference: *ctx.ConfigMap}
+	case ctx.PersistentVolumeClaim != nil:
+		volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: ctx.PersistentVolumeClaim.ClaimName,
+			ReadOnly:  true,
+		}
+		subPath = ctx.PersistentVolumeClaim.Path
+	default:
+		return "", fmt.Errorf("neither git, configMap nor persistentVolumeClaim context specified")
+	}
+	podSpec.Volumes = append(podSpec.Volumes, volume)
+	return path.Join(workspaceDir, subPath), nil
+}
+
+// jobFinished returns true if the Job succeeded or failed
+func jobFinished(job *batchv1.Job) (finished bool, failed bool, msg string) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, false, ""
+		case batchv1.JobFailed:
+			return true, true, c.Message
+		}
+	}
+	return false, false, ""
+}
+
+// reportedImageDigest returns the pushed image's digest the builder wrote into the succeeded Pod's termination message
+// or an empty string if the builder did not report it (makisu).
+func reportedImageDigest(pods []corev1.Pod) string {
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.Name != containerBuild || c.State.Terminated == nil {
+				continue
+			}
+			if digest := strings.TrimSpace(c.State.Terminated.Message); digestRegex.MatchString(digest) {
+				return digest
+			}
+		}
+	}
+	return ""
+}
+
+// splitImageName splits an image name into the registry host and the repository (including the tag)
+func splitImageName(image string) (host, repo string) {
+	s := strings.SplitN(image, "/", 2)
+	if len(s) == 2 && (strings.ContainsAny(s[0], ".:") || s[0] == "localhost") {
+		return s[0], s[1]
+	}
+	if len(s) == 1 {
+		return dockerHubHost, "library/" + image
+	}
+	return dockerHubHost, image
+}
+
+// argLines returns the non-empty lines of an args file
+func argLines(b []byte) (args []string) {
+	for _, l := range bytes.Split(b, []byte("\n")) {
+		if l = bytes.TrimSpace(l); len(l) > 0 {
+			args = append(args, string(l))
+		}
+	}
+	return
+}
+
+func builderImage(builder registryapi.ImageBuilder) string {
+	d := builderImages[builder]
+	return envOrDefault(d.env, d.image)
+}
+
+func envOrDefault(env, defaultValue string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return defaultValue
+}
@@ -0,0 +1,312 @@
+package imagebuildenv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagesecret"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registryclient"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	defaultCacheMaxAge      = 7 * 24 * time.Hour
+	defaultCacheStorageSize = "8Gi"
+	cacheRepoKaniko         = "kaniko"
+	cacheRepoBuildKit       = "buildkit"
+	cacheTagBuildKit        = "cache"
+	cachePurgeRetryDelay    = 5 * time.Minute
+	// cachePurgeIntervals is the number of purges per max age
+	cachePurgeIntervals = 4
+	// maxImageConfigSize limits the size of an image config that is read to determine a cache entry's age
+	maxImageConfigSize = 1 << 20
+)
+
+// cacheRegistry is the subset of the registry client the cache purge requires.
+// It does not list the catalog since the cache account is not granted catalog access.
+type cacheRegistry interface {
+	Tags(repo string) ([]string, error)
+	Manifest(repo, ref string) (*registryclient.Manifest, error)
+	Blob(repo, digest string) (io.ReadCloser, error)
+	DeleteManifest(repo, digest string) error
+}
+
+// reconcileCache provisions the ImageBuildEnv's cache registry and push account.
+// It returns the account's Secret or nil if the account is not ready yet or the cache is disabled.
+func (r *ReconcileImageBuildEnv) reconcileCache(cr *registryv1alpha1.ImageBuildEnv, reqLogger logr.Logger) (secret *corev1.Secret, err error) {
+	spec := cr.Spec.Cache
+	if spec == nil {
+		cr.Status.Cache = nil
+		if err = r.deleteCacheResource(cr, &registryv1alpha1.ImagePushSecret{}, reqLogger); err != nil {
+			return
+		}
+		return nil, r.deleteCacheResource(cr, &registryv1alpha1.ImageRegistry{}, reqLogger)
+	}
+	registryRef := spec.RegistryRef
+	if registryRef == nil {
+		if err = r.upsertCacheRegistryForCR(cr); err != nil {
+			return
+		}
+		registryRef = &registryv1alpha1.ImageRegistryRef{Name: cacheNameForCR(cr), Namespace: cr.Namespace}
+	} else if err = r.deleteCacheResource(cr, &registryv1alpha1.ImageRegistry{}, reqLogger); err != nil {
+		return
+	}
+	prefix := cacheRepositoryPrefix(cr)
+	pushSecret := &registryv1alpha1.ImagePushSecret{}
+	pushSecret.Name = cacheNameForCR(cr)
+	pushSecret.Namespace = cr.Namespace
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, pushSecret, func() error {
+		pushSecret.Spec.RegistryRef = registryRef
+		pushSecret.Spec.RepositoryPrefix = prefix
+		return controllerutil.SetControllerReference(cr, pushSecret, r.scheme)
+	})
+	if err != nil || !pushSecret.Status.Conditions.IsTrueFor(registryv1alpha1.ConditionReady) ||
+		pushSecret.Status.ObservedGeneration != pushSecret.Generation {
+		return nil, err
+	}
+	secret = &corev1.Secret{}
+	key := client.ObjectKey{Name: imagesecret.SecretNameForCR(pushSecret), Namespace: cr.Namespace}
+	if err = r.client.Get(context.TODO(), key, secret); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	st := &registryv1alpha1.ImageBuildCacheStatus{}
+	if cr.Status.Cache != nil {
+		st.PurgeTime = cr.Status.Cache.PurgeTime
+		st.FirstSeen = cr.Status.Cache.FirstSeen
+	}
+	st.Registry = string(secret.Data[registryv1alpha1.SecretKeyRegistry])
+	st.RepositoryPrefix = prefix
+	cr.Status.Cache = st
+	return secret, nil
+}
+
+// upsertCacheRegistryForCR provisions a dedicated ImageRegistry for the ImageBuildEnv's cache
+func (r *ReconcileImageBuildEnv) upsertCacheRegistryForCR(cr *registryv1alpha1.ImageBuildEnv) (err error) {
+	registry := &registryv1alpha1.ImageRegistry{}
+	registry.Name = cacheNameForCR(cr)
+	registry.Namespace = cr.Namespace
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, registry, func() error {
+		pvc := &registry.Spec.PersistentVolumeClaim
+		if spec := cr.Spec.Cache.PersistentVolumeClaim; spec != nil {
+			*pvc = *spec
+		}
+		if len(pvc.AccessModes) == 0 {
+			pvc.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		}
+		if _, ok := pvc.Resources.Requests[corev1.ResourceStorage]; !ok {
+			if pvc.Resources.Requests == nil {
+				pvc.Resources.Requests = corev1.ResourceList{}
+			}
+			pvc.Resources.Requests[corev1.ResourceStorage] = resource.MustParse(defaultCacheStorageSize)
+		}
+		return controllerutil.SetControllerReference(cr, registry, r.scheme)
+	})
+	return
+}
+
+// deleteCacheResource deletes the given cache resource if it exists and is owned by the ImageBuildEnv
+func (r *ReconcileImageBuildEnv) deleteCacheResource(cr *registryv1alpha1.ImageBuildEnv, o runtime.Object, reqLogger logr.Logger) error {
+	key := client.ObjectKey{Name: cacheNameForCR(cr), Namespace: cr.Namespace}
+	if err := r.client.Get(context.TODO(), key, o); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(o.(metav1.Object), cr) {
+		return nil
+	}
+	reqLogger.Info(fmt.Sprintf("Deleting cache %T", o), "name", key.Name)
+	return client.IgnoreNotFound(r.client.Delete(context.TODO(), o))
+}
+
+// purgeCacheIfDue deletes the cache manifests that are older than the cache's max age.
+// It runs several times per max age and returns the duration until the next run.
+// When manifests of the dedicated cache registry have been deleted its garbage collection is requested to free the space.
+func (r *ReconcileImageBuildEnv) purgeCacheIfDue(cr *registryv1alpha1.ImageBuildEnv, secret *corev1.Secret, reqLogger logr.Logger) (requeueAfter time.Duration, err error) {
+	maxAge := defaultCacheMaxAge
+	if cr.Spec.Cache.MaxAge != nil && cr.Spec.Cache.MaxAge.Duration > 0 {
+		maxAge = cr.Spec.Cache.MaxAge.Duration
+	}
+	interval := maxAge / cachePurgeIntervals
+	st := cr.Status.Cache
+	now := metav1.Now()
+	if st.PurgeTime != nil {
+		if d := st.PurgeTime.Add(interval).Sub(now.Time); d > 0 {
+			return d, nil
+		}
+	}
+	reqLogger.Info("Purging build cache", "registry", st.Registry, "prefix", st.RepositoryPrefix)
+	c, err := registryclient.New(string(secret.Data[registryv1alpha1.SecretKeyRegistry]), registryclient.Credentials{
+		Username: string(secret.Data[registryv1alpha1.SecretKeyUsername]),
+		Password: string(secret.Data[registryv1alpha1.SecretKeyPassword]),
+	}, secret.Data[registryv1alpha1.SecretKeyCaCert])
+	deleted := 0
+	if err == nil {
+		deleted, err = purgeCache(c, st, maxAge, now)
+	}
+	if err == nil && deleted > 0 && cr.Spec.Cache.RegistryRef == nil {
+		err = r.requestCacheGarbageCollection(cr, now)
+	}
+	if err != nil {
+		r.recorder.Event(cr, corev1.EventTypeWarning, registryv1alpha1.EventReasonCachePurgeFailed, err.Error())
+		return cachePurgeRetryDelay, err
+	}
+	if deleted > 0 {
+		r.recorder.Eventf(cr, corev1.EventTypeNormal, registryv1alpha1.EventReasonCachePurged, "Deleted %d cache manifests older than %s", deleted, maxAge)
+	}
+	st.PurgeTime = &now
+	return interval, nil
+}
+
+// requestCacheGarbageCollection makes the dedicated cache registry collect the blobs of deleted manifests
+func (r *ReconcileImageBuildEnv) requestCacheGarbageCollection(cr *registryv1alpha1.ImageBuildEnv, now metav1.Time) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		registry := &registryv1alpha1.ImageRegistry{}
+		key := client.ObjectKey{Name: cacheNameForCR(cr), Namespace: cr.Namespace}
+		if err := r.client.Get(context.TODO(), key, registry); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !metav1.IsControlledBy(registry, cr) {
+			return nil
+		}
+		if registry.Annotations == nil {
+			registry.Annotations = map[string]string{}
+		}
+		registry.Annotations[imageregistry.AnnotationGarbageCollect] = now.UTC().Format(time.RFC3339)
+		return r.client.Update(context.TODO(), registry)
+	})
+}
+
+// purgeCache deletes the manifests within the cache repositories the builders push to that are older than maxAge.
+// A manifest's age is derived from its image config's creation time.
+// Manifests that don't specify a creation time (e.g. BuildKit's cache manifest) are aged from the time they have been seen first.
+func purgeCache(c cacheRegistry, st *registryv1alpha1.ImageBuildCacheStatus, maxAge time.Duration, now metav1.Time) (deleted int, err error) {
+	firstSeen := map[string]metav1.Time{}
+	for _, repo := range cacheRepositories(st.RepositoryPrefix) {
+		tags, err := c.Tags(repo)
+		if err != nil {
+			if registryclient.IsNotFound(err) {
+				continue
+			}
+			return deleted, fmt.Errorf("list cache repository %s tags: %w", repo, err)
+		}
+		purged := map[string]bool{}
+		for _, tag := range tags {
+			m, err := c.Manifest(repo, tag)
+			if err != nil {
+				if registryclient.IsNotFound(err) {
+					continue
+				}
+				return deleted, err
+			}
+			if purged[m.Digest] {
+				continue
+			}
+			created, err := imageCreationTime(c, repo, m)
+			if err != nil {
+				return deleted, err
+			}
+			if created.IsZero() {
+				key := repo + "@" + m.Digest
+				seen, ok := st.FirstSeen[key]
+				if !ok {
+					seen = now
+				}
+				firstSeen[key] = seen
+				created = seen.Time
+			}
+			if now.Sub(created) < maxAge {
+				continue
+			}
+			if err = c.DeleteManifest(repo, m.Digest); err != nil && !registryclient.IsNotFound(err) {
+				return deleted, fmt.Errorf("delete cache manifest %s:%s: %w", repo, tag, err)
+			}
+			delete(firstSeen, repo+"@"+m.Digest)
+			purged[m.Digest] = true
+			deleted++
+		}
+	}
+	st.FirstSeen = nil
+	if len(firstSeen) > 0 {
+		st.FirstSeen = firstSeen
+	}
+	return deleted, nil
+}
+
+// imageCreationTime returns the creation time specified within an image manifest's config
+// or a zero time if the manifest is no image or its config does not specify it.
+func imageCreationTime(c cacheRegistry, repo string, m *registryclient.Manifest) (created time.Time, err error) {
+	if m.IsList() || len(m.Blobs) == 0 {
+		return
+	}
+	config := m.Blobs[0]
+	if config.MediaType != registryclient.MediaTypeImageConfig && config.MediaType != registryclient.MediaTypeOCIConfig {
+		return
+	}
+	blob, err := c.Blob(repo, config.Digest)
+	if err != nil {
+		return
+	}
+	defer blob.Close()
+	parsed := struct {
+		Created *time.Time `json:"created"`
+	}{}
+	if err = json.NewDecoder(io.LimitReader(blob, maxImageConfigSize)).Decode(&parsed); err != nil {
+		return created, fmt.Errorf("read image config %s@%s: %w", repo, config.Digest, err)
+	}
+	if parsed.Created != nil {
+		created = *parsed.Created
+	}
+	return
+}
+
+// addCacheConfig adds the cache repository coordinates to the Secret data
+func addCacheConfig(cr *registryv1alpha1.ImageBuildEnv, data map[string][]byte) {
+	st := cr.Status.Cache
+	if cr.Spec.Cache == nil || st == nil {
+		return
+	}
+	repo := st.Registry + "/" + st.RepositoryPrefix
+	data[registryv1alpha1.SecretKeyCacheRepo] = []byte(repo)
+	if cr.Spec.HasBuilder(registryv1alpha1.ImageBuilderKaniko) {
+		args := fmt.Sprintf("--cache=true\n--cache-repo=%s/%s\n", repo, cacheRepoKaniko)
+		data[registryv1alpha1.SecretKeyKanikoArgs] = append(data[registryv1alpha1.SecretKeyKanikoArgs], args...)
+	}
+	if cr.Spec.HasBuilder(registryv1alpha1.ImageBuilderBuildKit) {
+		ref := fmt.Sprintf("%s/%s:%s", repo, cacheRepoBuildKit, cacheTagBuildKit)
+		data[registryv1alpha1.SecretKeyBuildKitArgs] = []byte(fmt.Sprintf("--export-cache=type=registry,ref=%s,mode=max\n--import-cache=type=registry,ref=%s\n", ref, ref))
+	}
+}
+
+// cacheRepositories returns the repositories below the prefix the builders push their cache to
+func cacheRepositories(prefix string) []string {
+	return []string{prefix + "/" + cacheRepoKaniko, prefix + "/" + cacheRepoBuildKit}
+}
+
+// cacheRepositoryPrefix returns the repository prefix the ImageBuildEnv's cache is pushed to.
+// A custom prefix is placed below the namespace since the cache account may only delete repositories within it.
+func cacheRepositoryPrefix(cr *registryv1alpha1.ImageBuildEnv) string {
+	prefix := strings.Trim(cr.Spec.Cache.RepositoryPrefix, "/")
+	if prefix == "" {
+		return fmt.Sprintf("%s/%s/cache", cr.Namespace, cr.Name)
+	}
+	if strings.HasPrefix(prefix, cr.Namespace+"/") {
+		return prefix
+	}
+	return cr.Namespace + "/" + prefix
+}
+
+func cacheNameForCR(cr *registryv1alpha1.ImageBuildEnv) string {
+	return "imagebuildenv-" + cr.Name + "-cache"
+}
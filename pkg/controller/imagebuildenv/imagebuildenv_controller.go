@@ -2,20 +2,26 @@ package imagebuildenv
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
 	"github.com/mgoltzsche/image-registry-operator/pkg/backrefs"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
 	"github.com/mgoltzsche/image-registry-operator/pkg/merge"
-	"github.com/mgoltzsche/image-registry-operator/pkg/passwordgen"
 	"github.com/mgoltzsche/image-registry-operator/pkg/registriesconf"
 	"github.com/operator-framework/operator-sdk/pkg/status"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -41,7 +47,9 @@ func Add(mgr manager.Manager) error {
 	r := &ReconcileImageBuildEnv{
 		client:     mgr.GetClient(),
 		scheme:     mgr.GetScheme(),
-		secretRefs: backrefs.NewBackReferencesHandler(mgr.GetClient(), backrefs.OwnerReferences()),
+		recorder:   mgr.GetEventRecorderFor("imagebuildenv-controller"),
+		secretRefs: backrefs.NewBackReferencesHandler(mgr.GetClient(), secretReferences()),
+		imageRedis: redisImage(),
 	}
 
 	// Create a new controller
@@ -57,7 +65,15 @@ func Add(mgr manager.Manager) error {
 	}
 
 	// Watch for changes to secondary resources
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
+	err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageBuildEnv{},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &registryv1alpha1.ImageBuildEnv{},
 	})
@@ -80,6 +96,30 @@ func Add(mgr manager.Manager) error {
 		return err
 	}
 
+	// Watch input Secrets (which may live in other namespaces)
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: backrefs.AnnotationReferencesToRequests(annotationSecretRefs, &registryv1alpha1.ImageBuildEnv{}),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &registryv1alpha1.ImagePushSecret{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageBuildEnv{},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &registryv1alpha1.ImageRegistry{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageBuildEnv{},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -92,7 +132,9 @@ type ReconcileImageBuildEnv struct {
 	// that reads objects from the cache and writes to the apiserver
 	client     client.Client
 	scheme     *runtime.Scheme
+	recorder   record.EventRecorder
 	secretRefs *backrefs.BackReferencesHandler
+	imageRedis string
 }
 
 // Reconcile reads that state of the cluster for a ImageBuildEnv object and makes changes based on the state read
@@ -148,8 +190,15 @@ func (r *ReconcileImageBuildEnv) Reconcile(request reconcile.Request) (reconcile
 
 	// Load referenced docker config secrets
 	secrets, err := r.loadInputSecretsForCR(instance)
+	if e, ok := err.(*secretNotAllowedError); ok {
+		// secret not shared with the namespace - reconcile after one minute since it is not watched
+		r.recorder.Event(instance, corev1.EventTypeWarning, registryv1alpha1.EventReasonSecretNotAllowed, e.Error())
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryv1alpha1.ReasonSecretNotAllowed, e.Error())
+		return reconcile.Result{RequeueAfter: time.Minute}, err
+	}
 	if err != nil {
 		// secret not found - reconcile after one minute
+		r.recorder.Event(instance, corev1.EventTypeWarning, registryv1alpha1.EventReasonMissingSecret, err.Error())
 		err = r.updateStatus(instance, corev1.ConditionFalse, registryv1alpha1.ReasonMissingSecret, err.Error())
 		return reconcile.Result{RequeueAfter: 30 * time.Second}, err
 	}
@@ -159,22 +208,57 @@ func (r *ReconcileImageBuildEnv) Reconcile(request reconcile.Request) (reconcile
 		return reconcile.Result{}, err
 	}
 
+	// Provision the build cache
+	cacheStatus := instance.Status.Cache.DeepCopy()
+	cacheSecret, err := r.reconcileCache(instance, reqLogger)
+	if err != nil {
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryv1alpha1.ReasonFailedUpdate, err.Error())
+		return reconcile.Result{}, err
+	}
+	if instance.Spec.Cache != nil && cacheSecret == nil {
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryv1alpha1.ReasonPending, "waiting for the build cache account to become ready")
+		return reconcile.Result{RequeueAfter: 10 * time.Second}, err
+	}
+	var requeueAfter time.Duration
+	if cacheSecret != nil {
+		requeueAfter, err = r.purgeCacheIfDue(instance, cacheSecret, reqLogger)
+		if err != nil {
+			reqLogger.Error(err, "failed to purge build cache")
+		}
+	}
+	if !reflect.DeepEqual(cacheStatus, instance.Status.Cache) {
+		if err = r.client.Status().Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	// Merge the secrets
-	data, err := r.mergeSecretData(secrets)
+	data, conflicts, err := r.mergeSecretData(instance, secrets, cacheSecret)
 	if err != nil {
 		// config invalid
 		err = r.updateStatus(instance, corev1.ConditionFalse, registryv1alpha1.ReasonInvalidSecret, err.Error())
 		return reconcile.Result{}, err
 	}
+	addCacheConfig(instance, data)
+	if err = r.setAuthConflictCondition(instance, conflicts); err != nil {
+		return reconcile.Result{}, err
+	}
 
 	// Configure redis and upsert output Secret
-	ready, err := r.configureRedis(instance, data)
+	redisPending := isRedisPending(instance)
+	ready, err := r.configureRedis(instance, data, reqLogger)
 	if err == nil {
 		if !ready {
+			if !redisPending {
+				r.recorder.Event(instance, corev1.EventTypeNormal, registryv1alpha1.EventReasonRedisPending, "Waiting for redis to become ready")
+			}
 			err = r.updateStatus(instance, corev1.ConditionFalse, registryv1alpha1.ReasonPending, "waiting for redis to become ready")
 			return reconcile.Result{}, err
 		}
 
+		if redisPending {
+			r.recorder.Event(instance, corev1.EventTypeNormal, registryv1alpha1.EventReasonRedisReady, "Redis is ready")
+		}
 		err = r.upsertMergedSecretForCR(instance, data)
 	}
 	if err != nil {
@@ -183,7 +267,13 @@ func (r *ReconcileImageBuildEnv) Reconcile(request reconcile.Request) (reconcile
 	}
 
 	err = r.updateStatus(instance, corev1.ConditionTrue, "", "")
-	return reconcile.Result{}, err
+	return reconcile.Result{RequeueAfter: requeueAfter}, err
+}
+
+// isRedisPending returns true if the ImageBuildEnv is waiting for redis to become ready
+func isRedisPending(cr *registryv1alpha1.ImageBuildEnv) bool {
+	c := cr.Status.Conditions.GetCondition(registryv1alpha1.ConditionReady)
+	return c != nil && c.Reason == registryv1alpha1.ReasonPending
 }
 
 func secretsToObjects(secrets []*corev1.Secret) []backrefs.Object {
@@ -209,7 +299,7 @@ func (r *ReconcileImageBuildEnv) updateStatus(cr *registryv1alpha1.ImageBuildEnv
 
 func (r *ReconcileImageBuildEnv) upsertMergedSecretForCR(cr *registryv1alpha1.ImageBuildEnv, data map[string][]byte) (err error) {
 	mergedSecret := &corev1.Secret{}
-	mergedSecret.Name = "imagebuildenv-" + cr.Name + "-conf"
+	mergedSecret.Name = SecretNameForCR(cr)
 	mergedSecret.Namespace = cr.Namespace
 	mergedSecret.Type = corev1.SecretTypeOpaque
 	if err = controllerutil.SetControllerReference(cr, mergedSecret, r.scheme); err != nil {
@@ -225,40 +315,95 @@ func (r *ReconcileImageBuildEnv) upsertMergedSecretForCR(cr *registryv1alpha1.Im
 func (r *ReconcileImageBuildEnv) loadInputSecretsForCR(cr *registryv1alpha1.ImageBuildEnv) (secrets []*corev1.Secret, err error) {
 	secrets = make([]*corev1.Secret, len(cr.Spec.Secrets))
 	for i, s := range cr.Spec.Secrets {
-		key := types.NamespacedName{Name: s.SecretName, Namespace: cr.Namespace}
+		key := types.NamespacedName{Name: s.SecretName, Namespace: s.Namespace}
+		if key.Namespace == "" {
+			key.Namespace = cr.Namespace
+		}
 		secret := &corev1.Secret{}
 		err = r.client.Get(context.TODO(), key, secret)
 		if err != nil {
 			return
 		}
+		if key.Namespace != cr.Namespace && !allowsNamespace(secret, cr.Namespace) {
+			return nil, &secretNotAllowedError{key}
+		}
 		secrets[i] = secret
 	}
 	return
 }
 
-func (r *ReconcileImageBuildEnv) mergeSecretData(secrets []*corev1.Secret) (data map[string][]byte, err error) {
+type secretNotAllowedError struct {
+	key types.NamespacedName
+}
+
+func (e *secretNotAllowedError) Error() string {
+	return fmt.Sprintf("secret %s does not allow the namespace to use it (annotation %s)", e.key, registryv1alpha1.AnnotationAllowedNamespaces)
+}
+
+// allowsNamespace returns true if the Secret's allowed-namespaces annotation contains the given namespace or "*"
+func allowsNamespace(secret *corev1.Secret, namespace string) bool {
+	for _, ns := range strings.Split(secret.GetAnnotations()[registryv1alpha1.AnnotationAllowedNamespaces], ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSecretData merges the docker configs and CA certificates of the input Secrets
+// and the optional cache account Secret.
+// The cache account's credentials are used only for registries no input Secret specifies credentials for.
+// It returns the hosts whose credentials are specified differently by multiple Secrets.
+func (r *ReconcileImageBuildEnv) mergeSecretData(cr *registryv1alpha1.ImageBuildEnv, secrets []*corev1.Secret, cacheSecret *corev1.Secret) (data map[string][]byte, conflicts []string, err error) {
 	var (
-		makisuConf registriesconf.MakisuRegistries = map[string]registriesconf.MakisuRepos{}
-		dockerConf                                 = &registriesconf.DockerConfig{Auths: map[string]registriesconf.DockerConfigUrlAuth{}}
-		inputConf  *registriesconf.DockerConfig
+		makisuConf  registriesconf.MakisuRegistries = map[string]registriesconf.MakisuRepos{}
+		dockerConf                                  = &registriesconf.DockerConfig{Auths: map[string]registriesconf.DockerConfigUrlAuth{}}
+		registries                                  = map[string]registriesconf.Registry{}
+		authSources                                 = map[string]string{}
+		makisuAuths                                 = map[string]registriesconf.MakisuBasicAuth{}
+		caCerts                                     = make([][]byte, 0, len(secrets)+1)
+		inputs                                      = secrets
+		inputConf   *registriesconf.DockerConfig
 	)
-	for _, secret := range secrets {
+	if cacheSecret != nil {
+		inputs = append(secrets[:len(secrets):len(secrets)], cacheSecret)
+	}
+	for _, secret := range inputs {
 		inputConf, err = dockerConfigFromSecret(secret)
 		if err != nil {
-			return nil, fmt.Errorf("secret %s: %w", secret.Name, err)
+			return nil, nil, fmt.Errorf("secret %s: %w", secret.Name, err)
 		}
+		ca := secret.Data[registryv1alpha1.SecretKeyCaCert]
+		caCerts = append(caCerts, ca)
 		// Merge config
-		if inputConf.Auths != nil {
-			for k, v := range inputConf.Auths {
-				dockerConf.Auths[k] = v
-				auth, e := registriesconf.ToMakisuBasicAuth(v.Auth)
-				if e != nil {
-					return nil, fmt.Errorf("secret %s basic auth: %w", secret.Name, e)
-				}
-				makisuConf.AddRegistry(k, ".*", auth)
+		for k, v := range inputConf.Auths {
+			auth, e := registriesconf.ToMakisuBasicAuth(v.Auth)
+			if e != nil {
+				return nil, nil, fmt.Errorf("secret %s basic auth: %w", secret.Name, e)
+			}
+			host := registriesconf.RegistryHost(k)
+			prev, ok := registries[host]
+			if ok && secret == cacheSecret {
+				continue
+			}
+			if ok && prev.Auth != v.Auth && authSources[host] != secret.Name {
+				conflicts = append(conflicts, fmt.Sprintf("%s (secrets %s and %s)", host, authSources[host], secret.Name))
 			}
+			authSources[host] = secret.Name
+			dockerConf.Auths[k] = v
+			makisuAuths[k] = auth
+			registries[host] = registriesconf.Registry{Host: host, AuthKey: k, Auth: v.Auth, CA: ca}
 		}
 	}
+	for k, auth := range makisuAuths {
+		host := registriesconf.RegistryHost(k)
+		caFile := registriesconf.MakisuDefaultCACerts
+		if len(registries[host].CA) > 0 {
+			caFile = path.Join(registriesconf.MakisuConfigDir, registriesconf.CAFileName(host))
+		}
+		makisuConf.AddRegistryWithCA(k, ".*", auth, caFile)
+	}
 
 	// Prepare secret data
 	data = map[string][]byte{}
@@ -267,9 +412,70 @@ func (r *ReconcileImageBuildEnv) mergeSecretData(secrets []*corev1.Secret) (data
 			data[k] = v
 		}
 	}
+	delete(data, registryv1alpha1.SecretKeyCaCert)
+	if bundle := certs.MergeCertificates(caCerts); len(bundle) > 0 {
+		data[registryv1alpha1.SecretKeyCaCert] = bundle
+	}
 	data[corev1.DockerConfigJsonKey] = dockerConf.JSON()
-	data[registryv1alpha1.SecretKeyMakisuYAML] = makisuConf.YAML()
-	return data, nil
+	if cr.Spec.HasBuilder(registryv1alpha1.ImageBuilderMakisu) {
+		data[registryv1alpha1.SecretKeyMakisuYAML] = makisuConf.YAML()
+	}
+	sort.Strings(conflicts)
+	return data, conflicts, addBuilderConfig(cr, registries, data)
+}
+
+// setAuthConflictCondition reports hosts whose credentials are specified by multiple input Secrets
+func (r *ReconcileImageBuildEnv) setAuthConflictCondition(cr *registryv1alpha1.ImageBuildEnv, conflicts []string) error {
+	c := status.Condition{
+		Type:   registryv1alpha1.ConditionAuthConflict,
+		Status: corev1.ConditionFalse,
+	}
+	if len(conflicts) > 0 {
+		c.Status = corev1.ConditionTrue
+		c.Reason = registryv1alpha1.ReasonConflictingSecrets
+		c.Message = "conflicting credentials for " + strings.Join(conflicts, ", ")
+	}
+	if !cr.Status.Conditions.SetCondition(c) {
+		return nil
+	}
+	if c.IsTrue() {
+		r.recorder.Event(cr, corev1.EventTypeWarning, registryv1alpha1.EventReasonAuthConflict, c.Message)
+	}
+	return r.client.Status().Update(context.TODO(), cr)
+}
+
+// addBuilderConfig adds the per-registry CA certificates and the configuration of the ImageBuildEnv's non-makisu builders to the Secret data
+func addBuilderConfig(cr *registryv1alpha1.ImageBuildEnv, registryMap map[string]registriesconf.Registry, data map[string][]byte) error {
+	registries := make(registriesconf.Registries, 0, len(registryMap))
+	for _, reg := range registryMap {
+		registries = append(registries, reg)
+	}
+	registries.Sort()
+	if cr.Spec.HasBuilder(registryv1alpha1.ImageBuilderKaniko) {
+		data[secretKeyConfigJson] = registries.DockerConfig().JSON()
+		data[registryv1alpha1.SecretKeyKanikoArgs] = registries.KanikoArgs()
+	}
+	if cr.Spec.HasBuilder(registryv1alpha1.ImageBuilderBuildah) {
+		certsDir, err := json.Marshal(registries.ContainersCertsDir())
+		if err != nil {
+			return err
+		}
+		data[registryv1alpha1.SecretKeyAuthJSON] = registries.ContainersAuthJSON()
+		data[registryv1alpha1.SecretKeyRegistriesConf] = registries.ContainersRegistriesConf()
+		data[registryv1alpha1.SecretKeyCertsDir] = certsDir
+	}
+	if cr.Spec.HasBuilder(registryv1alpha1.ImageBuilderBuildKit) {
+		data[registryv1alpha1.SecretKeyBuildKitdTOML] = registries.BuildKitdTOML()
+	}
+	for k, v := range registries.CAFiles() {
+		data[k] = v
+	}
+	return nil
+}
+
+// SecretNameForCR returns the name of the merged Secret generated for an ImageBuildEnv
+func SecretNameForCR(cr *registryv1alpha1.ImageBuildEnv) string {
+	return "imagebuildenv-" + cr.Name + "-conf"
 }
 
 func dockerConfigFromSecret(secret *corev1.Secret) (*registriesconf.DockerConfig, error) {
@@ -293,137 +499,3 @@ func dockerConfigFromSecret(secret *corev1.Secret) (*registriesconf.DockerConfig
 	}
 	return registriesconf.ParseDockerConfig(configJson)
 }
-
-func (r *ReconcileImageBuildEnv) configureRedis(cr *registryv1alpha1.ImageBuildEnv, data map[string][]byte) (ready bool, err error) {
-	if cr.Spec.Redis {
-		var password []byte
-		password, err = r.upsertRedisSecretForCR(cr)
-		if err != nil {
-			return
-		}
-		data[registryv1alpha1.SecretKeyRedis] = []byte(fmt.Sprintf("%s:%d", redisNameForCR(cr), redisPort))
-		data[registryv1alpha1.SecretKeyRedisPassword] = password
-		ready, err = r.createRedisPodForCR(cr)
-		if err != nil {
-			return
-		}
-		err = r.upsertRedisServiceForCR(cr)
-	} else {
-		ready = true
-		// TODO: delete redis pod+svc
-	}
-	return
-}
-
-func (r *ReconcileImageBuildEnv) createRedisPodForCR(cr *registryv1alpha1.ImageBuildEnv) (ready bool, err error) {
-	pod := &corev1.Pod{}
-	podKey := types.NamespacedName{Name: redisNameForCR(cr), Namespace: cr.Namespace}
-	err = r.client.Get(context.TODO(), podKey, pod)
-	if err == nil || !errors.IsNotFound(err) {
-		return isPodReady(pod), err
-	}
-	pod.Name = podKey.Name
-	pod.Namespace = cr.Namespace
-	pod.Labels = redisLabelsForCR(cr)
-	merge.AddVolume(&pod.Spec, corev1.Volume{
-		Name: "redis-conf",
-		VolumeSource: corev1.VolumeSource{
-			Secret: &corev1.SecretVolumeSource{SecretName: pod.Name + "-conf"},
-		},
-	})
-	merge.AddContainer(&pod.Spec, corev1.Container{
-		Name:            "redis",
-		Image:           "redis:6-alpine",
-		ImagePullPolicy: corev1.PullIfNotPresent,
-		Args:            []string{"redis-server", "/conf/redis.conf"},
-		Ports: []corev1.ContainerPort{
-			{Name: redisPortName, ContainerPort: redisPort, Protocol: corev1.ProtocolTCP},
-		},
-		Env: []corev1.EnvVar{
-			{Name: "MASTER", Value: "true"},
-		},
-		ReadinessProbe: &corev1.Probe{
-			Handler: corev1.Handler{
-				TCPSocket: &corev1.TCPSocketAction{
-					Port: intstr.IntOrString{Type: intstr.Int, IntVal: redisPort},
-				},
-			},
-			InitialDelaySeconds: 3,
-			PeriodSeconds:       3,
-		},
-		LivenessProbe: &corev1.Probe{
-			Handler: corev1.Handler{
-				TCPSocket: &corev1.TCPSocketAction{
-					Port: intstr.IntOrString{Type: intstr.Int, IntVal: redisPort},
-				},
-			},
-			InitialDelaySeconds: 5,
-			PeriodSeconds:       30,
-		},
-		VolumeMounts: []corev1.VolumeMount{
-			{
-				Name:      "redis-conf",
-				MountPath: "/conf",
-			},
-		},
-	})
-	err = controllerutil.SetControllerReference(cr, pod, r.scheme)
-	if err != nil {
-		return
-	}
-	return false, r.client.Create(context.TODO(), pod)
-}
-
-func (r *ReconcileImageBuildEnv) upsertRedisServiceForCR(cr *registryv1alpha1.ImageBuildEnv) (err error) {
-	svc := &corev1.Service{}
-	svc.Name = redisNameForCR(cr)
-	svc.Namespace = cr.Namespace
-	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, svc, func() error {
-		svc.Spec.Selector = redisLabelsForCR(cr)
-		svc.Spec.Type = corev1.ServiceTypeClusterIP
-		merge.AddServicePort(svc, redisPortName, redisPort, redisPort, corev1.ProtocolTCP)
-		return controllerutil.SetControllerReference(cr, svc, r.scheme)
-	})
-	return
-}
-
-func (r *ReconcileImageBuildEnv) upsertRedisSecretForCR(cr *registryv1alpha1.ImageBuildEnv) (password []byte, err error) {
-	secret := &corev1.Secret{}
-	secret.Name = redisNameForCR(cr) + "-conf"
-	secret.Namespace = cr.Namespace
-	secret.Type = corev1.SecretTypeOpaque
-	err = controllerutil.SetControllerReference(cr, secret, r.scheme)
-	if err != nil {
-		return
-	}
-	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, secret, func() error {
-		if secret.Data != nil && len(secret.Data["redis_password"]) > 0 {
-			password = secret.Data[registryv1alpha1.SecretKeyRedisPassword]
-		} else {
-			password = passwordgen.GeneratePassword()
-		}
-		secret.Data = map[string][]byte{
-			registryv1alpha1.SecretKeyRedisPassword: password,
-			"redis.conf":                            []byte("requirepass " + string(password)),
-		}
-		return nil
-	})
-	return
-}
-
-func isPodReady(pod *corev1.Pod) bool {
-	for _, c := range pod.Status.Conditions {
-		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
-			return true
-		}
-	}
-	return false
-}
-
-func redisNameForCR(cr *registryv1alpha1.ImageBuildEnv) string {
-	return "imagebuildenv-" + cr.Name + "-redis"
-}
-
-func redisLabelsForCR(cr *registryv1alpha1.ImageBuildEnv) map[string]string {
-	return map[string]string{"app": redisNameForCR(cr)}
-}
@@ -0,0 +1,344 @@
+package imagebuildenv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registriesconf"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registryclient"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestMergeSecretData(t *testing.T) {
+	params := certs.KeyParams{Algorithm: certs.KeyAlgorithmECDSAP256}
+	ca1, err := certs.NewSelfSignedCAKeyPair("ca1", params)
+	require.NoError(t, err)
+	ca2, err := certs.NewSelfSignedCAKeyPair("ca2", params)
+	require.NoError(t, err)
+	dockerSecret := func(name string, ca []byte, conf *registriesconf.DockerConfig) *corev1.Secret {
+		s := &corev1.Secret{Type: corev1.SecretTypeDockerConfigJson, Data: map[string][]byte{corev1.DockerConfigJsonKey: conf.JSON()}}
+		s.Name = name
+		if ca != nil {
+			s.Data[registryv1alpha1.SecretKeyCaCert] = ca
+		}
+		return s
+	}
+	secrets := []*corev1.Secret{
+		dockerSecret("push", ca1.CertPEM(), (&registriesconf.DockerConfig{}).AddAuth("registry1.example.org", "user", "pw")),
+		dockerSecret("pull", ca2.CertPEM(), (&registriesconf.DockerConfig{}).AddAuth("registry2.example.org", "user", "pw").AddAuth("registry1.example.org", "other", "pw")),
+		dockerSecret("hub", nil, (&registriesconf.DockerConfig{}).AddAuth("https://index.docker.io/v1/", "user", "pw")),
+	}
+	cr := &registryv1alpha1.ImageBuildEnv{}
+	r := &ReconcileImageBuildEnv{}
+	data, conflicts, err := r.mergeSecretData(cr, secrets, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"registry1.example.org (secrets push and pull)"}, conflicts, "conflicts")
+	require.Equal(t, string(certs.MergeCertificates([][]byte{ca1.CertPEM(), ca2.CertPEM()})), string(data[registryv1alpha1.SecretKeyCaCert]), "CA bundle")
+	require.Equal(t, string(ca2.CertPEM()), string(data[registriesconf.CAFileName("registry1.example.org")]), "registry1 CA of the Secret that provides its credentials")
+	require.Equal(t, string(ca2.CertPEM()), string(data[registriesconf.CAFileName("registry2.example.org")]), "registry2 CA")
+
+	makisuConf := registriesconf.MakisuRegistries{}
+	err = yaml.Unmarshal(data[registryv1alpha1.SecretKeyMakisuYAML], &makisuConf)
+	require.NoError(t, err, "unmarshal makisu.yaml")
+	for registry, caFile := range map[string]string{
+		"registry1.example.org":       "/makisu-internal/config/ca_registry1.example.org.crt",
+		"registry2.example.org":       "/makisu-internal/config/ca_registry2.example.org.crt",
+		"https://index.docker.io/v1/": registriesconf.MakisuDefaultCACerts,
+	} {
+		tls := makisuConf[registry][".*"].Security.TLS
+		require.NotNil(t, tls, "makisu TLS of %s", registry)
+		require.Equal(t, caFile, tls.CA.Cert.Path, "makisu CA of %s", registry)
+	}
+}
+
+func TestMergeSecretDataWithCache(t *testing.T) {
+	dockerSecret := func(name string, conf *registriesconf.DockerConfig) *corev1.Secret {
+		s := &corev1.Secret{Type: corev1.SecretTypeDockerConfigJson, Data: map[string][]byte{corev1.DockerConfigJsonKey: conf.JSON()}}
+		s.Name = name
+		return s
+	}
+	secrets := []*corev1.Secret{
+		dockerSecret("push", (&registriesconf.DockerConfig{}).AddAuth("registry1.example.org", "user", "pw")),
+	}
+	cacheSecret := dockerSecret("cache", (&registriesconf.DockerConfig{}).AddAuth("registry1.example.org", "cacheuser", "pw").AddAuth("cache.example.org", "cacheuser", "pw"))
+	cr := &registryv1alpha1.ImageBuildEnv{}
+	cr.Name = "myenv"
+	cr.Namespace = "myns"
+	cr.Spec.Cache = &registryv1alpha1.ImageBuildCacheSpec{}
+	cr.Spec.Builders = []registryv1alpha1.ImageBuilder{registryv1alpha1.ImageBuilderKaniko, registryv1alpha1.ImageBuilderBuildKit}
+	cr.Status.Cache = &registryv1alpha1.ImageBuildCacheStatus{Registry: "cache.example.org", RepositoryPrefix: cacheRepositoryPrefix(cr)}
+	r := &ReconcileImageBuildEnv{}
+	data, conflicts, err := r.mergeSecretData(cr, secrets, cacheSecret)
+	require.NoError(t, err)
+	require.Empty(t, conflicts, "conflicts")
+	addCacheConfig(cr, data)
+
+	dockerConf, err := dockerConfigFromSecret(&corev1.Secret{Type: corev1.SecretTypeDockerConfigJson, Data: data})
+	require.NoError(t, err)
+	inputConf, err := dockerConfigFromSecret(secrets[0])
+	require.NoError(t, err)
+	require.Equal(t, inputConf.Auths["registry1.example.org"], dockerConf.Auths["registry1.example.org"], "input Secret credentials should take precedence over the cache account")
+	require.Contains(t, dockerConf.Auths, "cache.example.org", "cache registry credentials")
+	require.Equal(t, "cache.example.org/myns/myenv/cache", string(data[registryv1alpha1.SecretKeyCacheRepo]), registryv1alpha1.SecretKeyCacheRepo)
+	require.Contains(t, string(data[registryv1alpha1.SecretKeyKanikoArgs]), "--cache-repo=cache.example.org/myns/myenv/cache/kaniko\n", registryv1alpha1.SecretKeyKanikoArgs)
+	require.Equal(t, "--export-cache=type=registry,ref=cache.example.org/myns/myenv/cache/buildkit:cache,mode=max\n--import-cache=type=registry,ref=cache.example.org/myns/myenv/cache/buildkit:cache\n", string(data[registryv1alpha1.SecretKeyBuildKitArgs]), registryv1alpha1.SecretKeyBuildKitArgs)
+}
+
+func TestDeleteLegacyRedisPodForCR(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, registryv1alpha1.SchemeBuilder.AddToScheme(scheme))
+	for _, owned := range []bool{true, false} {
+		cr := &registryv1alpha1.ImageBuildEnv{}
+		cr.Name = "myenv"
+		cr.Namespace = "myns"
+		cr.UID = "myenv-uid"
+		pod := &corev1.Pod{}
+		pod.Name = redisNameForCR(cr)
+		pod.Namespace = cr.Namespace
+		if owned {
+			require.NoError(t, controllerutil.SetControllerReference(cr, pod, scheme))
+		}
+		r := &ReconcileImageBuildEnv{client: fake.NewFakeClientWithScheme(scheme, pod), scheme: scheme}
+		require.NoError(t, r.deleteLegacyRedisPodForCR(cr, logf.Log), "owned=%v", owned)
+		err := r.client.Get(context.TODO(), client.ObjectKey{Name: pod.Name, Namespace: pod.Namespace}, &corev1.Pod{})
+		if owned {
+			require.True(t, apierrors.IsNotFound(err), "owned Pod should be deleted but Get returned %v", err)
+		} else {
+			require.NoError(t, err, "Pod not owned by the ImageBuildEnv should be kept")
+		}
+	}
+}
+
+func TestLoadInputSecretsForCR(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	for _, c := range []struct {
+		name      string
+		namespace string
+		allowed   string
+		valid     bool
+	}{
+		{"same namespace", "myns", "", true},
+		{"default namespace", "", "", true},
+		{"other namespace without annotation", "infra", "", false},
+		{"other namespace not allowed", "infra", "otherns,thirdns", false},
+		{"other namespace allowed", "infra", "otherns, myns", true},
+		{"other namespace allows all", "infra", "*", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			secret := &corev1.Secret{}
+			secret.Name = "shared"
+			secret.Namespace = c.namespace
+			if secret.Namespace == "" {
+				secret.Namespace = "myns"
+			}
+			if c.allowed != "" {
+				secret.Annotations = map[string]string{registryv1alpha1.AnnotationAllowedNamespaces: c.allowed}
+			}
+			cr := &registryv1alpha1.ImageBuildEnv{}
+			cr.Name = "myenv"
+			cr.Namespace = "myns"
+			cr.Spec.Secrets = []registryv1alpha1.ImageSecretRef{{SecretName: secret.Name, Namespace: c.namespace}}
+			r := &ReconcileImageBuildEnv{client: fake.NewFakeClientWithScheme(scheme, secret), scheme: scheme}
+			secrets, err := r.loadInputSecretsForCR(cr)
+			if c.valid {
+				require.NoError(t, err)
+				require.Len(t, secrets, 1)
+				require.Equal(t, secret.Namespace, secrets[0].Namespace)
+			} else {
+				require.Error(t, err)
+				require.IsType(t, &secretNotAllowedError{}, err)
+			}
+		})
+	}
+}
+
+func TestCacheRepositoryPrefix(t *testing.T) {
+	cr := &registryv1alpha1.ImageBuildEnv{}
+	cr.Name = "myenv"
+	cr.Namespace = "myns"
+	cr.Spec.Cache = &registryv1alpha1.ImageBuildCacheSpec{}
+	for _, c := range []struct {
+		prefix   string
+		expected string
+	}{
+		{"", "myns/myenv/cache"},
+		{"myns/custom/", "myns/custom"},
+		{"custom", "myns/custom"},
+		{"otherns/cache", "myns/otherns/cache"},
+	} {
+		cr.Spec.Cache.RepositoryPrefix = c.prefix
+		require.Equal(t, c.expected, cacheRepositoryPrefix(cr), "prefix %q", c.prefix)
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	now := metav1.NewTime(time.Now().Truncate(time.Second))
+	maxAge := 7 * 24 * time.Hour
+	kaniko, buildkit := "myns/myenv/cache/kaniko", "myns/myenv/cache/buildkit"
+	reg := &fakeCacheRegistry{manifests: map[string]map[string]*registryclient.Manifest{}, configs: map[string]string{}}
+	reg.addImage(kaniko, "sha256:old", now.Add(-maxAge-time.Hour), "old", "old-alias")
+	reg.addImage(kaniko, "sha256:new", now.Add(-time.Hour), "new")
+	reg.addImage("other/app", "sha256:other", now.Add(-maxAge-time.Hour), "latest")
+	reg.addList(buildkit, "sha256:buildkit", "cache")
+	st := &registryv1alpha1.ImageBuildCacheStatus{
+		RepositoryPrefix: "myns/myenv/cache",
+		FirstSeen:        map[string]metav1.Time{kaniko + "@sha256:gone": now},
+	}
+
+	deleted, err := purgeCache(reg, st, maxAge, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted, "deleted manifests")
+	require.Equal(t, []string{kaniko + "@sha256:old"}, reg.deleted, "deleted manifests")
+	require.Equal(t, map[string]metav1.Time{buildkit + "@sha256:buildkit": now}, st.FirstSeen, "firstSeen")
+
+	st.FirstSeen[buildkit+"@sha256:buildkit"] = metav1.NewTime(now.Add(-maxAge))
+	deleted, err = purgeCache(reg, st, maxAge, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted, "deleted manifests")
+	require.Equal(t, []string{kaniko + "@sha256:old", buildkit + "@sha256:buildkit"}, reg.deleted, "deleted manifests")
+	require.Nil(t, st.FirstSeen, "firstSeen")
+}
+
+// TestPurgeCacheWithoutCatalogAccess purges the cache using the registry client
+// against a registry that denies the catalog scope as the cache account's ACL does.
+func TestPurgeCacheWithoutCatalogAccess(t *testing.T) {
+	now := metav1.NewTime(time.Now().Truncate(time.Second))
+	maxAge := 7 * 24 * time.Hour
+	repo := "myns/myenv/cache/kaniko"
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":%q,"digest":"sha256:config"}}`,
+		registryclient.MediaTypeManifestV2, registryclient.MediaTypeImageConfig)
+	var (
+		srv     *httptest.Server
+		scopes  []string
+		deleted []string
+	)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			scope := req.URL.Query().Get("scope")
+			scopes = append(scopes, scope)
+			if !strings.HasPrefix(scope, "repository:myns/myenv/cache/") {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "mytoken"})
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer mytoken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.Method + " " + req.URL.Path {
+		case "GET /v2/" + repo + "/tags/list":
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"old"}})
+		case "GET /v2/" + repo + "/manifests/old":
+			w.Header().Set("Content-Type", registryclient.MediaTypeManifestV2)
+			w.Header().Set("Docker-Content-Digest", "sha256:old")
+			w.Write([]byte(manifest))
+		case "GET /v2/" + repo + "/blobs/sha256:config":
+			fmt.Fprintf(w, `{"created":%q}`, now.Add(-maxAge-time.Hour).Format(time.RFC3339))
+		case "DELETE /v2/" + repo + "/manifests/sha256:old":
+			deleted = append(deleted, repo+"@sha256:old")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	c := registryclient.NewWithHTTPClient(u, registryclient.Credentials{Username: "myuser", Password: "mypasswd"}, srv.Client())
+	_, err = c.Catalog()
+	require.Error(t, err, "precondition: the registry should deny the catalog")
+	scopes = nil
+
+	st := &registryv1alpha1.ImageBuildCacheStatus{RepositoryPrefix: "myns/myenv/cache"}
+	n, err := purgeCache(c, st, maxAge, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "deleted manifests")
+	require.Equal(t, []string{repo + "@sha256:old"}, deleted, "deleted manifests")
+	for _, scope := range scopes {
+		require.NotEqual(t, "registry:catalog:*", scope, "requested scope")
+	}
+}
+
+type fakeCacheRegistry struct {
+	manifests map[string]map[string]*registryclient.Manifest
+	configs   map[string]string
+	deleted   []string
+}
+
+func (r *fakeCacheRegistry) addImage(repo, digest string, created time.Time, tags ...string) {
+	configDigest := digest + "-config"
+	r.configs[configDigest] = fmt.Sprintf(`{"created":%q}`, created.Format(time.RFC3339))
+	r.addManifest(repo, &registryclient.Manifest{
+		MediaType: registryclient.MediaTypeManifestV2,
+		Digest:    digest,
+		Blobs:     []registryclient.Descriptor{{MediaType: registryclient.MediaTypeImageConfig, Digest: configDigest}},
+	}, tags...)
+}
+
+func (r *fakeCacheRegistry) addList(repo, digest string, tags ...string) {
+	r.addManifest(repo, &registryclient.Manifest{MediaType: registryclient.MediaTypeOCIIndex, Digest: digest}, tags...)
+}
+
+func (r *fakeCacheRegistry) addManifest(repo string, m *registryclient.Manifest, tags ...string) {
+	if r.manifests[repo] == nil {
+		r.manifests[repo] = map[string]*registryclient.Manifest{}
+	}
+	for _, tag := range tags {
+		r.manifests[repo][tag] = m
+	}
+}
+
+func (r *fakeCacheRegistry) Tags(repo string) (tags []string, err error) {
+	for tag := range r.manifests[repo] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return
+}
+
+func (r *fakeCacheRegistry) Manifest(repo, ref string) (*registryclient.Manifest, error) {
+	if m := r.manifests[repo][ref]; m != nil {
+		return m, nil
+	}
+	return nil, &registryclient.HTTPError{StatusCode: 404}
+}
+
+func (r *fakeCacheRegistry) Blob(repo, digest string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(r.configs[digest])), nil
+}
+
+func (r *fakeCacheRegistry) DeleteManifest(repo, digest string) error {
+	for tag, m := range r.manifests[repo] {
+		if m.Digest == digest {
+			delete(r.manifests[repo], tag)
+		}
+	}
+	r.deleted = append(r.deleted, repo+"@"+digest)
+	return nil
+}
@@ -1,21 +1,58 @@
 package imagebuildenv
 
 import (
+	"strings"
+
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
 	"github.com/mgoltzsche/image-registry-operator/pkg/backrefs"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// annotationSecretRefs lists the ImageBuildEnvs that refer to an input Secret
+const annotationSecretRefs = "registry.mgoltzsche.github.com/imagebuildenvs"
+
+// secretReferences returns the strategy used to maintain back references
+// from input Secrets to the ImageBuildEnvs that use them.
+// Owner references previous versions set are removed since they would
+// let the garbage collector delete an input Secret with its last ImageBuildEnv.
+func secretReferences() backrefs.BackReferenceStrategy {
+	return &migratingReferences{backrefs.AnnotationReferences(annotationSecretRefs), backrefs.OwnerReferences()}
+}
+
+type migratingReferences struct {
+	backrefs.BackReferenceStrategy
+	legacy backrefs.BackReferenceStrategy
+}
+
+func (s *migratingReferences) AddReference(from metav1.Object, to backrefs.Object) bool {
+	changed := s.BackReferenceStrategy.AddReference(from, to)
+	return s.delLegacyReference(from, to) || changed
+}
+
+func (s *migratingReferences) DelReference(from metav1.Object, to backrefs.Object) bool {
+	changed := s.BackReferenceStrategy.DelReference(from, to)
+	return s.delLegacyReference(from, to) || changed
+}
+
+func (s *migratingReferences) delLegacyReference(from metav1.Object, to backrefs.Object) bool {
+	return from.GetNamespace() == to.GetNamespace() && s.legacy.DelReference(from, to)
+}
+
 type referenceOwner struct {
 	*registryv1alpha1.ImageBuildEnv
 }
 
 func (s *referenceOwner) GetStatusReferences() []backrefs.Object {
 	refs := make([]backrefs.Object, len(s.Status.SecretRefs))
-	for i, name := range s.Status.SecretRefs {
+	for i, ref := range s.Status.SecretRefs {
 		sec := &corev1.Secret{}
-		sec.Name = name
 		sec.Namespace = s.Namespace
+		sec.Name = ref
+		if nsName := strings.SplitN(ref, "/", 2); len(nsName) == 2 {
+			sec.Namespace = nsName[0]
+			sec.Name = nsName[1]
+		}
 		refs[i] = sec
 	}
 	return refs
@@ -25,6 +62,9 @@ func (s *referenceOwner) SetStatusReferences(refs []backrefs.Object) {
 	names := make([]string, len(refs))
 	for i, ref := range refs {
 		names[i] = ref.GetName()
+		if ref.GetNamespace() != s.Namespace {
+			names[i] = ref.GetNamespace() + "/" + names[i]
+		}
 	}
 	s.Status.SecretRefs = names
 }
@@ -0,0 +1,244 @@
+package imagebuildenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/merge"
+	"github.com/mgoltzsche/image-registry-operator/pkg/passwordgen"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	EnvImageRedis     = "OPERATOR_IMAGE_REDIS"
+	defaultImageRedis = "redis:6-alpine"
+	redisDataDir      = "/data"
+)
+
+func (r *ReconcileImageBuildEnv) configureRedis(cr *registryv1alpha1.ImageBuildEnv, data map[string][]byte, reqLogger logr.Logger) (ready bool, err error) {
+	if err = r.deleteLegacyRedisPodForCR(cr, reqLogger); err != nil {
+		return
+	}
+	if !cr.Spec.Redis {
+		return true, r.deleteRedisForCR(cr, reqLogger)
+	}
+	spec := cr.Spec.RedisConfig
+	if spec == nil {
+		spec = &registryv1alpha1.RedisSpec{}
+	}
+	password, err := r.upsertRedisSecretForCR(cr)
+	if err != nil {
+		return
+	}
+	data[registryv1alpha1.SecretKeyRedis] = []byte(fmt.Sprintf("%s:%d", redisNameForCR(cr), redisPort))
+	data[registryv1alpha1.SecretKeyRedisPassword] = password
+	if spec.PersistentVolumeClaim != nil {
+		err = r.upsertRedisPVCForCR(cr, spec.PersistentVolumeClaim)
+	} else {
+		err = r.deleteRedisResource(cr, &corev1.PersistentVolumeClaim{}, reqLogger)
+	}
+	if err != nil {
+		return
+	}
+	if ready, err = r.upsertRedisDeploymentForCR(cr, spec); err != nil {
+		return
+	}
+	err = r.upsertRedisServiceForCR(cr)
+	return
+}
+
+// deleteRedisForCR deletes all redis resources of the ImageBuildEnv
+func (r *ReconcileImageBuildEnv) deleteRedisForCR(cr *registryv1alpha1.ImageBuildEnv, reqLogger logr.Logger) error {
+	for _, o := range []runtime.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.PersistentVolumeClaim{}, &corev1.Secret{}} {
+		if err := r.deleteRedisResource(cr, o, reqLogger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteLegacyRedisPodForCR deletes the bare redis Pod previous operator versions created.
+// A Pod with the same name that is not owned by the ImageBuildEnv is left untouched.
+func (r *ReconcileImageBuildEnv) deleteLegacyRedisPodForCR(cr *registryv1alpha1.ImageBuildEnv, reqLogger logr.Logger) error {
+	return r.deleteRedisResource(cr, &corev1.Pod{}, reqLogger)
+}
+
+// deleteRedisResource deletes the given redis resource if it exists and is owned by the ImageBuildEnv
+func (r *ReconcileImageBuildEnv) deleteRedisResource(cr *registryv1alpha1.ImageBuildEnv, o runtime.Object, reqLogger logr.Logger) error {
+	name := redisNameForCR(cr)
+	if _, ok := o.(*corev1.Secret); ok {
+		name = redisSecretNameForCR(cr)
+	}
+	key := client.ObjectKey{Name: name, Namespace: cr.Namespace}
+	if err := r.client.Get(context.TODO(), key, o); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	m := o.(metav1.Object)
+	if !metav1.IsControlledBy(m, cr) {
+		return nil
+	}
+	reqLogger.Info(fmt.Sprintf("Deleting redis %T", o), "name", name)
+	return client.IgnoreNotFound(r.client.Delete(context.TODO(), o))
+}
+
+func (r *ReconcileImageBuildEnv) upsertRedisDeploymentForCR(cr *registryv1alpha1.ImageBuildEnv, spec *registryv1alpha1.RedisSpec) (ready bool, err error) {
+	image := spec.Image
+	if image == "" {
+		image = r.imageRedis
+	}
+	replicas := int32(1)
+	deploy := &appsv1.Deployment{}
+	deploy.Name = redisNameForCR(cr)
+	deploy.Namespace = cr.Namespace
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, deploy, func() error {
+		deploy.Labels = redisLabelsForCR(cr)
+		deploy.Spec.Replicas = &replicas
+		deploy.Spec.Selector = &metav1.LabelSelector{MatchLabels: redisLabelsForCR(cr)}
+		// Recreate since a ReadWriteOnce volume cannot be mounted by two Pods
+		deploy.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+		deploy.Spec.Template.Labels = redisLabelsForCR(cr)
+		podSpec := &deploy.Spec.Template.Spec
+		merge.AddVolume(podSpec, corev1.Volume{
+			Name: "redis-conf",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: redisSecretNameForCR(cr)},
+			},
+		})
+		dataVolume := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+		if spec.PersistentVolumeClaim != nil {
+			dataVolume = corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: redisNameForCR(cr)}}
+		}
+		merge.AddVolume(podSpec, corev1.Volume{Name: "redis-data", VolumeSource: dataVolume})
+		merge.AddContainer(podSpec, corev1.Container{
+			Name:            "redis",
+			Image:           image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Args:            []string{"redis-server", "/conf/redis.conf"},
+			Ports: []corev1.ContainerPort{
+				{Name: redisPortName, ContainerPort: redisPort, Protocol: corev1.ProtocolTCP},
+			},
+			Env: []corev1.EnvVar{
+				{Name: "MASTER", Value: "true"},
+			},
+			Resources: spec.Resources,
+			ReadinessProbe: &corev1.Probe{
+				Handler: corev1.Handler{
+					TCPSocket: &corev1.TCPSocketAction{
+						Port: intstr.IntOrString{Type: intstr.Int, IntVal: redisPort},
+					},
+				},
+				InitialDelaySeconds: 3,
+				PeriodSeconds:       3,
+			},
+			LivenessProbe: &corev1.Probe{
+				Handler: corev1.Handler{
+					TCPSocket: &corev1.TCPSocketAction{
+						Port: intstr.IntOrString{Type: intstr.Int, IntVal: redisPort},
+					},
+				},
+				InitialDelaySeconds: 5,
+				PeriodSeconds:       30,
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "redis-conf", MountPath: "/conf"},
+				{Name: "redis-data", MountPath: redisDataDir},
+			},
+		})
+		return controllerutil.SetControllerReference(cr, deploy, r.scheme)
+	})
+	return isDeploymentReady(deploy), err
+}
+
+func (r *ReconcileImageBuildEnv) upsertRedisPVCForCR(cr *registryv1alpha1.ImageBuildEnv, spec *registryv1alpha1.RedisPersistentVolumeClaimSpec) (err error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	pvc.Name = redisNameForCR(cr)
+	pvc.Namespace = cr.Namespace
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, pvc, func() error {
+		pvc.Labels = redisLabelsForCR(cr)
+		if spec.StorageClassName != nil {
+			pvc.Spec.StorageClassName = spec.StorageClassName
+		}
+		pvc.Spec.AccessModes = spec.AccessModes
+		if len(pvc.Spec.AccessModes) == 0 {
+			pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		}
+		pvc.Spec.Resources = spec.Resources
+		return controllerutil.SetControllerReference(cr, pvc, r.scheme)
+	})
+	return
+}
+
+func (r *ReconcileImageBuildEnv) upsertRedisServiceForCR(cr *registryv1alpha1.ImageBuildEnv) (err error) {
+	svc := &corev1.Service{}
+	svc.Name = redisNameForCR(cr)
+	svc.Namespace = cr.Namespace
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, svc, func() error {
+		svc.Spec.Selector = redisLabelsForCR(cr)
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		merge.AddServicePort(svc, redisPortName, redisPort, redisPort, corev1.ProtocolTCP)
+		return controllerutil.SetControllerReference(cr, svc, r.scheme)
+	})
+	return
+}
+
+func (r *ReconcileImageBuildEnv) upsertRedisSecretForCR(cr *registryv1alpha1.ImageBuildEnv) (password []byte, err error) {
+	secret := &corev1.Secret{}
+	secret.Name = redisSecretNameForCR(cr)
+	secret.Namespace = cr.Namespace
+	secret.Type = corev1.SecretTypeOpaque
+	err = controllerutil.SetControllerReference(cr, secret, r.scheme)
+	if err != nil {
+		return
+	}
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, secret, func() error {
+		if secret.Data != nil && len(secret.Data["redis_password"]) > 0 {
+			password = secret.Data[registryv1alpha1.SecretKeyRedisPassword]
+		} else {
+			password = passwordgen.GeneratePassword()
+		}
+		secret.Data = map[string][]byte{
+			registryv1alpha1.SecretKeyRedisPassword: password,
+			"redis.conf":                            []byte(fmt.Sprintf("requirepass %s\ndir %s\n", password, redisDataDir)),
+		}
+		return nil
+	})
+	return
+}
+
+// isDeploymentReady returns true if the Deployment's latest revision has been rolled out and is available
+func isDeploymentReady(deploy *appsv1.Deployment) bool {
+	st := deploy.Status
+	return st.ObservedGeneration >= deploy.Generation &&
+		deploy.Spec.Replicas != nil &&
+		st.UpdatedReplicas == *deploy.Spec.Replicas &&
+		st.AvailableReplicas == *deploy.Spec.Replicas &&
+		st.Replicas == *deploy.Spec.Replicas
+}
+
+func redisImage() string {
+	if image := os.Getenv(EnvImageRedis); image != "" {
+		return image
+	}
+	return defaultImageRedis
+}
+
+func redisNameForCR(cr *registryv1alpha1.ImageBuildEnv) string {
+	return "imagebuildenv-" + cr.Name + "-redis"
+}
+
+func redisSecretNameForCR(cr *registryv1alpha1.ImageBuildEnv) string {
+	return redisNameForCR(cr) + "-conf"
+}
+
+func redisLabelsForCR(cr *registryv1alpha1.ImageBuildEnv) map[string]string {
+	return map[string]string{"app": redisNameForCR(cr)}
+}
@@ -4,6 +4,7 @@ import (
 	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
 	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagesecret"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -20,6 +21,7 @@ const pushAccountLabel = "registry.mgoltzsche.github.com/imagepushsecret"
 func Add(mgr manager.Manager) error {
 	r := imagesecret.NewReconciler(mgr, log, imagesecret.ReconcileImageSecretConfig{
 		CRFactory:       func() registryapi.ImageSecretInterface { return &registryapi.ImagePushSecret{} },
+		CRListFactory:   func() runtime.Object { return &registryapi.ImagePushSecretList{} },
 		Intent:          registryapi.TypePush,
 		SecretType:      corev1.SecretTypeDockerConfigJson,
 		DockerConfigKey: corev1.DockerConfigJsonKey,
@@ -36,5 +38,5 @@ func Add(mgr manager.Manager) error {
 		return err
 	}
 
-	return imagesecret.WatchSecondaryResources(c, &registryapi.ImagePushSecret{}, pushAccountLabel)
+	return imagesecret.WatchSecondaryResources(mgr, c, r)
 }
@@ -1,11 +1,20 @@
 package imageregistry
 
 import (
+	"context"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
 	"github.com/mgoltzsche/image-registry-operator/pkg/backrefs"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -29,7 +38,7 @@ func Add(mgr manager.Manager) error {
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r *ReconcileImageRegistry) error {
 	// Create a new controller
 	c, err := controller.New("imageregistry-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
@@ -60,6 +69,33 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to secondary resource Ingress and requeue the owner ImageRegistry
+	err = c.Watch(&source.Kind{Type: &networkingv1beta1.Ingress{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageRegistry{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource NetworkPolicy and requeue the owner ImageRegistry
+	err = c.Watch(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageRegistry{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource PodDisruptionBudget and requeue the owner ImageRegistry
+	err = c.Watch(&source.Kind{Type: &policyv1beta1.PodDisruptionBudget{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageRegistry{},
+	})
+	if err != nil {
+		return err
+	}
+
 	// Watch for changes to secondary resource Secret and requeue the owner ImageRegistry
 	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
@@ -69,12 +105,32 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to secondary resource ServiceMonitor and requeue the owner ImageRegistry
+	if r.serviceMonitors {
+		err = c.Watch(&source.Kind{Type: &monitoringv1.ServiceMonitor{}}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &registryv1alpha1.ImageRegistry{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	// Watch for changes to secondary resource PersistentVolumeClaim and requeue the owner ImageRegistry
 	err = c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: pvcAnnotationToRequest})
 	if err != nil {
 		return err
 	}
 
+	// Watch for changes to secondary resource ConfigMap and requeue the owner ImageRegistry
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryv1alpha1.ImageRegistry{},
+	})
+	if err != nil {
+		return err
+	}
+
 	// Watch for changes to secondary resource ServiceAccount and requeue the owner ImageRegistry
 	err = c.Watch(&source.Kind{Type: &corev1.ServiceAccount{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
@@ -102,5 +158,73 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to certificate and notification headers Secrets that are not owned by the ImageRegistry
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: &secretRefToRequests{r.client}})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the root CA Secret and requeue all ImageRegistries to renew their certificates
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: &rootCAToRequests{r.client, certs.RootCASecretName()}})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// rootCAToRequests maps the root CA Secret to reconcile requests for all ImageRegistries
+type rootCAToRequests struct {
+	client client.Client
+	name   types.NamespacedName
+}
+
+func (m *rootCAToRequests) Map(o handler.MapObject) (r []reconcile.Request) {
+	if o.Meta == nil || o.Meta.GetName() != m.name.Name || o.Meta.GetNamespace() != m.name.Namespace {
+		return
+	}
+	list := &registryv1alpha1.ImageRegistryList{}
+	if err := m.client.List(context.TODO(), list); err != nil {
+		log.Error(err, "failed to list ImageRegistries")
+		return
+	}
+	for _, registry := range list.Items {
+		r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Name: registry.Name, Namespace: registry.Namespace}})
+	}
+	return
+}
+
+// secretRefToRequests maps a certificate or notification headers Secret to reconcile requests for the ImageRegistries that use it
+type secretRefToRequests struct {
+	client client.Client
+}
+
+func (m *secretRefToRequests) Map(o handler.MapObject) (r []reconcile.Request) {
+	if o.Meta == nil {
+		return
+	}
+	list := &registryv1alpha1.ImageRegistryList{}
+	if err := m.client.List(context.TODO(), list, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		log.Error(err, "failed to list ImageRegistries")
+		return
+	}
+	for i := range list.Items {
+		registry := &list.Items[i]
+		names := []string{tlsSecretNameForCR(registry), authCASecretNameForCR(registry)}
+		if registry.Spec.TLS.SecretName != nil {
+			names = append(names, *registry.Spec.TLS.SecretName)
+		}
+		for _, n := range registry.Spec.Notifications {
+			if n.HeadersSecretRef != nil {
+				names = append(names, n.HeadersSecretRef.Name)
+			}
+		}
+		for _, name := range names {
+			if name == o.Meta.GetName() {
+				r = append(r, reconcile.Request{NamespacedName: types.NamespacedName{Name: registry.Name, Namespace: registry.Namespace}})
+				break
+			}
+		}
+	}
+	return
+}
@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 
 	"github.com/go-logr/logr"
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
 	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -25,6 +29,10 @@ const (
 	EnvImageAuth     = "OPERATOR_IMAGE_AUTH"
 	EnvImageNginx    = "OPERATOR_IMAGE_NGINX"
 	EnvImageRegistry = "OPERATOR_IMAGE_REGISTRY"
+	// EnvNotificationsURL specifies the operator's registry notification receiver URL
+	EnvNotificationsURL = "OPERATOR_NOTIFICATIONS_URL"
+	// EnvNotificationsToken specifies the shared secret the registries authenticate with at the operator's notification receiver
+	EnvNotificationsToken = "OPERATOR_NOTIFICATIONS_TOKEN"
 )
 
 func DNSZone() string {
@@ -35,8 +43,35 @@ func DNSZone() string {
 	return dnsZone
 }
 
+// RegistryHostname returns the registry's primary external hostname
 func RegistryHostname(cr *registryv1alpha1.ImageRegistry, dnsZone string) string {
-	return fmt.Sprintf("%s.%s.%s", serviceNameForCR(cr), cr.Namespace, dnsZone)
+	return RegistryHostnames(cr, dnsZone)[0]
+}
+
+// RegistryHostnames returns the registry's primary external hostname followed by its aliases
+func RegistryHostnames(cr *registryv1alpha1.ImageRegistry, dnsZone string) []string {
+	if len(cr.Spec.Hostnames) > 0 {
+		return cr.Spec.Hostnames
+	}
+	if ingress := cr.Spec.Expose.Ingress; ingress != nil && ingress.Host != "" {
+		return []string{ingress.Host}
+	}
+	return []string{fmt.Sprintf("%s.%s.%s", serviceNameForCR(cr), cr.Namespace, dnsZone)}
+}
+
+// validateHostnames fails if a custom hostname is not a valid DNS-1123 subdomain
+// before it is used within certificates and the Ingress
+func (r *ReconcileImageRegistry) validateHostnames(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) error {
+	hostnames := instance.Spec.Hostnames
+	if ingress := instance.Spec.Expose.Ingress; ingress != nil && ingress.Host != "" {
+		hostnames = append([]string{ingress.Host}, hostnames...)
+	}
+	for _, hostname := range hostnames {
+		if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+			return fmt.Errorf("invalid hostname %q: %s", hostname, strings.Join(errs, ", "))
+		}
+	}
+	return nil
 }
 
 // blank assignment to verify that ReconcileImageRegistry implements reconcile.Reconciler
@@ -48,26 +83,41 @@ type ReconcileImageRegistry struct {
 	// that reads objects from the cache and writes to the apiserver
 	client         client.Client
 	scheme         *runtime.Scheme
+	recorder       record.EventRecorder
 	certManager    *certs.CertManager
 	reconcileTasks []reconcileTask
 	dnsZone        string
 	imageAuth      string
 	imageNginx     string
 	imageRegistry  string
+	// notificationsURL is the operator's notification receiver URL (optional)
+	notificationsURL string
+	// notificationsToken is the bearer token the registries send to the notification receiver
+	notificationsToken string
+	// serviceMonitors is true if the prometheus-operator's ServiceMonitor API is available
+	serviceMonitors bool
+	// certManagerAPIVersion is the cert-manager API version served by the cluster or empty if cert-manager is not installed
+	certManagerAPIVersion string
 }
 
 type reconcileTask func(*registryv1alpha1.ImageRegistry, logr.Logger) error
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager) *ReconcileImageRegistry {
+	recorder := mgr.GetEventRecorderFor("imageregistry-controller")
 	r := &ReconcileImageRegistry{
-		client:        mgr.GetClient(),
-		scheme:        mgr.GetScheme(),
-		certManager:   certs.NewCertManager(mgr.GetClient(), mgr.GetScheme(), certs.RootCASecretName()),
-		dnsZone:       DNSZone(),
-		imageAuth:     os.Getenv(EnvImageAuth),
-		imageNginx:    os.Getenv(EnvImageNginx),
-		imageRegistry: os.Getenv(EnvImageRegistry),
+		client:                mgr.GetClient(),
+		scheme:                mgr.GetScheme(),
+		recorder:              recorder,
+		certManager:           certs.NewCertManager(mgr.GetClient(), mgr.GetScheme(), recorder, certs.RootCASecretName()),
+		dnsZone:               DNSZone(),
+		imageAuth:             os.Getenv(EnvImageAuth),
+		imageNginx:            os.Getenv(EnvImageNginx),
+		imageRegistry:         os.Getenv(EnvImageRegistry),
+		notificationsURL:      os.Getenv(EnvNotificationsURL),
+		notificationsToken:    os.Getenv(EnvNotificationsToken),
+		serviceMonitors:       serviceMonitorsAvailable(mgr.GetConfig()),
+		certManagerAPIVersion: certManagerAPIVersion(mgr.GetConfig()),
 	}
 	if r.imageAuth == "" {
 		r.imageAuth = "mgoltzsche/image-registry-operator:latest-auth"
@@ -78,14 +128,28 @@ func newReconciler(mgr manager.Manager) reconcile.Reconciler {
 	if r.imageRegistry == "" {
 		r.imageRegistry = "registry:2"
 	}
+	if r.notificationsURL != "" && r.notificationsToken == "" {
+		panic(fmt.Sprintf("%s must be specified when %s is set", EnvNotificationsToken, EnvNotificationsURL))
+	}
 	r.reconcileTasks = []reconcileTask{
+		r.validateHostnames,
+		r.validateStorageTopology,
 		r.reconcileTokenCert,
 		r.reconcileTLSCert,
+		r.reconcileCertificateStatus,
 		r.reconcileServiceAccount,
 		r.reconcileRole,
 		r.reconcileRoleBinding,
 		r.reconcileService,
+		r.reconcileMetricsService,
+		r.reconcileServiceMonitor,
+		r.reconcileIngress,
+		r.reconcileNotifications,
+		r.reconcileHTTPSecret,
+		r.reconcileMaintenance,
 		r.reconcileStatefulSet,
+		r.reconcilePodDisruptionBudget,
+		r.reconcileNetworkPolicy,
 		r.reconcilePersistentVolumeClaim,
 	}
 	return r
@@ -108,6 +172,7 @@ func (r *ReconcileImageRegistry) Reconcile(request reconcile.Request) (reconcile
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			deleteCertMetrics(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -116,6 +181,7 @@ func (r *ReconcileImageRegistry) Reconcile(request reconcile.Request) (reconcile
 
 	conditions := instance.Status.Conditions
 	instance.Status.Conditions = map[status.ConditionType]status.Condition{}
+	observedStatus := observedStatusOf(instance)
 
 	// Run reconcile tasks (may write ImageRegistry conditions)
 	for _, task := range r.reconcileTasks {
@@ -140,7 +206,15 @@ func (r *ReconcileImageRegistry) Reconcile(request reconcile.Request) (reconcile
 			Status: corev1.ConditionFalse,
 			Reason: registryv1alpha1.ReasonFailedSync,
 		})
+	} else if certCond := instance.Status.Conditions.GetCondition(registryv1alpha1.ConditionCertificateReady); certCond != nil && certCond.Status == corev1.ConditionFalse {
+		instance.Status.Conditions.SetCondition(status.Condition{
+			Type:    registryv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  registryv1alpha1.ReasonCertificateNotReady,
+			Message: certCond.Message,
+		})
 	}
+	wasReady := conditions.IsTrueFor(registryv1alpha1.ConditionReady)
 	changedCond := false
 	for _, c := range instance.Status.Conditions {
 		if conditions.SetCondition(c) {
@@ -148,12 +222,14 @@ func (r *ReconcileImageRegistry) Reconcile(request reconcile.Request) (reconcile
 		}
 	}
 	instance.Status.Conditions = conditions
+	r.recordReadinessTransition(instance, wasReady)
 	hostname := r.externalHostnameForCR(instance)
 	tlsSecretName := tlsSecretNameForCR(instance)
 	changedGeneration := instance.Status.ObservedGeneration != instance.Generation
 	changedHost := instance.Status.Hostname != hostname
 	changedTLSSecretName := instance.Status.TLSSecretName != tlsSecretName
-	if changedCond || changedGeneration || changedHost || changedTLSSecretName {
+	changedStatus := !equality.Semantic.DeepEqual(observedStatus, observedStatusOf(instance))
+	if changedCond || changedGeneration || changedHost || changedTLSSecretName || changedStatus {
 		instance.Status.ObservedGeneration = instance.Generation
 		instance.Status.Hostname = hostname
 		instance.Status.TLSSecretName = tlsSecretName
@@ -162,7 +238,13 @@ func (r *ReconcileImageRegistry) Reconcile(request reconcile.Request) (reconcile
 		}
 	}
 
-	return reconcile.Result{}, err
+	return reconcile.Result{RequeueAfter: maintenanceRequeueAfter(instance, certRequeueAfter(instance))}, err
+}
+
+// observedStatusOf returns the status fields that are derived from other resources for comparison
+func observedStatusOf(cr *registryv1alpha1.ImageRegistry) []interface{} {
+	return []interface{}{cr.Status.Replicas, cr.Status.ReadyReplicas, cr.Status.Pods, cr.Status.CARotation,
+		cr.Status.TLSCertificate, cr.Status.AuthCACertificate}
 }
 
 type namespacedObject interface {
@@ -195,6 +277,23 @@ func (r *ReconcileImageRegistry) upsert(owner *registryv1alpha1.ImageRegistry, o
 	return
 }
 
+// recordReadinessTransition emits an Event when the registry became ready or unready
+func (r *ReconcileImageRegistry) recordReadinessTransition(instance *registryv1alpha1.ImageRegistry, wasReady bool) {
+	ready := instance.Status.Conditions.GetCondition(registryv1alpha1.ConditionReady)
+	if ready == nil || ready.IsTrue() == wasReady {
+		return
+	}
+	if ready.IsTrue() {
+		r.recorder.Event(instance, corev1.EventTypeNormal, registryv1alpha1.EventReasonRegistryReady, "Registry is ready")
+	} else {
+		msg := ready.Message
+		if msg == "" {
+			msg = string(ready.Reason)
+		}
+		r.recorder.Eventf(instance, corev1.EventTypeWarning, registryv1alpha1.EventReasonRegistryNotReady, "Registry became unready: %s", msg)
+	}
+}
+
 func logOperation(log logr.Logger, verb string, o metav1.Object) {
 	kind := reflect.TypeOf(o).Elem().Name()
 	msg := fmt.Sprintf("%s %s", verb, kind)
@@ -205,6 +304,15 @@ func (r *ReconcileImageRegistry) externalHostnameForCR(cr *registryv1alpha1.Imag
 	return RegistryHostname(cr, r.dnsZone)
 }
 
+func (r *ReconcileImageRegistry) externalHostnamesForCR(cr *registryv1alpha1.ImageRegistry) []string {
+	return RegistryHostnames(cr, r.dnsZone)
+}
+
+// PodLabelsForCR returns the labels that select the registry's Pods
+func PodLabelsForCR(cr *registryv1alpha1.ImageRegistry) map[string]string {
+	return selectorLabelsForCR(cr)
+}
+
 func selectorLabelsForCR(cr *registryv1alpha1.ImageRegistry) map[string]string {
 	return map[string]string{"app": "imageregistry-" + cr.Name}
 }
@@ -213,7 +321,27 @@ func serviceNameForCR(cr *registryv1alpha1.ImageRegistry) string {
 	return cr.Name
 }
 
-func pvcNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+func serviceTypeForCR(cr *registryv1alpha1.ImageRegistry) corev1.ServiceType {
+	if t := cr.Spec.Expose.ServiceType; t != "" {
+		return t
+	}
+	return corev1.ServiceTypeLoadBalancer
+}
+
+func ingressNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name
+}
+
+func pdbNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name
+}
+
+func httpSecretNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name + "-http-secret"
+}
+
+// PVCNameForCR returns the name of the PersistentVolumeClaim holding the registry's images
+func PVCNameForCR(cr *registryv1alpha1.ImageRegistry) string {
 	return "imageregistry-" + cr.Name + "-pvc"
 }
 
@@ -223,7 +351,7 @@ func tlsCertNameForCR(cr *registryv1alpha1.ImageRegistry) string {
 
 func tlsSecretNameForCR(cr *registryv1alpha1.ImageRegistry) string {
 	name := cr.Spec.TLS.SecretName
-	if name != nil {
+	if name != nil && (cr.Spec.TLS.IssuerRef != nil || cr.Spec.TLS.SecretMode != registryv1alpha1.CertificateSecretModeIntermediateCA) {
 		return *name
 	}
 	if cr.Spec.TLS.IssuerRef == nil {
@@ -0,0 +1,139 @@
+package imageregistry
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	certManagerGroup       = "cert-manager.io"
+	certManagerCertificate = "Certificate"
+)
+
+// certManagerVersions lists the supported cert-manager API versions in order of preference
+var certManagerVersions = []string{"v1", "v1alpha3", "v1alpha2"}
+
+// certificateParams specifies a cert-manager Certificate independent of its API version
+type certificateParams struct {
+	Labels     map[string]string
+	IsCA       bool
+	CommonName string
+	DNSNames   []string
+	SecretName string
+	Issuer     *registryv1alpha1.CertIssuerRefSpec
+	Key        certs.KeyParams
+}
+
+// certManagerAPIVersion returns the preferred cert-manager API version served by the cluster
+// or an empty string if cert-manager is not installed
+func certManagerAPIVersion(cfg *rest.Config) string {
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err == nil {
+		for _, version := range certManagerVersions {
+			gv := schema.GroupVersion{Group: certManagerGroup, Version: version}
+			var available bool
+			if available, err = k8sutil.ResourceExists(dc, gv.String(), certManagerCertificate); err != nil {
+				break
+			}
+			if available {
+				return gv.String()
+			}
+		}
+	}
+	if err != nil {
+		log.Error(err, "failed to discover cert-manager API")
+	}
+	return ""
+}
+
+// upsertCertificate maintains a cert-manager Certificate using the API version detected at startup
+func (r *ReconcileImageRegistry) upsertCertificate(instance *registryv1alpha1.ImageRegistry, name string, p certificateParams, reqLogger logr.Logger) error {
+	spec, err := certManagerCertificateSpec(r.certManagerAPIVersion, p)
+	if err != nil {
+		return err
+	}
+	cert := &unstructured.Unstructured{}
+	cert.SetAPIVersion(r.certManagerAPIVersion)
+	cert.SetKind(certManagerCertificate)
+	cert.SetName(name)
+	cert.SetNamespace(instance.Namespace)
+	return r.upsert(instance, cert, reqLogger, func() error {
+		cert.SetLabels(p.Labels)
+		return unstructured.SetNestedField(cert.Object, spec, "spec")
+	})
+}
+
+// certManagerUnavailableCondition is set when a certificate refers to an issuer but cert-manager is not installed
+func certManagerUnavailableCondition(condType status.ConditionType) status.Condition {
+	return status.Condition{
+		Type:    condType,
+		Status:  corev1.ConditionFalse,
+		Reason:  registryv1alpha1.ReasonCertManagerUnavailable,
+		Message: "issuerRef specified but the cert-manager API is not available (the operator must be restarted after cert-manager has been installed)",
+	}
+}
+
+// certManagerCertificateSpec returns the spec of a cert-manager Certificate of the given API version
+func certManagerCertificateSpec(apiVersion string, p certificateParams) (map[string]interface{}, error) {
+	dnsNames := make([]interface{}, len(p.DNSNames))
+	for i, name := range p.DNSNames {
+		dnsNames[i] = name
+	}
+	issuerRef := map[string]interface{}{
+		"name": p.Issuer.Name,
+		"kind": p.Issuer.Kind,
+	}
+	if p.Issuer.Group != "" {
+		issuerRef["group"] = p.Issuer.Group
+	}
+	spec := map[string]interface{}{
+		"isCA":       p.IsCA,
+		"commonName": p.CommonName,
+		"secretName": p.SecretName,
+		"issuerRef":  issuerRef,
+		"duration":   p.Key.Validity.String(),
+	}
+	if len(dnsNames) > 0 {
+		spec["dnsNames"] = dnsNames
+	}
+	if p.Key.RenewBefore > 0 {
+		spec["renewBefore"] = p.Key.RenewBefore.String()
+	}
+	var algorithm string
+	var size int64
+	switch p.Key.Algorithm {
+	case certs.KeyAlgorithmRSA:
+		algorithm, size = "RSA", int64(p.Key.RSAKeySize)
+	case certs.KeyAlgorithmECDSAP256:
+		algorithm, size = "ECDSA", 256
+	case certs.KeyAlgorithmECDSAP384:
+		algorithm, size = "ECDSA", 384
+	case certs.KeyAlgorithmEd25519:
+		algorithm = "Ed25519"
+	}
+	switch {
+	case apiVersion == certManagerGroup+"/v1" && algorithm != "":
+		privateKey := map[string]interface{}{"algorithm": algorithm}
+		if size > 0 {
+			privateKey["size"] = size
+		}
+		spec["privateKey"] = privateKey
+	case apiVersion != certManagerGroup+"/v1" && algorithm != "" && size > 0:
+		// pre-v1 APIs specify the key on the top level using lower case algorithm names
+		spec["keyAlgorithm"] = map[string]string{"RSA": "rsa", "ECDSA": "ecdsa"}[algorithm]
+		spec["keySize"] = size
+	default:
+		return nil, fmt.Errorf("key algorithm %q is not supported by cert-manager %s", p.Key.Algorithm, apiVersion)
+	}
+	return spec, nil
+}
@@ -0,0 +1,73 @@
+package imageregistry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestUpsertCertificateLabels(t *testing.T) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = "myregistry"
+	cr.Namespace = "myns"
+	scheme := runtime.NewScheme()
+	require.NoError(t, registryv1alpha1.SchemeBuilder.AddToScheme(scheme))
+	r := &ReconcileImageRegistry{client: fake.NewFakeClientWithScheme(scheme), scheme: scheme, certManagerAPIVersion: "cert-manager.io/v1"}
+	p := certificateParams{
+		Labels:     selectorLabelsForCR(cr),
+		CommonName: "registry.example.org",
+		SecretName: "myregistry-tls",
+		Issuer:     &registryv1alpha1.CertIssuerRefSpec{Name: "ca", Kind: "Issuer"},
+		Key:        certs.KeyParams{Algorithm: certs.KeyAlgorithmECDSAP256, Validity: time.Hour},
+	}
+	require.NoError(t, r.upsertCertificate(cr, "myregistry-tls", p, logf.Log))
+	cert := &unstructured.Unstructured{}
+	cert.SetAPIVersion(r.certManagerAPIVersion)
+	cert.SetKind(certManagerCertificate)
+	require.NoError(t, r.client.Get(context.TODO(), client.ObjectKey{Name: "myregistry-tls", Namespace: cr.Namespace}, cert))
+	require.Equal(t, selectorLabelsForCR(cr), cert.GetLabels(), "labels")
+	require.Equal(t, "myregistry-tls", cert.Object["spec"].(map[string]interface{})["secretName"], "secretName")
+}
+
+func TestCertManagerCertificateSpec(t *testing.T) {
+	p := certificateParams{
+		CommonName: "registry.example.org",
+		DNSNames:   []string{"registry.example.org"},
+		SecretName: "registry-tls",
+		Issuer:     &registryv1alpha1.CertIssuerRefSpec{Name: "step", Kind: "StepIssuer", Group: "certmanager.step.sm"},
+		Key:        certs.KeyParams{Algorithm: certs.KeyAlgorithmECDSAP256, Validity: 48 * time.Hour, RenewBefore: 12 * time.Hour},
+	}
+
+	spec, err := certManagerCertificateSpec("cert-manager.io/v1", p)
+	require.NoError(t, err, "v1")
+	require.Equal(t, map[string]interface{}{"algorithm": "ECDSA", "size": int64(256)}, spec["privateKey"], "v1 privateKey")
+	require.Nil(t, spec["keyAlgorithm"], "v1 keyAlgorithm")
+	require.Equal(t, map[string]interface{}{"name": "step", "kind": "StepIssuer", "group": "certmanager.step.sm"}, spec["issuerRef"], "issuerRef")
+	require.Equal(t, "48h0m0s", spec["duration"], "duration")
+	require.Equal(t, "12h0m0s", spec["renewBefore"], "renewBefore")
+	require.Equal(t, []interface{}{"registry.example.org"}, spec["dnsNames"], "dnsNames")
+
+	spec, err = certManagerCertificateSpec("cert-manager.io/v1alpha3", p)
+	require.NoError(t, err, "v1alpha3")
+	require.Equal(t, "ecdsa", spec["keyAlgorithm"], "v1alpha3 keyAlgorithm")
+	require.Equal(t, int64(256), spec["keySize"], "v1alpha3 keySize")
+	require.Nil(t, spec["privateKey"], "v1alpha3 privateKey")
+
+	p.Issuer = &registryv1alpha1.CertIssuerRefSpec{Name: "ca", Kind: "Issuer"}
+	p.Key = certs.KeyParams{Algorithm: certs.KeyAlgorithmEd25519, Validity: time.Hour}
+	spec, err = certManagerCertificateSpec("cert-manager.io/v1", p)
+	require.NoError(t, err, "v1 Ed25519")
+	require.Equal(t, map[string]interface{}{"algorithm": "Ed25519"}, spec["privateKey"], "v1 Ed25519 privateKey")
+	require.Equal(t, map[string]interface{}{"name": "ca", "kind": "Issuer"}, spec["issuerRef"], "default issuer group")
+	_, err = certManagerCertificateSpec("cert-manager.io/v1alpha3", p)
+	require.Error(t, err, "v1alpha3 Ed25519")
+}
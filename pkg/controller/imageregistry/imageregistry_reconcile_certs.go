@@ -1,81 +1,239 @@
 package imageregistry
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
-	certmgr "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha3"
-	certmgrmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
 func (r *ReconcileImageRegistry) reconcileTokenCert(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	spec := &instance.Spec.Auth.CA
 	secretName := authCASecretNameForCR(instance)
 	commonName := fmt.Sprintf("%s.%s.svc", instance.Name, instance.Namespace)
 	labels := selectorLabelsForCR(instance)
-	authTokenCaIssuer := instance.Spec.Auth.CA.IssuerRef
-	if authTokenCaIssuer != nil {
-		caCertCR := &certmgr.Certificate{}
-		caCertCR.Name = authCACertNameForCR(instance)
-		caCertCR.Namespace = instance.Namespace
-		err = r.upsert(instance, caCertCR, reqLogger, func() error {
-			caCertCR.Labels = labels
-			caCertCR.Spec = certmgr.CertificateSpec{
-				IsCA:       true,
-				Duration:   &metav1.Duration{Duration: 24 * 365 * 5 * time.Hour},
-				CommonName: commonName,
-				SecretName: secretName,
-				IssuerRef:  toObjectReference(authTokenCaIssuer),
-			}
-			return nil
+	params := r.certManager.TokenCAKeyParams(keyParamsForSpec(spec))
+	if err = params.Validate(); err != nil {
+		return fmt.Errorf("auth token CA: %w", err)
+	}
+	// docker_auth derives the JWT signature algorithm from the key
+	if _, err = certs.JWTAlgorithm(params.Algorithm); err != nil {
+		return fmt.Errorf("auth token CA: %w", err)
+	}
+	key := types.NamespacedName{Name: secretName, Namespace: instance.Namespace}
+	cond := status.Condition{Type: registryv1alpha1.ConditionAuthCACertificate}
+	var cert *certs.KeyPair
+	switch {
+	case spec.IssuerRef != nil:
+		if r.certManagerAPIVersion == "" {
+			cond = certManagerUnavailableCondition(cond.Type)
+			break
+		}
+		err = r.upsertCertificate(instance, authCACertNameForCR(instance), certificateParams{
+			Labels:     labels,
+			IsCA:       true,
+			CommonName: commonName,
+			SecretName: secretName,
+			Issuer:     spec.IssuerRef,
+			Key:        params,
+		}, reqLogger)
+		cond = issuerCertCondition(cond.Type, secretName)
+	case spec.SecretName == nil:
+		cond.Reason = registryv1alpha1.ReasonCertificateGenerated
+		cert, err = r.certManager.RenewCACertSecret(key, instance, labels, commonName, params)
+	case spec.SecretMode == registryv1alpha1.CertificateSecretModeAdopt:
+		cond.Reason = registryv1alpha1.ReasonCertificateAdopted
+		if err = r.certManager.AdoptSecret(key); err == nil {
+			// Adopted Secrets are not owned by the ImageRegistry to survive its deletion
+			cert, err = r.certManager.RenewCACertSecret(key, nil, labels, commonName, params)
+		}
+	case spec.SecretMode == registryv1alpha1.CertificateSecretModeIntermediateCA:
+		cond.Reason = registryv1alpha1.ReasonInvalidCertificate
+		err = fmt.Errorf("auth token CA: secretMode %s is not supported", spec.SecretMode)
+	default:
+		cond = r.externalCertCondition(cond.Type, key, nil, func(cert *certs.KeyPair) error {
+			_, err := certs.JWTAlgorithm(cert.Algorithm())
+			return err
 		})
-	} else if instance.Spec.Auth.CA.SecretName == nil {
-		key := types.NamespacedName{Name: secretName, Namespace: instance.Namespace}
-		_, err = r.certManager.RenewCACertSecret(key, instance, labels, commonName)
 	}
+	instance.Status.Conditions.SetCondition(managedCertCondition(cond, cert, err))
 	return
 }
 
 func (r *ReconcileImageRegistry) reconcileTLSCert(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	spec := &instance.Spec.TLS
 	secretName := tlsSecretNameForCR(instance)
 	dnsNames := r.dnsNamesForCR(instance)
 	labels := selectorLabelsForCR(instance)
-	tlsIssuer := instance.Spec.TLS.IssuerRef
-	if tlsIssuer != nil {
-		tlsCertCR := &certmgr.Certificate{}
-		tlsCertCR.Name = tlsCertNameForCR(instance)
-		tlsCertCR.Namespace = instance.Namespace
-		err = r.upsert(instance, tlsCertCR, reqLogger, func() error {
-			tlsCertCR.Labels = labels
-			tlsCertCR.Spec = certmgr.CertificateSpec{
-				IsCA:        false,
-				Duration:    &metav1.Duration{Duration: 24 * 90 * time.Hour},
-				RenewBefore: &metav1.Duration{Duration: 24 * 20 * time.Hour},
-				CommonName:  dnsNames[0],
-				DNSNames:    dnsNames,
-				SecretName:  secretName,
-				IssuerRef:   toObjectReference(tlsIssuer),
+	instance.Status.CARotation = nil
+	params := r.certManager.ServerCertKeyParams(keyParamsForSpec(spec))
+	if err = params.Validate(); err != nil {
+		return fmt.Errorf("TLS certificate: %w", err)
+	}
+	key := types.NamespacedName{Name: secretName, Namespace: instance.Namespace}
+	cond := status.Condition{Type: registryv1alpha1.ConditionTLSCertificate}
+	var cert, ca *certs.KeyPair
+	switch {
+	case spec.IssuerRef != nil:
+		if r.certManagerAPIVersion == "" {
+			cond = certManagerUnavailableCondition(cond.Type)
+			break
+		}
+		if spec.RenewBefore == nil {
+			params.RenewBefore = 24 * 20 * time.Hour
+		}
+		err = r.upsertCertificate(instance, tlsCertNameForCR(instance), certificateParams{
+			Labels:     labels,
+			CommonName: dnsNames[0],
+			DNSNames:   dnsNames,
+			SecretName: secretName,
+			Issuer:     spec.IssuerRef,
+			Key:        params,
+		}, reqLogger)
+		cond = issuerCertCondition(cond.Type, secretName)
+	case spec.SecretName == nil:
+		cond.Reason = registryv1alpha1.ReasonCertificateGenerated
+		if ca, err = r.certManager.SigningCA(); err == nil {
+			cert, err = r.certManager.RenewServerCertSecret(key, instance, labels, dnsNames, ca, params)
+		}
+	case spec.SecretMode == registryv1alpha1.CertificateSecretModeIntermediateCA:
+		cond.Reason = registryv1alpha1.ReasonCertificateIntermediateCA
+		caKey := types.NamespacedName{Name: *spec.SecretName, Namespace: instance.Namespace}
+		intermediateCA, e := r.certManager.IntermediateCA(caKey)
+		if err = e; err == nil {
+			cert, err = r.certManager.RenewServerCertSecret(key, instance, labels, dnsNames, intermediateCA, params)
+		}
+	case spec.SecretMode == registryv1alpha1.CertificateSecretModeAdopt:
+		cond.Reason = registryv1alpha1.ReasonCertificateAdopted
+		if err = r.certManager.AdoptSecret(key); err == nil {
+			if ca, err = r.certManager.SigningCA(); err == nil {
+				// Adopted Secrets are not owned by the ImageRegistry to survive its deletion
+				cert, err = r.certManager.RenewAdoptedServerCertSecret(key, labels, dnsNames, ca, params)
 			}
-			return nil
-		})
-	} else if instance.Spec.TLS.SecretName == nil {
-		ca, e := r.certManager.RootCACert()
-		if e != nil {
-			return e
 		}
-		key := types.NamespacedName{Name: secretName, Namespace: instance.Namespace}
-		_, err = r.certManager.RenewServerCertSecret(key, instance, labels, dnsNames, ca)
+	default:
+		cond = r.externalCertCondition(cond.Type, key, r.externalHostnamesForCR(instance), nil)
+	}
+	if err == nil && ca != nil {
+		instance.Status.CARotation, err = r.caRotationStatus(cert)
 	}
+	instance.Status.Conditions.SetCondition(managedCertCondition(cond, cert, err))
 	return
 }
 
+// managedCertCondition completes the condition of a certificate managed by the operator
+func managedCertCondition(cond status.Condition, cert *certs.KeyPair, err error) status.Condition {
+	switch {
+	case err != nil:
+		cond.Status = corev1.ConditionFalse
+		cond.Message = err.Error()
+		if errors.Is(err, certs.ErrUnmanagedValidSecretExists) || errors.Is(err, certs.ErrUnmanagedInvalidSecretExists) {
+			cond.Reason = registryv1alpha1.ReasonUnmanagedSecret
+			cond.Message += " (refer to it using secretMode Adopt to let the operator take it over)"
+		} else if apierrors.IsNotFound(err) {
+			cond.Reason = registryv1alpha1.ReasonSecretNotFound
+		}
+	case cert != nil:
+		cond.Status = corev1.ConditionTrue
+		cond.Message = fmt.Sprintf("valid until %s", cert.NotAfter().Format(time.RFC3339))
+	}
+	return cond
+}
+
+func issuerCertCondition(condType status.ConditionType, secretName string) status.Condition {
+	return status.Condition{
+		Type:    condType,
+		Status:  corev1.ConditionUnknown,
+		Reason:  registryv1alpha1.ReasonCertificateIssuer,
+		Message: fmt.Sprintf("Secret %s is maintained by cert-manager", secretName),
+	}
+}
+
+// externalCertCondition reports the validity of a certificate Secret that is not maintained by the operator
+func (r *ReconcileImageRegistry) externalCertCondition(condType status.ConditionType, key types.NamespacedName, hostnames []string, validate func(*certs.KeyPair) error) status.Condition {
+	cond := status.Condition{Type: condType, Status: corev1.ConditionFalse}
+	st, reason, msg := r.inspectCertSecret(key, hostnames, validate)
+	if reason != "" {
+		cond.Reason = reason
+		cond.Message = msg
+	} else {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = registryv1alpha1.ReasonCertificateExternal
+		cond.Message = fmt.Sprintf("valid until %s", st.NotAfter.Format(time.RFC3339))
+	}
+	return cond
+}
+
+// inspectCertSecret reads the certificate from the given Secret.
+// It returns the certificate's status and, if it is not valid, the reason and a message.
+func (r *ReconcileImageRegistry) inspectCertSecret(key types.NamespacedName, hostnames []string, validate func(*certs.KeyPair) error) (st *registryv1alpha1.CertificateStatus, reason status.ConditionReason, msg string) {
+	st = &registryv1alpha1.CertificateStatus{SecretName: key.Name}
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), key, secret); err != nil {
+		reason = registryv1alpha1.ReasonFailedSync
+		if apierrors.IsNotFound(err) {
+			reason = registryv1alpha1.ReasonSecretNotFound
+		}
+		return st, reason, err.Error()
+	}
+	cert, err := certs.X509KeyPair(secret.Data[corev1.TLSPrivateKeyKey], secret.Data[corev1.TLSCertKey], nil)
+	if err == nil && validate != nil {
+		err = validate(cert)
+	}
+	if err != nil {
+		return st, registryv1alpha1.ReasonInvalidCertificate, fmt.Sprintf("Secret %s: %s", key.Name, err)
+	}
+	notBefore, notAfter := metav1.NewTime(cert.NotBefore()), metav1.NewTime(cert.NotAfter())
+	st.NotBefore = &notBefore
+	st.NotAfter = &notAfter
+	st.Issuer = cert.Issuer()
+	st.DNSNames = cert.DNSNames()
+	if missing := cert.MissingDNSNames(hostnames); len(missing) > 0 {
+		reason = registryv1alpha1.ReasonDNSNameMismatch
+		msg = fmt.Sprintf("certificate in Secret %s is not valid for %s", key.Name, strings.Join(missing, ", "))
+	} else if !time.Now().Before(cert.NotAfter()) {
+		reason = registryv1alpha1.ReasonCertificateExpired
+		msg = fmt.Sprintf("certificate in Secret %s expired at %s", key.Name, cert.NotAfter().Format(time.RFC3339))
+	}
+	return
+}
+
+// caRotationStatus reports the progress of a root CA rotation from the registry's point of view
+func (r *ReconcileImageRegistry) caRotationStatus(cert *certs.KeyPair) (*registryv1alpha1.CARotationStatus, error) {
+	rotation, err := r.certManager.RootCARotation()
+	if err != nil || rotation == nil {
+		return nil, err
+	}
+	rootCA, err := r.certManager.RootCACert()
+	if err != nil {
+		return nil, err
+	}
+	return &registryv1alpha1.CARotationStatus{
+		StartTime:         metav1.NewTime(rotation.StartTime),
+		OverlapEndTime:    metav1.NewTime(rotation.OverlapEndTime),
+		ServerCertRenewed: cert.SignedBy(rootCA),
+	}, nil
+}
+
 func (r *ReconcileImageRegistry) dnsNamesForCR(instance *registryv1alpha1.ImageRegistry) []string {
 	dnsNames := []string{}
 	internalFQN := fmt.Sprintf("%s.%s.svc.cluster.local", instance.Name, instance.Namespace)
 	externalFQN := fmt.Sprintf("%s.%s.%s", instance.Name, instance.Namespace, r.dnsZone)
+	for _, hostname := range r.externalHostnamesForCR(instance) {
+		if hostname != externalFQN && hostname != internalFQN {
+			dnsNames = append(dnsNames, hostname)
+		}
+	}
 	if externalFQN != internalFQN {
 		dnsNames = append(dnsNames, externalFQN)
 	}
@@ -83,9 +241,18 @@ func (r *ReconcileImageRegistry) dnsNamesForCR(instance *registryv1alpha1.ImageR
 		fmt.Sprintf("%s.%s.svc", instance.Name, instance.Namespace))
 }
 
-func toObjectReference(issuer *registryv1alpha1.CertIssuerRefSpec) certmgrmeta.ObjectReference {
-	return certmgrmeta.ObjectReference{
-		Name: issuer.Name,
-		Kind: issuer.Kind,
+// keyParamsForSpec maps a CertificateSpec to key parameters.
+// Unspecified values are left empty to be completed with the operator's defaults.
+func keyParamsForSpec(spec *registryv1alpha1.CertificateSpec) (p certs.KeyParams) {
+	if spec.Key != nil {
+		p.Algorithm = certs.KeyAlgorithm(spec.Key.Algorithm)
+		p.RSAKeySize = spec.Key.Size
+	}
+	if spec.Duration != nil {
+		p.Validity = spec.Duration.Duration
 	}
+	if spec.RenewBefore != nil {
+		p.RenewBefore = spec.RenewBefore.Duration
+	}
+	return
 }
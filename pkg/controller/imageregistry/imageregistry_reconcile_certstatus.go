@@ -0,0 +1,116 @@
+package imageregistry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// maxCertExpiringSoonThreshold is the max time before expiry a certificate is reported as expiring soon.
+	// Shorter-lived certificates are reported as expiring soon during the last fifth of their validity.
+	maxCertExpiringSoonThreshold = 14 * 24 * time.Hour
+	certRequeueDelay             = time.Minute
+)
+
+// reconcileCertificateStatus derives the certificates' status from the Secrets' contents
+// independent of whether they are maintained by the operator, cert-manager or the user.
+func (r *ReconcileImageRegistry) reconcileCertificateStatus(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) error {
+	now := time.Now()
+	ready := status.Condition{Type: registryv1alpha1.ConditionCertificateReady, Status: corev1.ConditionTrue}
+	expiring := status.Condition{Type: registryv1alpha1.ConditionCertificateExpiringSoon, Status: corev1.ConditionFalse}
+	var notReadyMsgs, expiringMsgs []string
+	for _, c := range []struct {
+		condType  status.ConditionType
+		target    **registryv1alpha1.CertificateStatus
+		secret    string
+		hostnames []string
+		validate  func(*certs.KeyPair) error
+	}{
+		{
+			condType:  registryv1alpha1.ConditionTLSCertificate,
+			target:    &instance.Status.TLSCertificate,
+			secret:    tlsSecretNameForCR(instance),
+			hostnames: r.externalHostnamesForCR(instance),
+		},
+		{
+			condType: registryv1alpha1.ConditionAuthCACertificate,
+			target:   &instance.Status.AuthCACertificate,
+			secret:   authCASecretNameForCR(instance),
+			validate: func(cert *certs.KeyPair) error {
+				_, err := certs.JWTAlgorithm(cert.Algorithm())
+				return err
+			},
+		},
+	} {
+		key := types.NamespacedName{Name: c.secret, Namespace: instance.Namespace}
+		st, reason, msg := r.inspectCertSecret(key, c.hostnames, c.validate)
+		*c.target = st
+		// GetCondition returns a copy that needs to be written back
+		cond := instance.Status.Conditions.GetCondition(c.condType)
+		issuer := cond != nil && cond.Reason == registryv1alpha1.ReasonCertificateIssuer
+		if reason != "" {
+			ready.Status = corev1.ConditionFalse
+			ready.Reason = reason
+			notReadyMsgs = append(notReadyMsgs, msg)
+			if issuer {
+				// Report cert-manager issuing failures
+				cond.Status = corev1.ConditionFalse
+				cond.Message = msg
+				instance.Status.Conditions.SetCondition(*cond)
+			}
+			continue
+		}
+		if issuer {
+			cond.Status = corev1.ConditionTrue
+			cond.Message = fmt.Sprintf("valid until %s", st.NotAfter.Format(time.RFC3339))
+			instance.Status.Conditions.SetCondition(*cond)
+		}
+		if !now.Before(expiringSoonTime(st)) {
+			expiring.Status = corev1.ConditionTrue
+			expiring.Reason = registryv1alpha1.ReasonCertificateExpiringSoon
+			expiringMsgs = append(expiringMsgs, fmt.Sprintf("certificate in Secret %s expires at %s", c.secret, st.NotAfter.Format(time.RFC3339)))
+		}
+	}
+	ready.Message = strings.Join(notReadyMsgs, "; ")
+	expiring.Message = strings.Join(expiringMsgs, "; ")
+	instance.Status.Conditions.SetCondition(ready)
+	instance.Status.Conditions.SetCondition(expiring)
+	return nil
+}
+
+// expiringSoonTime returns the time from which on the certificate is reported as expiring soon
+func expiringSoonTime(st *registryv1alpha1.CertificateStatus) time.Time {
+	threshold := st.NotAfter.Sub(st.NotBefore.Time) / 5
+	if threshold > maxCertExpiringSoonThreshold {
+		threshold = maxCertExpiringSoonThreshold
+	}
+	return st.NotAfter.Add(-threshold)
+}
+
+// certRequeueAfter returns the delay after which the certificate status must be updated
+// since a certificate expires (soon) or 0 if no requeue is required.
+func certRequeueAfter(cr *registryv1alpha1.ImageRegistry) (d time.Duration) {
+	now := time.Now()
+	for _, st := range []*registryv1alpha1.CertificateStatus{cr.Status.TLSCertificate, cr.Status.AuthCACertificate} {
+		if st == nil || st.NotAfter == nil || st.NotBefore == nil {
+			continue
+		}
+		for _, t := range []time.Time{expiringSoonTime(st), st.NotAfter.Time} {
+			if t.After(now) && (d == 0 || t.Sub(now) < d) {
+				d = t.Sub(now)
+			}
+		}
+	}
+	if d > 0 {
+		d += certRequeueDelay
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package imageregistry
+
+import (
+	"testing"
+	"time"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconcileCertificateStatusReportsIssuerFailure(t *testing.T) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = "myregistry"
+	cr.Namespace = "myns"
+	cr.Status.Conditions = status.Conditions{}
+	cr.Status.Conditions.SetCondition(status.Condition{
+		Type:   registryv1alpha1.ConditionTLSCertificate,
+		Status: corev1.ConditionTrue,
+		Reason: registryv1alpha1.ReasonCertificateIssuer,
+	})
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	r := &ReconcileImageRegistry{client: fake.NewFakeClientWithScheme(scheme)}
+	require.NoError(t, r.reconcileCertificateStatus(cr, logf.Log))
+	cond := cr.Status.Conditions.GetCondition(registryv1alpha1.ConditionTLSCertificate)
+	require.NotNil(t, cond, "TLS certificate condition")
+	require.Equal(t, corev1.ConditionFalse, cond.Status, "TLS certificate condition status")
+	require.NotEmpty(t, cond.Message, "TLS certificate condition message")
+	require.True(t, cr.Status.Conditions.IsFalseFor(registryv1alpha1.ConditionCertificateReady), "CertificateReady condition")
+}
+
+func TestCertRequeueAfter(t *testing.T) {
+	now := time.Now()
+	certStatus := func(notBefore, notAfter time.Time) *registryv1alpha1.CertificateStatus {
+		b, a := metav1.NewTime(notBefore), metav1.NewTime(notAfter)
+		return &registryv1alpha1.CertificateStatus{NotBefore: &b, NotAfter: &a}
+	}
+	cr := &registryv1alpha1.ImageRegistry{}
+	require.Equal(t, time.Duration(0), certRequeueAfter(cr), "no certificates")
+
+	// long-lived certificate: expiring soon 14 days before expiry
+	cr.Status.TLSCertificate = certStatus(now.Add(-24*time.Hour), now.Add(100*24*time.Hour))
+	requireDuration(t, 86*24*time.Hour+certRequeueDelay, certRequeueAfter(cr), "long-lived certificate")
+
+	// short-lived certificate: expiring soon during last fifth of validity
+	cr.Status.AuthCACertificate = certStatus(now.Add(-5*time.Hour), now.Add(5*time.Hour))
+	requireDuration(t, 3*time.Hour+certRequeueDelay, certRequeueAfter(cr), "short-lived certificate")
+
+	// expiring certificate: requeue on expiry
+	cr.Status.AuthCACertificate = certStatus(now.Add(-9*time.Hour), now.Add(time.Hour))
+	requireDuration(t, time.Hour+certRequeueDelay, certRequeueAfter(cr), "expiring certificate")
+
+	// expired certificate: no requeue for it
+	cr.Status.AuthCACertificate = certStatus(now.Add(-9*time.Hour), now.Add(-time.Hour))
+	requireDuration(t, 86*24*time.Hour+certRequeueDelay, certRequeueAfter(cr), "expired certificate")
+}
+
+func requireDuration(t *testing.T, expected, actual time.Duration, msg string) {
+	require.InDelta(t, float64(expected), float64(actual), float64(time.Second), msg)
+}
@@ -0,0 +1,175 @@
+package imageregistry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	secretKeyHTTPSecret       = "secret"
+	labelControllerRevision   = "controller-revision-hash"
+	topologyKeyHostname       = "kubernetes.io/hostname"
+	topologyKeyZone           = "topology.kubernetes.io/zone"
+	defaultPVCAccessMode      = corev1.ReadWriteMany
+	httpSecretLength          = 32
+	antiAffinityWeightReplica = 100
+)
+
+// validateStorageTopology fails if multiple replicas cannot share the registry's volume
+func (r *ReconcileImageRegistry) validateStorageTopology(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) error {
+	if replicasForCR(instance) < 2 || PVCSharedForCR(instance) {
+		return nil
+	}
+	return fmt.Errorf("%d replicas require a %s PersistentVolumeClaim", replicasForCR(instance), corev1.ReadWriteMany)
+}
+
+// reconcileHTTPSecret maintains the secret the registry replicas use to sign state (e.g. upload sessions).
+// It must be shared between replicas since uploads may be continued on another replica.
+func (r *ReconcileImageRegistry) reconcileHTTPSecret(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	secret := &corev1.Secret{}
+	secret.Name = httpSecretNameForCR(instance)
+	secret.Namespace = instance.Namespace
+	return r.upsert(instance, secret, reqLogger, func() error {
+		secret.Type = corev1.SecretTypeOpaque
+		if len(secret.Data[secretKeyHTTPSecret]) > 0 {
+			return nil
+		}
+		b := make([]byte, httpSecretLength)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("generate registry http secret: %w", err)
+		}
+		secret.Data = map[string][]byte{secretKeyHTTPSecret: []byte(hex.EncodeToString(b))}
+		return nil
+	})
+}
+
+// reconcilePodDisruptionBudget makes sure at most one replica is evicted at a time in HA mode
+func (r *ReconcileImageRegistry) reconcilePodDisruptionBudget(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	pdb.Name = pdbNameForCR(instance)
+	pdb.Namespace = instance.Namespace
+	if replicasForCR(instance) < 2 {
+		key := types.NamespacedName{Name: pdb.Name, Namespace: pdb.Namespace}
+		if err = r.client.Get(context.TODO(), key, pdb); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if err = r.client.Delete(context.TODO(), pdb); err == nil {
+			logOperation(reqLogger, "Deleted", pdb)
+		}
+		return client.IgnoreNotFound(err)
+	}
+	return r.upsert(instance, pdb, reqLogger, func() error {
+		maxUnavailable := intstr.FromInt(1)
+		pdb.Spec.MinAvailable = nil
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+		pdb.Spec.Selector = &metav1.LabelSelector{MatchLabels: selectorLabelsForCR(instance)}
+		return nil
+	})
+}
+
+// updateReplicaStatus writes the readiness of each registry Pod into the ImageRegistry's status
+func (r *ReconcileImageRegistry) updateReplicaStatus(instance *registryv1alpha1.ImageRegistry, statefulSet *appsv1.StatefulSet) error {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, client.InNamespace(instance.Namespace), client.MatchingLabels(selectorLabelsForCR(instance)))
+	if err != nil {
+		return err
+	}
+	// Sort the Pods to avoid status updates when only the list order changed
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+	var podStatus []registryv1alpha1.RegistryPodStatus
+	for _, pod := range pods.Items {
+		podStatus = append(podStatus, registryv1alpha1.RegistryPodStatus{
+			Name:    pod.Name,
+			Ready:   isPodReady(&pod),
+			Updated: pod.Labels[labelControllerRevision] == statefulSet.Status.UpdateRevision,
+		})
+	}
+	instance.Status.Replicas = statefulSet.Status.Replicas
+	instance.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
+	instance.Status.Pods = podStatus
+	return nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// antiAffinityForCR prefers to schedule the registry replicas on different nodes
+func antiAffinityForCR(cr *registryv1alpha1.ImageRegistry) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: antiAffinityWeightReplica,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: selectorLabelsForCR(cr)},
+						TopologyKey:   topologyKeyHostname,
+					},
+				},
+			},
+		},
+	}
+}
+
+// topologySpreadConstraintsForCR spreads the registry replicas evenly across zones and nodes
+func topologySpreadConstraintsForCR(cr *registryv1alpha1.ImageRegistry) []corev1.TopologySpreadConstraint {
+	selector := &metav1.LabelSelector{MatchLabels: selectorLabelsForCR(cr)}
+	return []corev1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       topologyKeyZone,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     selector,
+		},
+		{
+			MaxSkew:           1,
+			TopologyKey:       topologyKeyHostname,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     selector,
+		},
+	}
+}
+
+func replicasForCR(cr *registryv1alpha1.ImageRegistry) int32 {
+	if cr.Spec.Replicas != nil {
+		return *cr.Spec.Replicas
+	}
+	return 1
+}
+
+// PVCSharedForCR returns true if the registry's PersistentVolumeClaim can be mounted on multiple nodes
+func PVCSharedForCR(cr *registryv1alpha1.ImageRegistry) bool {
+	for _, m := range pvcAccessModesForCR(cr) {
+		if m == corev1.ReadWriteMany {
+			return true
+		}
+	}
+	return false
+}
+
+func pvcAccessModesForCR(cr *registryv1alpha1.ImageRegistry) []corev1.PersistentVolumeAccessMode {
+	if len(cr.Spec.PersistentVolumeClaim.AccessModes) == 0 {
+		return []corev1.PersistentVolumeAccessMode{defaultPVCAccessMode}
+	}
+	return cr.Spec.PersistentVolumeClaim.AccessModes
+}
@@ -0,0 +1,55 @@
+package imageregistry
+
+import (
+	"fmt"
+	"testing"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateReplicaStatusOrder(t *testing.T) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = "myregistry"
+	cr.Namespace = "myns"
+	var objs []runtime.Object
+	var expected []registryv1alpha1.RegistryPodStatus
+	for i := 0; i < 5; i++ {
+		pod := &corev1.Pod{}
+		pod.Name = fmt.Sprintf("myregistry-%d", i)
+		pod.Namespace = cr.Namespace
+		pod.Labels = selectorLabelsForCR(cr)
+		objs = append(objs, pod)
+		expected = append(expected, registryv1alpha1.RegistryPodStatus{Name: pod.Name, Updated: true})
+	}
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	r := &ReconcileImageRegistry{client: fake.NewFakeClientWithScheme(scheme, objs...)}
+	for i := 0; i < 10; i++ {
+		err := r.updateReplicaStatus(cr, &appsv1.StatefulSet{})
+		require.NoError(t, err)
+		require.Equal(t, expected, cr.Status.Pods, "status.pods")
+	}
+}
+
+func TestUpdateStatefulSetForCRSpreadsReplicas(t *testing.T) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = "myregistry"
+	cr.Namespace = "myns"
+	replicas := int32(3)
+	cr.Spec.Replicas = &replicas
+	r := &ReconcileImageRegistry{dnsZone: "svc.cluster.local"}
+	statefulSet := &appsv1.StatefulSet{}
+	require.NoError(t, r.updateStatefulSetForCR(cr, statefulSet))
+	podSpec := statefulSet.Spec.Template.Spec
+	require.NotNil(t, podSpec.Affinity, "affinity")
+	require.Len(t, podSpec.TopologySpreadConstraints, 2, "topologySpreadConstraints")
+
+	replicas = 1
+	require.NoError(t, r.updateStatefulSetForCR(cr, statefulSet))
+	require.Nil(t, statefulSet.Spec.Template.Spec.TopologySpreadConstraints, "topologySpreadConstraints of single replica")
+}
@@ -0,0 +1,80 @@
+package imageregistry
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	annotationIngressClass           = "kubernetes.io/ingress.class"
+	annotationNginxSSLPassthrough    = "nginx.ingress.kubernetes.io/ssl-passthrough"
+	annotationNginxBackendProtocol   = "nginx.ingress.kubernetes.io/backend-protocol"
+	annotationNginxProxyBodySize     = "nginx.ingress.kubernetes.io/proxy-body-size"
+	annotationNginxProxyRequestBuffs = "nginx.ingress.kubernetes.io/proxy-request-buffering"
+)
+
+func (r *ReconcileImageRegistry) reconcileIngress(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	ingress := &networkingv1beta1.Ingress{}
+	ingress.Name = ingressNameForCR(instance)
+	ingress.Namespace = instance.Namespace
+	spec := instance.Spec.Expose.Ingress
+	if spec == nil {
+		// Remove Ingress if it has been disabled
+		key := types.NamespacedName{Name: ingress.Name, Namespace: ingress.Namespace}
+		if err = r.client.Get(context.TODO(), key, ingress); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if err = r.client.Delete(context.TODO(), ingress); err == nil {
+			logOperation(reqLogger, "Deleted", ingress)
+		}
+		return client.IgnoreNotFound(err)
+	}
+	return r.upsert(instance, ingress, reqLogger, func() error {
+		a := map[string]string{}
+		if spec.ClassName != "" {
+			a[annotationIngressClass] = spec.ClassName
+		}
+		if spec.TLSMode == registryv1alpha1.IngressTLSReencrypt {
+			a[annotationNginxBackendProtocol] = "HTTPS"
+			// Allow large layer uploads
+			a[annotationNginxProxyBodySize] = "0"
+			a[annotationNginxProxyRequestBuffs] = "off"
+		} else {
+			a[annotationNginxSSLPassthrough] = "true"
+		}
+		for k, v := range spec.Annotations {
+			a[k] = v
+		}
+		ingress.Annotations = a
+		hostnames := r.externalHostnamesForCR(instance)
+		ingress.Spec.TLS = []networkingv1beta1.IngressTLS{
+			{Hosts: hostnames, SecretName: tlsSecretNameForCR(instance)},
+		}
+		ingress.Spec.Rules = make([]networkingv1beta1.IngressRule, len(hostnames))
+		for i, hostname := range hostnames {
+			ingress.Spec.Rules[i] = networkingv1beta1.IngressRule{
+				Host: hostname,
+				IngressRuleValue: networkingv1beta1.IngressRuleValue{
+					HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+						Paths: []networkingv1beta1.HTTPIngressPath{
+							{
+								Path: "/",
+								Backend: networkingv1beta1.IngressBackend{
+									ServiceName: serviceNameForCR(instance),
+									ServicePort: intstr.FromString(publicPortName),
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package imageregistry
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// annotationMaintenanceSwitched records when the read-only mode has been written into the maintenance ConfigMap
+	annotationMaintenanceSwitched = "registry.mgoltzsche.github.com/maintenance-switched"
+	configMapKeyReadOnly          = "readonly"
+	// maintenancePropagationDelay is the time the kubelet takes at most to sync a ConfigMap change into the Pods
+	maintenancePropagationDelay = 90 * time.Second
+	maintenanceRequeueDelay     = 10 * time.Second
+)
+
+// reconcileMaintenance writes the read-only mode into a ConfigMap that is mounted into the nginx container.
+// nginx rejects write requests while the readonly file exists which allows to toggle the mode without restarting the Pods.
+// The Maintenance condition becomes true once the kubelet had the time to sync the ConfigMap into the Pods.
+func (r *ReconcileImageRegistry) reconcileMaintenance(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) error {
+	cm := &corev1.ConfigMap{}
+	cm.Name = maintenanceConfigMapNameForCR(instance)
+	cm.Namespace = instance.Namespace
+	now := time.Now()
+	err := r.upsert(instance, cm, reqLogger, func() error {
+		_, readOnly := cm.Data[configMapKeyReadOnly]
+		if readOnly != instance.Spec.ReadOnly || cm.Annotations[annotationMaintenanceSwitched] == "" {
+			cm.Annotations[annotationMaintenanceSwitched] = now.UTC().Format(time.RFC3339)
+		}
+		cm.Data = map[string]string{}
+		if instance.Spec.ReadOnly {
+			cm.Data[configMapKeyReadOnly] = "true"
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	instance.Status.Conditions.SetCondition(maintenanceCondition(instance, cm, now))
+	return nil
+}
+
+func maintenanceCondition(instance *registryv1alpha1.ImageRegistry, cm *corev1.ConfigMap, now time.Time) status.Condition {
+	cond := status.Condition{
+		Type:   registryv1alpha1.ConditionMaintenance,
+		Status: corev1.ConditionFalse,
+	}
+	if !instance.Spec.ReadOnly {
+		return cond
+	}
+	switched, err := time.Parse(time.RFC3339, cm.Annotations[annotationMaintenanceSwitched])
+	if err != nil || now.Sub(switched) < maintenancePropagationDelay {
+		cond.Reason = registryv1alpha1.ReasonPending
+		cond.Message = "waiting for the read-only mode to be synced into the Pods"
+		return cond
+	}
+	cond.Status = corev1.ConditionTrue
+	cond.Reason = registryv1alpha1.ReasonReadOnly
+	cond.Message = instance.Spec.MaintenanceMessage
+	return cond
+}
+
+// maintenanceRequeueAfter returns the delay after which a pending Maintenance condition should be checked again
+func maintenanceRequeueAfter(cr *registryv1alpha1.ImageRegistry, d time.Duration) time.Duration {
+	cond := cr.Status.Conditions.GetCondition(registryv1alpha1.ConditionMaintenance)
+	if cond != nil && cond.Reason == registryv1alpha1.ReasonPending && (d == 0 || maintenanceRequeueDelay < d) {
+		return maintenanceRequeueDelay
+	}
+	return d
+}
+
+func maintenanceConfigMapNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name + "-maintenance"
+}
@@ -0,0 +1,36 @@
+package imageregistry
+
+import (
+	"testing"
+	"time"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMaintenanceCondition(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		name     string
+		readOnly bool
+		switched time.Time
+		status   corev1.ConditionStatus
+		reason   string
+	}{
+		{"read-write", false, now.Add(-time.Hour), corev1.ConditionFalse, ""},
+		{"read-only pending", true, now.Add(-time.Second), corev1.ConditionFalse, string(registryv1alpha1.ReasonPending)},
+		{"read-only synced", true, now.Add(-maintenancePropagationDelay), corev1.ConditionTrue, string(registryv1alpha1.ReasonReadOnly)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cr := &registryv1alpha1.ImageRegistry{}
+			cr.Spec.ReadOnly = c.readOnly
+			cr.Spec.MaintenanceMessage = "backup in progress"
+			cm := &corev1.ConfigMap{}
+			cm.Annotations = map[string]string{annotationMaintenanceSwitched: c.switched.UTC().Format(time.RFC3339)}
+			cond := maintenanceCondition(cr, cm, now)
+			require.Equal(t, c.status, cond.Status, "status")
+			require.Equal(t, c.reason, string(cond.Reason), "reason")
+		})
+	}
+}
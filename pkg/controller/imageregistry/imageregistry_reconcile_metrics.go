@@ -0,0 +1,94 @@
+package imageregistry
+
+import (
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/metrics"
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	labelMetrics        = "registry.mgoltzsche.github.com/metrics"
+	metricsPath         = "/metrics"
+	metricsPortRegistry = "metrics"
+	metricsPortAuth     = "auth-metrics"
+)
+
+// serviceMonitorsAvailable returns true if the prometheus-operator's ServiceMonitor API is installed
+func serviceMonitorsAvailable(cfg *rest.Config) bool {
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err == nil {
+		var available bool
+		if available, err = k8sutil.ResourceExists(dc, monitoringv1.SchemeGroupVersion.String(), monitoringv1.ServiceMonitorsKind); err == nil {
+			return available
+		}
+	}
+	log.Error(err, "failed to discover ServiceMonitor API - skipping ServiceMonitor creation")
+	return false
+}
+
+// reconcileMetricsService exposes the registry's and the auth server's metrics endpoints within the cluster
+func (r *ReconcileImageRegistry) reconcileMetricsService(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	svc := &corev1.Service{}
+	svc.Name = metricsServiceNameForCR(instance)
+	svc.Namespace = instance.Namespace
+	return r.upsert(instance, svc, reqLogger, func() error {
+		svc.Labels[labelMetrics] = "true"
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		svc.Spec.Selector = selectorLabelsForCR(instance)
+		svc.Spec.Ports = []corev1.ServicePort{
+			metricsServicePort(metricsPortRegistry, internalPortRegistryMetrics),
+			metricsServicePort(metricsPortAuth, internalPortAuthMetrics),
+		}
+		return nil
+	})
+}
+
+// reconcileServiceMonitor makes the prometheus-operator scrape the registry's metrics (if installed)
+func (r *ReconcileImageRegistry) reconcileServiceMonitor(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	if !r.serviceMonitors {
+		return nil
+	}
+	sm := &monitoringv1.ServiceMonitor{}
+	sm.Name = metricsServiceNameForCR(instance)
+	sm.Namespace = instance.Namespace
+	return r.upsert(instance, sm, reqLogger, func() error {
+		labels := selectorLabelsForCR(instance)
+		labels[labelMetrics] = "true"
+		sm.Spec.Selector = metav1.LabelSelector{MatchLabels: labels}
+		sm.Spec.Endpoints = []monitoringv1.Endpoint{
+			{Port: metricsPortRegistry, Path: metricsPath},
+			{Port: metricsPortAuth, Path: metricsPath},
+		}
+		return nil
+	})
+}
+
+// deleteCertMetrics removes the expiry metrics of the certificates generated for a deleted ImageRegistry
+func deleteCertMetrics(key types.NamespacedName) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = key.Name
+	cr.Namespace = key.Namespace
+	metrics.CertificateExpiry.Delete(cr.Namespace, tlsSecretNameForCR(cr))
+	metrics.CertificateExpiry.Delete(cr.Namespace, authCASecretNameForCR(cr))
+}
+
+func metricsServicePort(name string, port int32) corev1.ServicePort {
+	return corev1.ServicePort{
+		Name:       name,
+		Port:       port,
+		TargetPort: intstr.FromInt(int(port)),
+		Protocol:   corev1.ProtocolTCP,
+	}
+}
+
+func metricsServiceNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name + "-metrics"
+}
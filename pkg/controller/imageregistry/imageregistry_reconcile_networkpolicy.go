@@ -0,0 +1,67 @@
+package imageregistry
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileNetworkPolicy restricts the ingress traffic to the registry Pods to the nginx and metrics ports.
+// The internal registry and auth ports bypass TLS and are therefore not admitted at all.
+func (r *ReconcileImageRegistry) reconcileNetworkPolicy(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	netpol := &networkingv1.NetworkPolicy{}
+	netpol.Name = networkPolicyNameForCR(instance)
+	netpol.Namespace = instance.Namespace
+	spec := instance.Spec.NetworkPolicy
+	if spec == nil {
+		key := types.NamespacedName{Name: netpol.Name, Namespace: netpol.Namespace}
+		if err = r.client.Get(context.TODO(), key, netpol); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if err = r.client.Delete(context.TODO(), netpol); err == nil {
+			logOperation(reqLogger, "Deleted", netpol)
+		}
+		return client.IgnoreNotFound(err)
+	}
+	return r.upsert(instance, netpol, reqLogger, func() error {
+		rules := []networkingv1.NetworkPolicyIngressRule{
+			{
+				From:  spec.From,
+				Ports: networkPolicyPorts(internalPortNginx, internalPortNginxHTTP),
+			},
+		}
+		if len(spec.MetricsFrom) > 0 {
+			rules = append(rules, networkingv1.NetworkPolicyIngressRule{
+				From:  spec.MetricsFrom,
+				Ports: networkPolicyPorts(internalPortRegistryMetrics, internalPortAuthMetrics),
+			})
+		}
+		netpol.Spec = networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selectorLabelsForCR(instance)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     rules,
+		}
+		return nil
+	})
+}
+
+func networkPolicyPorts(ports ...int32) []networkingv1.NetworkPolicyPort {
+	r := make([]networkingv1.NetworkPolicyPort, len(ports))
+	for i, port := range ports {
+		protocol := corev1.ProtocolTCP
+		p := intstr.FromInt(int(port))
+		r[i] = networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+	}
+	return r
+}
+
+func networkPolicyNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name
+}
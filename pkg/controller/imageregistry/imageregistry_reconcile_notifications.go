@@ -0,0 +1,139 @@
+package imageregistry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	annotationNotificationsChecksum = "registry.mgoltzsche.github.com/notifications-checksum"
+	secretKeyNotificationEndpoints  = "endpoints.yml"
+	operatorNotificationEndpoint    = "image-registry-operator"
+	mediaTypeBlob                   = "application/octet-stream"
+)
+
+// notificationEndpoint is the distribution registry's notification endpoint configuration.
+// See https://docs.docker.com/registry/configuration/#notifications
+type notificationEndpoint struct {
+	Name              string              `yaml:"name"`
+	URL               string              `yaml:"url"`
+	Headers           map[string][]string `yaml:"headers,omitempty"`
+	Timeout           string              `yaml:"timeout,omitempty"`
+	Threshold         int                 `yaml:"threshold,omitempty"`
+	Backoff           string              `yaml:"backoff,omitempty"`
+	IgnoredMediaTypes []string            `yaml:"ignoredmediatypes,omitempty"`
+	Ignore            *notificationIgnore `yaml:"ignore,omitempty"`
+}
+
+type notificationIgnore struct {
+	MediaTypes []string `yaml:"mediatypes,omitempty"`
+	Actions    []string `yaml:"actions,omitempty"`
+}
+
+// reconcileNotifications renders the registry's notification endpoints into a Secret
+// since headers may contain credentials.
+func (r *ReconcileImageRegistry) reconcileNotifications(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
+	endpoints, err := r.notificationEndpointsForCR(instance)
+	if err != nil {
+		return
+	}
+	secret := &corev1.Secret{}
+	secret.Name = notificationsSecretNameForCR(instance)
+	secret.Namespace = instance.Namespace
+	if len(endpoints) == 0 {
+		err = r.client.Delete(context.TODO(), secret)
+		if errors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+	endpointsYAML, err := yaml.Marshal(endpoints)
+	if err != nil {
+		return
+	}
+	return r.upsert(instance, secret, reqLogger, func() error {
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{secretKeyNotificationEndpoints: endpointsYAML}
+		return nil
+	})
+}
+
+func (r *ReconcileImageRegistry) notificationEndpointsForCR(instance *registryv1alpha1.ImageRegistry) (endpoints []notificationEndpoint, err error) {
+	for _, spec := range instance.Spec.Notifications {
+		endpoint := notificationEndpoint{
+			Name:      spec.Name,
+			URL:       spec.URL,
+			Threshold: 3,
+			Backoff:   "1s",
+			Timeout:   "1s",
+		}
+		if spec.Timeout != nil {
+			endpoint.Timeout = spec.Timeout.Duration.String()
+		}
+		if len(spec.IgnoredActions) > 0 || len(spec.IgnoredMediaTypes) > 0 {
+			endpoint.Ignore = &notificationIgnore{
+				Actions:    spec.IgnoredActions,
+				MediaTypes: spec.IgnoredMediaTypes,
+			}
+		}
+		if spec.HeadersSecretRef != nil {
+			if endpoint.Headers, err = r.notificationHeaders(instance.Namespace, spec.HeadersSecretRef.Name); err != nil {
+				return nil, fmt.Errorf("notification endpoint %s: %w", spec.Name, err)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if r.notificationsURL != "" {
+		endpoints = append(endpoints, notificationEndpoint{
+			Name:              operatorNotificationEndpoint,
+			URL:               r.notificationsURL,
+			Threshold:         3,
+			Backoff:           "5s",
+			Timeout:           "1s",
+			IgnoredMediaTypes: []string{mediaTypeBlob},
+			Headers:           map[string][]string{"Authorization": {"Bearer " + r.notificationsToken}},
+		})
+	}
+	return
+}
+
+func (r *ReconcileImageRegistry) notificationHeaders(namespace, secretName string) (headers map[string][]string, err error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: secretName, Namespace: namespace}
+	if err = r.client.Get(context.TODO(), key, secret); err != nil {
+		return
+	}
+	headers = map[string][]string{}
+	for k, v := range secret.Data {
+		headers[k] = []string{string(v)}
+	}
+	return
+}
+
+// notificationsChecksum returns a checksum of the rendered notification endpoints
+// to roll out the StatefulSet when the configuration changes.
+func (r *ReconcileImageRegistry) notificationsChecksum(instance *registryv1alpha1.ImageRegistry) (string, error) {
+	endpoints, err := r.notificationEndpointsForCR(instance)
+	if err != nil || len(endpoints) == 0 {
+		return "", err
+	}
+	endpointsYAML, err := yaml.Marshal(endpoints)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(endpointsYAML)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func notificationsSecretNameForCR(cr *registryv1alpha1.ImageRegistry) string {
+	return "imageregistry-" + cr.Name + "-notifications"
+}
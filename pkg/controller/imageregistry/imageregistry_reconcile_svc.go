@@ -3,6 +3,7 @@ package imageregistry
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
@@ -21,11 +22,19 @@ const (
 	annotationExternalDnsHostname     = "external-dns.alpha.kubernetes.io/hostname"
 	annotationImageRegistryGeneration = "registry.mgoltzsche.github.com/generation"
 	annotationStatefulSetExternalName = "registry.mgoltzsche.github.com/externalName"
-	internalPortRegistry              = int32(5000)
-	internalPortAuth                  = int32(5001)
-	internalPortNginx                 = int32(8443)
-	publicPortNginx                   = int32(443)
-	publicPortName                    = "https"
+	annotationServiceAnnotations      = "registry.mgoltzsche.github.com/annotations"
+	// AnnotationGarbageCollect requests the registry's garbage collection:
+	// Changing its value restarts the registry Pod which collects unreferenced blobs before it starts serving.
+	// It is ignored when the registry has multiple replicas since other replicas could upload blobs concurrently.
+	AnnotationGarbageCollect    = "registry.mgoltzsche.github.com/garbage-collect"
+	internalPortRegistry        = int32(5000)
+	internalPortAuth            = int32(5001)
+	internalPortRegistryMetrics = int32(5002)
+	internalPortAuthMetrics     = int32(5003)
+	internalPortNginx           = int32(8443)
+	internalPortNginxHTTP       = int32(8080)
+	publicPortNginx             = int32(443)
+	publicPortName              = "https"
 )
 
 func (r *ReconcileImageRegistry) reconcileService(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
@@ -33,24 +42,35 @@ func (r *ReconcileImageRegistry) reconcileService(instance *registryv1alpha1.Ima
 	svc.Name = instance.Name
 	svc.Namespace = instance.Namespace
 	return r.upsert(instance, svc, reqLogger, func() error {
-		externalHostname := r.externalHostnameForCR(instance)
-		svc.Annotations[annotationExternalDnsHostname] = externalHostname
+		expose := &instance.Spec.Expose
+		serviceType := serviceTypeForCR(instance)
+		merge.Annotations(svc, expose.Annotations, annotationServiceAnnotations)
+		if serviceType == corev1.ServiceTypeLoadBalancer && expose.Ingress == nil {
+			svc.Annotations[annotationExternalDnsHostname] = strings.Join(r.externalHostnamesForCR(instance), ",")
+		} else if _, ok := expose.Annotations[annotationExternalDnsHostname]; !ok {
+			// The Ingress is published by external-dns instead
+			delete(svc.Annotations, annotationExternalDnsHostname)
+		}
 		svc.Spec.Selector = selectorLabelsForCR(instance)
-		svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+		svc.Spec.Type = serviceType
 		merge.AddServicePort(svc, publicPortName, publicPortNginx, internalPortNginx, corev1.ProtocolTCP)
+		if serviceType == corev1.ServiceTypeClusterIP {
+			// Reset fields that are not allowed for ClusterIP Services
+			svc.Spec.ExternalTrafficPolicy = ""
+			for i := range svc.Spec.Ports {
+				svc.Spec.Ports[i].NodePort = 0
+			}
+		}
 		return nil
 	})
 }
 
 func (r *ReconcileImageRegistry) reconcilePersistentVolumeClaim(instance *registryv1alpha1.ImageRegistry, reqLogger logr.Logger) (err error) {
 	pvc := &corev1.PersistentVolumeClaim{}
-	pvc.Name = pvcNameForCR(instance)
+	pvc.Name = PVCNameForCR(instance)
 	pvc.Namespace = instance.Namespace
 	storageClassName := instance.Spec.PersistentVolumeClaim.StorageClassName
-	accessModes := instance.Spec.PersistentVolumeClaim.AccessModes
-	if len(instance.Spec.PersistentVolumeClaim.AccessModes) == 0 {
-		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
-	}
+	accessModes := pvcAccessModesForCR(instance)
 	/*ctx := context.TODO()
 	key := types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}
 	if err = r.client.Get(ctx, key, pvc); err != nil {
@@ -101,30 +121,44 @@ func (r *ReconcileImageRegistry) reconcileStatefulSet(instance *registryv1alpha1
 	statefulSet := &appsv1.StatefulSet{}
 	statefulSet.Name = instance.Name
 	statefulSet.Namespace = instance.Namespace
-	return r.upsert(instance, statefulSet, reqLogger, func() error {
+	notificationsChecksum, err := r.notificationsChecksum(instance)
+	if err != nil {
+		return
+	}
+	err = r.upsert(instance, statefulSet, reqLogger, func() error {
 		externalName := r.externalHostnameForCR(instance)
 		generation := strconv.FormatInt(instance.Generation, 10)
 		a := statefulSet.Annotations
-		r.updateStatefulSetForCR(instance, statefulSet)
+		if err := r.updateStatefulSetForCR(instance, statefulSet); err != nil {
+			return err
+		}
+		if notificationsChecksum != "" {
+			if statefulSet.Spec.Template.Annotations == nil {
+				statefulSet.Spec.Template.Annotations = map[string]string{}
+			}
+			statefulSet.Spec.Template.Annotations[annotationNotificationsChecksum] = notificationsChecksum
+		} else {
+			delete(statefulSet.Spec.Template.Annotations, annotationNotificationsChecksum)
+		}
 
 		// Set ImageRegistry ready condition
 		s := statefulSet.Status
-		replicas := int32(1)
-		if instance.Spec.Replicas != nil {
-			replicas = *instance.Spec.Replicas
-		}
+		replicas := replicasForCR(instance)
 		updatedReplicas := s.UpdatedReplicas
 		generationUpToDate := s.ObservedGeneration == statefulSet.Generation
 		if !generationUpToDate {
 			updatedReplicas = 0
 		}
 		generationUpToDate = generationUpToDate && a[annotationImageRegistryGeneration] == generation
-		ready := generationUpToDate &&
-			statefulSet.Spec.Replicas != nil &&
+		scaled := statefulSet.Spec.Replicas != nil &&
 			*statefulSet.Spec.Replicas == replicas &&
-			s.Replicas == replicas &&
+			s.Replicas == replicas
+		ready := generationUpToDate && scaled &&
 			s.ReadyReplicas == replicas &&
 			updatedReplicas == replicas
+		// In HA mode the registry remains available during a rolling update
+		// since the PodDisruptionBudget allows only one replica to be unavailable
+		available := replicas > 1 && scaled && s.ReadyReplicas >= replicas-1
 		condStatus := corev1.ConditionTrue
 		var condReason status.ConditionReason
 		condMsg := ""
@@ -132,6 +166,10 @@ func (r *ReconcileImageRegistry) reconcileStatefulSet(instance *registryv1alpha1
 			condStatus = corev1.ConditionFalse
 			condMsg = fmt.Sprintf("%d/%d pods updating", updatedReplicas, replicas)
 			condReason = registryv1alpha1.ReasonUpdating
+			if available {
+				condStatus = corev1.ConditionTrue
+				condMsg = fmt.Sprintf("%d/%d pods updated, %d ready", updatedReplicas, replicas, s.ReadyReplicas)
+			}
 		}
 		instance.Status.Conditions.SetCondition(status.Condition{
 			Type:    registryv1alpha1.ConditionReady,
@@ -145,17 +183,18 @@ func (r *ReconcileImageRegistry) reconcileStatefulSet(instance *registryv1alpha1
 
 		return nil
 	})
+	if err != nil {
+		return
+	}
+	return r.updateReplicaStatus(instance, statefulSet)
 }
 
-func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.ImageRegistry, statefulSet *appsv1.StatefulSet) {
+func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.ImageRegistry, statefulSet *appsv1.StatefulSet) error {
 	extHostname := r.externalHostnameForCR(cr)
 	externalURL := "https://" + extHostname
 	authIssuerName := fmt.Sprintf("Docker Registry Auth %s", extHostname)
 	labels := selectorLabelsForCR(cr)
-	replicas := int32(1)
-	if cr.Spec.Replicas != nil {
-		replicas = *cr.Spec.Replicas
-	}
+	replicas := replicasForCR(cr)
 	spec := &statefulSet.Spec
 	spec.Replicas = &replicas
 	spec.ServiceName = serviceNameForCR(cr)
@@ -169,12 +208,23 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 	podSpec := &statefulSet.Spec.Template.Spec
 	podSpec.ServiceAccountName = serviceAccountNameForCR(cr)
 	podSpec.RestartPolicy = corev1.RestartPolicyAlways
+	// Reset fields that may have been set by a previous podTemplate override
+	podSpec.NodeSelector = nil
+	podSpec.Tolerations = nil
+	podSpec.Affinity = nil
+	podSpec.PriorityClassName = ""
+	podSpec.SecurityContext = nil
+	podSpec.TopologySpreadConstraints = nil
+	if replicas > 1 {
+		podSpec.Affinity = antiAffinityForCR(cr)
+		podSpec.TopologySpreadConstraints = topologySpreadConstraintsForCR(cr)
+	}
 	volumes := []corev1.Volume{
 		{
 			Name: "images",
 			VolumeSource: corev1.VolumeSource{
 				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-					ClaimName: pvcNameForCR(cr),
+					ClaimName: PVCNameForCR(cr),
 					ReadOnly:  false,
 				},
 			},
@@ -187,9 +237,16 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 			Name:         "registry-auth-token-ca",
 			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: authCASecretNameForCR(cr)}},
 		},
+		{
+			Name: "maintenance",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: maintenanceConfigMapNameForCR(cr)},
+			}},
+		},
 	}
 	authVolumeMounts := []corev1.VolumeMount{
 		{Name: "registry-auth-token-ca", MountPath: "/config/auth-cert"},
+		{Name: "maintenance", MountPath: "/maintenance"},
 	}
 	authConfigMapVol := "auth-config"
 	if cr.Spec.Auth.ConfigMapName != nil {
@@ -203,6 +260,37 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 			corev1.VolumeMount{Name: authConfigMapVol, MountPath: "/config"})
 	}
 	podSpec.Volumes = volumes
+	registryEnv := []corev1.EnvVar{
+		{Name: "REGISTRY_HTTP_ADDR", Value: fmt.Sprintf(":%d", internalPortRegistry)},
+		{Name: "REGISTRY_HTTP_HOST", Value: externalURL},
+		{Name: "REGISTRY_HTTP_RELATIVEURLS", Value: "true"},
+		{
+			Name: "REGISTRY_HTTP_SECRET",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: httpSecretNameForCR(cr)},
+				Key:                  secretKeyHTTPSecret,
+			}},
+		},
+		{Name: "REGISTRY_STORAGE_DELETE_ENABLED", Value: "true"},
+		{Name: "REGISTRY_AUTH", Value: "token"},
+		{Name: "REGISTRY_AUTH_TOKEN_REALM", Value: externalURL + "/auth/token"},
+		{Name: "REGISTRY_AUTH_TOKEN_AUTOREDIRECT", Value: "true"},
+		{Name: "REGISTRY_AUTH_TOKEN_ISSUER", Value: authIssuerName},
+		{Name: "REGISTRY_AUTH_TOKEN_SERVICE", Value: fmt.Sprintf("Docker Registry %s", extHostname)},
+		{Name: "REGISTRY_AUTH_TOKEN_ROOTCERTBUNDLE", Value: "/root/auth-cert/ca.crt"},
+		{Name: "REGISTRY_HTTP_DEBUG_ADDR", Value: fmt.Sprintf(":%d", internalPortRegistryMetrics)},
+		{Name: "REGISTRY_HTTP_DEBUG_PROMETHEUS_ENABLED", Value: "true"},
+		{Name: "REGISTRY_HTTP_DEBUG_PROMETHEUS_PATH", Value: metricsPath},
+	}
+	if len(cr.Spec.Notifications) > 0 || r.notificationsURL != "" {
+		registryEnv = append(registryEnv, corev1.EnvVar{
+			Name: "REGISTRY_NOTIFICATIONS_ENDPOINTS",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: notificationsSecretNameForCR(cr)},
+				Key:                  secretKeyNotificationEndpoints,
+			}},
+		})
+	}
 	podSpec.Containers = []corev1.Container{
 		{
 			Name:            "registry",
@@ -210,19 +298,9 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 			ImagePullPolicy: corev1.PullIfNotPresent,
 			Ports: []corev1.ContainerPort{
 				{Name: "docker", ContainerPort: internalPortRegistry, Protocol: corev1.ProtocolTCP},
+				{Name: metricsPortRegistry, ContainerPort: internalPortRegistryMetrics, Protocol: corev1.ProtocolTCP},
 			},
-			Env: []corev1.EnvVar{
-				{Name: "REGISTRY_HTTP_ADDR", Value: fmt.Sprintf(":%d", internalPortRegistry)},
-				{Name: "REGISTRY_HTTP_HOST", Value: externalURL},
-				{Name: "REGISTRY_HTTP_RELATIVEURLS", Value: "true"},
-				{Name: "REGISTRY_STORAGE_DELETE_ENABLED", Value: "true"},
-				{Name: "REGISTRY_AUTH", Value: "token"},
-				{Name: "REGISTRY_AUTH_TOKEN_REALM", Value: externalURL + "/auth/token"},
-				{Name: "REGISTRY_AUTH_TOKEN_AUTOREDIRECT", Value: "true"},
-				{Name: "REGISTRY_AUTH_TOKEN_ISSUER", Value: authIssuerName},
-				{Name: "REGISTRY_AUTH_TOKEN_SERVICE", Value: fmt.Sprintf("Docker Registry %s", extHostname)},
-				{Name: "REGISTRY_AUTH_TOKEN_ROOTCERTBUNDLE", Value: "/root/auth-cert/ca.crt"},
-			},
+			Env: registryEnv,
 			VolumeMounts: []corev1.VolumeMount{
 				{Name: "images", MountPath: "/var/lib/registry"},
 				{Name: "registry-auth-token-ca", MountPath: "/root/auth-cert"},
@@ -248,10 +326,13 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 				{Name: "NAMESPACE", Value: cr.GetNamespace()},
 				{Name: "AUTH_SERVER_ADDR", Value: fmt.Sprintf(":%d", internalPortAuth)},
 				{Name: "AUTH_TOKEN_ISSUER", Value: authIssuerName},
+				{Name: "AUTH_READ_ONLY_FILE", Value: "/maintenance/" + configMapKeyReadOnly},
+				{Name: "AUTH_METRICS_ADDR", Value: fmt.Sprintf(":%d", internalPortAuthMetrics)},
 			},
 			VolumeMounts: authVolumeMounts,
 			Ports: []corev1.ContainerPort{
 				{Name: "auth", ContainerPort: internalPortAuth, Protocol: corev1.ProtocolTCP},
+				{Name: metricsPortAuth, ContainerPort: internalPortAuthMetrics, Protocol: corev1.ProtocolTCP},
 			},
 			ReadinessProbe: httpProbe(internalPortAuth, "/"),
 			LivenessProbe:  httpProbe(internalPortAuth, "/"),
@@ -272,13 +353,14 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 			ImagePullPolicy: corev1.PullIfNotPresent,
 			Ports: []corev1.ContainerPort{
 				{Name: "https", ContainerPort: internalPortNginx, Protocol: corev1.ProtocolTCP},
-				{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP},
+				{Name: "http", ContainerPort: internalPortNginxHTTP, Protocol: corev1.ProtocolTCP},
 			},
 			VolumeMounts: []corev1.VolumeMount{
 				{Name: "tls", MountPath: "/etc/nginx/tls"},
+				{Name: "maintenance", MountPath: "/etc/nginx/maintenance"},
 			},
-			ReadinessProbe: httpProbe(8080, "/health"),
-			LivenessProbe:  httpProbe(8080, "/health"),
+			ReadinessProbe: httpProbe(internalPortNginxHTTP, "/health"),
+			LivenessProbe:  httpProbe(internalPortNginxHTTP, "/health"),
 			Resources: corev1.ResourceRequirements{
 				Limits: map[corev1.ResourceName]resource.Quantity{
 					corev1.ResourceCPU:    resource.MustParse("200m"),
@@ -291,6 +373,48 @@ func (r *ReconcileImageRegistry) updateStatefulSetForCR(cr *registryv1alpha1.Ima
 			},
 		},
 	}
+	podSpec.InitContainers = nil
+	delete(spec.Template.Annotations, AnnotationGarbageCollect)
+	if gc := cr.Annotations[AnnotationGarbageCollect]; gc != "" && replicas == 1 {
+		podSpec.InitContainers = []corev1.Container{
+			{
+				Name:            "garbage-collect",
+				Image:           r.imageRegistry,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Command:         []string{"registry", "garbage-collect", "--delete-untagged", "/etc/docker/registry/config.yml"},
+				Env:             registryEnv,
+				VolumeMounts:    podSpec.Containers[0].VolumeMounts,
+			},
+		}
+		if spec.Template.Annotations == nil {
+			spec.Template.Annotations = map[string]string{}
+		}
+		spec.Template.Annotations[AnnotationGarbageCollect] = gc
+	}
+	if cr.Spec.PodTemplate != nil {
+		if err := merge.PodTemplate(&spec.Template, podTemplateOverrideForCR(cr)); err != nil {
+			return fmt.Errorf("merge podTemplate: %w", err)
+		}
+		// Apply the selector labels last since overrides must not break Pod selection
+		for k, v := range labels {
+			spec.Template.Labels[k] = v
+		}
+	}
+	return nil
+}
+
+func podTemplateOverrideForCR(cr *registryv1alpha1.ImageRegistry) *corev1.PodTemplateSpec {
+	o := cr.Spec.PodTemplate
+	t := &corev1.PodTemplateSpec{}
+	t.Labels = o.Labels
+	t.Annotations = o.Annotations
+	t.Spec.NodeSelector = o.NodeSelector
+	t.Spec.Tolerations = o.Tolerations
+	t.Spec.Affinity = o.Affinity
+	t.Spec.PriorityClassName = o.PriorityClassName
+	t.Spec.SecurityContext = o.SecurityContext
+	t.Spec.Containers = o.Containers
+	return t
 }
 
 func httpProbe(port int32, path string) *corev1.Probe {
@@ -0,0 +1,48 @@
+package imageregistry
+
+import (
+	"testing"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestUpdateStatefulSetForCRPodTemplateLabels(t *testing.T) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = "myregistry"
+	cr.Namespace = "myns"
+	cr.Spec.PodTemplate = &registryv1alpha1.PodTemplateSpec{
+		Labels: map[string]string{"app": "overridden", "team": "platform"},
+	}
+	r := &ReconcileImageRegistry{dnsZone: "svc.cluster.local"}
+	statefulSet := &appsv1.StatefulSet{}
+	err := r.updateStatefulSetForCR(cr, statefulSet)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "imageregistry-myregistry"}, statefulSet.Spec.Selector.MatchLabels, "selector")
+	require.Equal(t, map[string]string{"app": "imageregistry-myregistry", "team": "platform"}, statefulSet.Spec.Template.Labels, "pod labels")
+}
+
+func TestUpdateStatefulSetForCRGarbageCollect(t *testing.T) {
+	cr := &registryv1alpha1.ImageRegistry{}
+	cr.Name = "myregistry"
+	cr.Namespace = "myns"
+	r := &ReconcileImageRegistry{dnsZone: "svc.cluster.local", imageRegistry: "registry:2"}
+	statefulSet := &appsv1.StatefulSet{}
+	require.NoError(t, r.updateStatefulSetForCR(cr, statefulSet))
+	require.Empty(t, statefulSet.Spec.Template.Spec.InitContainers, "initContainers without garbage collection")
+
+	cr.Annotations = map[string]string{AnnotationGarbageCollect: "2020-01-01T00:00:00Z"}
+	require.NoError(t, r.updateStatefulSetForCR(cr, statefulSet))
+	initContainers := statefulSet.Spec.Template.Spec.InitContainers
+	require.Len(t, initContainers, 1, "initContainers")
+	require.Equal(t, "registry:2", initContainers[0].Image, "garbage collection image")
+	require.Contains(t, initContainers[0].Command, "garbage-collect", "garbage collection command")
+	require.Equal(t, "2020-01-01T00:00:00Z", statefulSet.Spec.Template.Annotations[AnnotationGarbageCollect], "pod template annotation")
+
+	replicas := int32(2)
+	cr.Spec.Replicas = &replicas
+	require.NoError(t, r.updateStatefulSetForCR(cr, statefulSet))
+	require.Empty(t, statefulSet.Spec.Template.Spec.InitContainers, "initContainers with multiple replicas")
+	require.Empty(t, statefulSet.Spec.Template.Annotations[AnnotationGarbageCollect], "pod template annotation with multiple replicas")
+}
@@ -0,0 +1,41 @@
+package imageregistry
+
+import (
+	"testing"
+
+	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestValidateHostnames(t *testing.T) {
+	r := &ReconcileImageRegistry{}
+	for _, c := range []struct {
+		name        string
+		hostnames   []string
+		ingressHost string
+		valid       bool
+	}{
+		{"default", nil, "", true},
+		{"custom", []string{"registry.example.org", "registry-alias.example.org"}, "", true},
+		{"ingress host", nil, "registry.example.org", true},
+		{"uppercase", []string{"Registry.example.org"}, "", false},
+		{"wildcard", []string{"*.example.org"}, "", false},
+		{"injection", []string{"registry.example.org,evil.example.org"}, "", false},
+		{"invalid ingress host", nil, "registry_example.org", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cr := &registryv1alpha1.ImageRegistry{}
+			cr.Spec.Hostnames = c.hostnames
+			if c.ingressHost != "" {
+				cr.Spec.Expose.Ingress = &registryv1alpha1.IngressSpec{Host: c.ingressHost}
+			}
+			err := r.validateHostnames(cr, logf.Log)
+			if c.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
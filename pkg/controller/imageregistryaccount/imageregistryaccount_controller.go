@@ -5,8 +5,12 @@ import (
 	"time"
 
 	registryv1alpha1 "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagesecret"
+	"github.com/mgoltzsche/image-registry-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -21,7 +25,11 @@ var log = logf.Log.WithName("controller_imageregistryaccount")
 // Add creates a new ImageRegistryAccount Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	r := &ReconcileImageRegistryAccount{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	r := &ReconcileImageRegistryAccount{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetEventRecorderFor("imageregistryaccount-controller"),
+	}
 
 	c, err := controller.New("imageregistryaccount-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
@@ -38,8 +46,9 @@ var _ reconcile.Reconciler = &ReconcileImageRegistryAccount{}
 type ReconcileImageRegistryAccount struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a ImageRegistryAccount object and makes changes based on the state read
@@ -70,6 +79,10 @@ func (r *ReconcileImageRegistryAccount) Reconcile(request reconcile.Request) (re
 		if account.Expired() {
 			reqLogger.Info("Deleting expired ImageRegistryAccount", "ImageRegistryAccount.Namespace", account.Namespace, "ImageRegistryAccount.Name", account.Name)
 			err = r.client.Delete(context.TODO(), account)
+			if err == nil {
+				metrics.AccountsExpired.WithLabelValues(accessModeOf(account)).Inc()
+				r.recordAccountDeletion(account)
+			}
 			return reconcile.Result{}, err
 		}
 		expiryTime := account.CreationTimestamp.Time.Add(ttl.Duration)
@@ -78,3 +91,28 @@ func (r *ReconcileImageRegistryAccount) Reconcile(request reconcile.Request) (re
 
 	return reconcile.Result{}, nil
 }
+
+// recordAccountDeletion emits an Event on the ImagePullSecret/ImagePushSecret the deleted account belonged to
+func (r *ReconcileImageRegistryAccount) recordAccountDeletion(account *registryv1alpha1.ImageRegistryAccount) {
+	mode, key, err := imagesecret.ParseAccountName(account.Name)
+	if err != nil {
+		return // account not managed by a secret CR
+	}
+	var cr registryv1alpha1.ImageSecretInterface = &registryv1alpha1.ImagePullSecret{}
+	if mode == registryv1alpha1.TypePush {
+		cr = &registryv1alpha1.ImagePushSecret{}
+	}
+	if err = r.client.Get(context.TODO(), key, cr); err != nil {
+		return
+	}
+	r.recorder.Eventf(cr, corev1.EventTypeNormal, registryv1alpha1.EventReasonAccountDeleted,
+		"Deleted expired ImageRegistryAccount %s/%s", account.Namespace, account.Name)
+}
+
+// accessModeOf returns the access mode (pull/push) an account has been created for
+func accessModeOf(account *registryv1alpha1.ImageRegistryAccount) string {
+	if modes := account.Spec.Labels["accessMode"]; len(modes) > 0 {
+		return modes[0]
+	}
+	return ""
+}
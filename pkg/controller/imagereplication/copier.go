@@ -0,0 +1,151 @@
+package imagereplication
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registryclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// copier replicates all matching repositories from one registry to another
+type copier struct {
+	src     *registryclient.Client
+	dest    *registryclient.Client
+	filters []*regexp.Regexp
+	log     logr.Logger
+}
+
+func newCopier(src, dest *registryEndpoint, filters []*regexp.Regexp, log logr.Logger) (*copier, error) {
+	srcClient, err := registryclient.New(src.Hostname, src.Credentials, src.CA)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	destClient, err := registryclient.New(dest.Hostname, dest.Credentials, dest.CA)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	return &copier{srcClient, destClient, filters, log}, nil
+}
+
+// Run copies all matching repositories and returns their sync status.
+// An error is returned only if the source catalog cannot be listed.
+func (c *copier) Run() (status []registryapi.RepositorySyncStatus, err error) {
+	repos, err := c.src.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	return c.RunRepositories(repos), nil
+}
+
+// RunRepositories copies the given repositories if they match and returns their sync status.
+func (c *copier) RunRepositories(repos []string) (status []registryapi.RepositorySyncStatus) {
+	for _, repo := range repos {
+		if !c.matches(repo) {
+			continue
+		}
+		repoStatus := registryapi.RepositorySyncStatus{Name: repo}
+		tags, err := c.copyRepository(repo)
+		repoStatus.Tags = tags
+		if err != nil {
+			c.log.Error(err, "failed to replicate repository", "repository", repo)
+			repoStatus.Error = err.Error()
+		} else {
+			now := metav1.Now()
+			repoStatus.LastSyncTime = &now
+		}
+		status = append(status, repoStatus)
+	}
+	return
+}
+
+func (c *copier) matches(repo string) bool {
+	if len(c.filters) == 0 {
+		return true
+	}
+	for _, re := range c.filters {
+		if re.MatchString(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *copier) copyRepository(repo string) (synced int, err error) {
+	tags, err := c.src.Tags(repo)
+	if err != nil {
+		return
+	}
+	for _, tag := range tags {
+		if err = c.copyTag(repo, tag); err != nil {
+			return
+		}
+		synced++
+	}
+	return
+}
+
+func (c *copier) copyTag(repo, tag string) error {
+	m, err := c.src.Manifest(repo, tag)
+	if err != nil {
+		return err
+	}
+	destDigest, err := c.dest.ManifestDigest(repo, tag)
+	if err != nil {
+		return err
+	}
+	if destDigest == m.Digest {
+		return nil // up to date
+	}
+	c.log.V(1).Info("replicating image", "image", repo+":"+tag, "digest", m.Digest)
+	if err = c.copyManifestContents(repo, m); err != nil {
+		return err
+	}
+	return c.dest.PutManifest(repo, tag, m)
+}
+
+func (c *copier) copyManifestContents(repo string, m *registryclient.Manifest) error {
+	if m.IsList() {
+		for _, d := range m.Manifests {
+			destDigest, err := c.dest.ManifestDigest(repo, d.Digest)
+			if err != nil {
+				return err
+			}
+			if destDigest == d.Digest {
+				continue
+			}
+			child, err := c.src.Manifest(repo, d.Digest)
+			if err != nil {
+				return err
+			}
+			if err = c.copyManifestContents(repo, child); err != nil {
+				return err
+			}
+			if err = c.dest.PutManifest(repo, d.Digest, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, blob := range m.Blobs {
+		if err := c.copyBlob(repo, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *copier) copyBlob(repo string, blob registryclient.Descriptor) error {
+	exists, err := c.dest.BlobExists(repo, blob.Digest)
+	if err != nil || exists {
+		return err
+	}
+	reader, err := c.src.Blob(repo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return c.dest.PutBlob(repo, blob.Digest, blob.Size, reader)
+}
@@ -0,0 +1,449 @@
+package imagereplication
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagesecret"
+	"github.com/mgoltzsche/image-registry-operator/pkg/notifications"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registriesconf"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registryclient"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_imagereplication")
+
+const (
+	defaultInterval     = time.Hour
+	requeueDelayPending = 15 * time.Second
+	requeueDelayError   = time.Minute
+	secretKeyConfigJson = "config.json"
+)
+
+// Add creates a new ImageReplication Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager, events *notifications.Dispatcher) error {
+	triggers := make(chan event.GenericEvent)
+	r := &ReconcileImageReplication{
+		client:  mgr.GetClient(),
+		scheme:  mgr.GetScheme(),
+		dnsZone: imageregistry.DNSZone(),
+	}
+
+	// Create a new controller
+	c, err := controller.New("imagereplication-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource ImageReplication
+	err = c.Watch(&source.Kind{Type: &registryapi.ImageReplication{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the secret CRs providing the replication accounts
+	err = c.Watch(&source.Kind{Type: &registryapi.ImagePullSecret{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryapi.ImageReplication{},
+	})
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &registryapi.ImagePushSecret{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryapi.ImageReplication{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Trigger replications on push events received by the operator
+	err = c.Watch(&source.Channel{Source: triggers}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+	events.AddListener(func(evt notifications.Event) {
+		if evt.Action == notifications.ActionPush {
+			go r.triggerReplications(evt.Request.Host, evt.Target.Repository, triggers)
+		}
+	})
+	return nil
+}
+
+// blank assignment to verify that ReconcileImageReplication implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileImageReplication{}
+
+// ReconcileImageReplication reconciles a ImageReplication object
+type ReconcileImageReplication struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client  client.Client
+	scheme  *runtime.Scheme
+	dnsZone string
+	// triggered maps the keys of the replications that received a push event to the pushed repositories
+	triggered     map[types.NamespacedName]map[string]struct{}
+	triggeredLock sync.Mutex
+}
+
+// Reconcile reads that state of the cluster for a ImageReplication object and makes changes based on the state read
+// and what is in the ImageReplication.Spec
+// Note:
+// The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcileImageReplication) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling ImageReplication")
+
+	// Fetch the ImageReplication instance
+	instance := &registryapi.ImageReplication{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	// Resolve source and target registry (creates the replication accounts)
+	src, err := r.endpointForCR(instance, &instance.Spec.Source, registryapi.TypePull, "source")
+	if err != nil {
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonFailedSync, err.Error())
+		return reconcile.Result{}, err
+	}
+	dest, err := r.endpointForCR(instance, &instance.Spec.Target, registryapi.TypePush, "target")
+	if err != nil {
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonFailedSync, err.Error())
+		return reconcile.Result{}, err
+	}
+	if src == nil || dest == nil {
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonPending, "waiting for replication accounts to become ready")
+		return reconcile.Result{RequeueAfter: requeueDelayPending}, err
+	}
+
+	// Replicate all repositories when spec changed or interval elapsed
+	// or only the pushed repositories when push events have been received
+	filters, err := repositoryFilters(instance.Spec.Repositories)
+	if err != nil {
+		err = r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonFailedSync, err.Error())
+		return reconcile.Result{}, err
+	}
+	if nextSync := r.nextSync(instance); nextSync.After(time.Now()) {
+		pushed := r.takeTriggered(request.NamespacedName)
+		if len(pushed) > 0 {
+			return r.replicateRepositories(instance, src, dest, filters, pushed, reqLogger)
+		}
+		if instance.Spec.Trigger == registryapi.ReplicationTriggerPush {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: time.Until(nextSync)}, nil
+	}
+	r.takeTriggered(request.NamespacedName)
+	c, err := newCopier(src, dest, filters, reqLogger)
+	if err == nil {
+		reqLogger.Info("Replicating images", "source", src.Hostname, "target", dest.Hostname)
+		instance.Status.Repositories, err = c.Run()
+	}
+	now := metav1.Now()
+	instance.Status.LastSyncTime = &now
+	instance.Status.ObservedGeneration = instance.Generation
+	if err == nil {
+		failed := 0
+		for _, repo := range instance.Status.Repositories {
+			if repo.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			err = fmt.Errorf("%d/%d repositories failed to replicate", failed, len(instance.Status.Repositories))
+		}
+	}
+	if err != nil {
+		e := r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonFailedReplication, err.Error())
+		return reconcile.Result{RequeueAfter: requeueDelayError}, e
+	}
+	err = r.updateStatus(instance, corev1.ConditionTrue, "", "")
+	if err != nil || instance.Spec.Trigger == registryapi.ReplicationTriggerPush {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: intervalForCR(instance)}, nil
+}
+
+// replicateRepositories replicates the given repositories only.
+// Repositories that failed to replicate are triggered again with the next attempt.
+func (r *ReconcileImageReplication) replicateRepositories(instance *registryapi.ImageReplication, src, dest *registryEndpoint, filters []*regexp.Regexp, repos []string, reqLogger logr.Logger) (reconcile.Result, error) {
+	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	c, err := newCopier(src, dest, filters, reqLogger)
+	if err != nil {
+		r.addTriggered(key, repos...)
+		e := r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonFailedReplication, err.Error())
+		return reconcile.Result{RequeueAfter: requeueDelayError}, e
+	}
+	reqLogger.Info("Replicating pushed images", "source", src.Hostname, "target", dest.Hostname, "repositories", repos)
+	var failed []string
+	for _, repoStatus := range c.RunRepositories(repos) {
+		instance.Status.Repositories = mergeRepositoryStatus(instance.Status.Repositories, repoStatus)
+		if repoStatus.Error != "" {
+			failed = append(failed, repoStatus.Name)
+		}
+	}
+	if len(failed) > 0 {
+		r.addTriggered(key, failed...)
+		e := r.updateStatus(instance, corev1.ConditionFalse, registryapi.ReasonFailedReplication,
+			fmt.Sprintf("failed to replicate repositories %s", strings.Join(failed, ", ")))
+		return reconcile.Result{RequeueAfter: requeueDelayError}, e
+	}
+	err = r.updateStatus(instance, corev1.ConditionTrue, "", "")
+	if err != nil || instance.Spec.Trigger == registryapi.ReplicationTriggerPush {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: time.Until(r.nextSync(instance))}, nil
+}
+
+// mergeRepositoryStatus replaces or adds the repository's status
+func mergeRepositoryStatus(status []registryapi.RepositorySyncStatus, repoStatus registryapi.RepositorySyncStatus) []registryapi.RepositorySyncStatus {
+	for i, s := range status {
+		if s.Name == repoStatus.Name {
+			status[i] = repoStatus
+			return status
+		}
+	}
+	return append(status, repoStatus)
+}
+
+func (r *ReconcileImageReplication) addTriggered(key types.NamespacedName, repos ...string) {
+	r.triggeredLock.Lock()
+	defer r.triggeredLock.Unlock()
+	if r.triggered == nil {
+		r.triggered = map[types.NamespacedName]map[string]struct{}{}
+	}
+	m := r.triggered[key]
+	if m == nil {
+		m = map[string]struct{}{}
+		r.triggered[key] = m
+	}
+	for _, repo := range repos {
+		m[repo] = struct{}{}
+	}
+}
+
+// takeTriggered returns and resets the repositories that have been pushed since the last call
+func (r *ReconcileImageReplication) takeTriggered(key types.NamespacedName) (repos []string) {
+	r.triggeredLock.Lock()
+	defer r.triggeredLock.Unlock()
+	for repo := range r.triggered[key] {
+		repos = append(repos, repo)
+	}
+	delete(r.triggered, key)
+	sort.Strings(repos)
+	return
+}
+
+func (r *ReconcileImageReplication) nextSync(cr *registryapi.ImageReplication) time.Time {
+	if cr.Status.LastSyncTime == nil || cr.Status.ObservedGeneration != cr.Generation {
+		return time.Now()
+	}
+	if !cr.Status.Conditions.IsTrueFor(registryapi.ConditionReady) {
+		return cr.Status.LastSyncTime.Add(requeueDelayError)
+	}
+	if cr.Spec.Trigger == registryapi.ReplicationTriggerPush {
+		// Replicate on push events only
+		return time.Now().Add(time.Hour)
+	}
+	return cr.Status.LastSyncTime.Add(intervalForCR(cr))
+}
+
+func (r *ReconcileImageReplication) updateStatus(cr *registryapi.ImageReplication, ready corev1.ConditionStatus, reason status.ConditionReason, msg string) error {
+	cr.Status.Conditions.SetCondition(status.Condition{
+		Type:    registryapi.ConditionReady,
+		Status:  ready,
+		Reason:  reason,
+		Message: msg,
+	})
+	return r.client.Status().Update(context.TODO(), cr)
+}
+
+// triggerReplications enqueues all push-triggered replications whose source is the given registry host.
+// An ImageRegistry source matches any of its hostnames since clients may push using an alias.
+func (r *ReconcileImageReplication) triggerReplications(host, repo string, triggers chan<- event.GenericEvent) {
+	list := &registryapi.ImageReplicationList{}
+	if err := r.client.List(context.TODO(), list); err != nil {
+		log.Error(err, "failed to list ImageReplications")
+		return
+	}
+	host = strings.Split(host, ":")[0]
+	for i := range list.Items {
+		cr := &list.Items[i]
+		if cr.Spec.Trigger != registryapi.ReplicationTriggerPush {
+			continue
+		}
+		srcHosts, err := r.sourceHostnames(cr)
+		if err != nil || !containsHost(srcHosts, host) {
+			continue
+		}
+		r.addTriggered(types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}, repo)
+		triggers <- event.GenericEvent{Meta: cr, Object: cr}
+	}
+}
+
+func (r *ReconcileImageReplication) sourceHostnames(cr *registryapi.ImageReplication) ([]string, error) {
+	spec := cr.Spec.Source
+	if spec.External != nil {
+		return []string{spec.External.Hostname}, nil
+	}
+	if spec.RegistryRef == nil {
+		return nil, fmt.Errorf("neither registryRef nor external registry specified")
+	}
+	registry := &registryapi.ImageRegistry{}
+	key := registryKey(cr, spec.RegistryRef)
+	if err := r.client.Get(context.TODO(), key, registry); err != nil {
+		return nil, err
+	}
+	return imageregistry.RegistryHostnames(registry, r.dnsZone), nil
+}
+
+func containsHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+type registryEndpoint struct {
+	Hostname    string
+	Credentials registryclient.Credentials
+	CA          []byte
+}
+
+// endpointForCR resolves a replication source or target.
+// For an ImageRegistry it maintains an ImagePullSecret/ImagePushSecret providing the account
+// and returns nil until the corresponding Secret is available.
+func (r *ReconcileImageReplication) endpointForCR(cr *registryapi.ImageReplication, spec *registryapi.ReplicationEndpointSpec, mode registryapi.ImageSecretType, suffix string) (*registryEndpoint, error) {
+	if spec.External != nil {
+		return r.externalEndpoint(cr, spec.External)
+	}
+	if spec.RegistryRef == nil {
+		return nil, fmt.Errorf("%s: neither registryRef nor external registry specified", suffix)
+	}
+	var secretCR registryapi.ImageSecretInterface
+	var secretSpec *registryapi.ImageSecretSpec
+	if mode == registryapi.TypePush {
+		pushSecret := &registryapi.ImagePushSecret{}
+		secretCR, secretSpec = pushSecret, &pushSecret.Spec
+	} else {
+		pullSecret := &registryapi.ImagePullSecret{}
+		secretCR, secretSpec = pullSecret, &pullSecret.Spec
+	}
+	secretCR.SetName(fmt.Sprintf("imagereplication-%s-%s", cr.Name, suffix))
+	secretCR.SetNamespace(cr.Namespace)
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, secretCR, func() error {
+		ref := *spec.RegistryRef
+		secretSpec.RegistryRef = &ref
+		return controllerutil.SetControllerReference(cr, secretCR, r.scheme)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: upsert image secret: %w", suffix, err)
+	}
+	if !secretCR.GetStatus().Conditions.IsTrueFor(registryapi.ConditionReady) {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: imagesecret.SecretNameForCR(secretCR), Namespace: cr.Namespace}
+	if err = r.client.Get(context.TODO(), key, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &registryEndpoint{
+		Hostname: string(secret.Data[registryapi.SecretKeyRegistry]),
+		Credentials: registryclient.Credentials{
+			Username: string(secret.Data[registryapi.SecretKeyUsername]),
+			Password: string(secret.Data[registryapi.SecretKeyPassword]),
+		},
+		CA: secret.Data[registryapi.SecretKeyCaCert],
+	}, nil
+}
+
+func (r *ReconcileImageReplication) externalEndpoint(cr *registryapi.ImageReplication, spec *registryapi.ExternalRegistrySpec) (*registryEndpoint, error) {
+	endpoint := &registryEndpoint{Hostname: spec.Hostname}
+	if spec.SecretName == "" {
+		return endpoint, nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: spec.SecretName, Namespace: cr.Namespace}
+	if err := r.client.Get(context.TODO(), key, secret); err != nil {
+		return nil, fmt.Errorf("registry %s: %w", spec.Hostname, err)
+	}
+	configJson := secret.Data[corev1.DockerConfigJsonKey]
+	if len(configJson) == 0 {
+		configJson = secret.Data[secretKeyConfigJson]
+	}
+	conf, err := registriesconf.ParseDockerConfig(configJson)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", spec.SecretName, err)
+	}
+	if auth, ok := conf.Auths[spec.Hostname]; ok {
+		basicAuth, err := registriesconf.ToMakisuBasicAuth(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s basic auth: %w", spec.SecretName, err)
+		}
+		endpoint.Credentials = registryclient.Credentials{Username: basicAuth.Username, Password: basicAuth.Password}
+	}
+	endpoint.CA = secret.Data[registryapi.SecretKeyCaCert]
+	return endpoint, nil
+}
+
+func registryKey(cr *registryapi.ImageReplication, ref *registryapi.ImageRegistryRef) types.NamespacedName {
+	ns := ref.Namespace
+	if ns == "" {
+		ns = cr.Namespace
+	}
+	return types.NamespacedName{Name: ref.Name, Namespace: ns}
+}
+
+func repositoryFilters(expressions []string) (filters []*regexp.Regexp, err error) {
+	for _, expr := range expressions {
+		re, err := regexp.Compile("^(" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository filter: %w", err)
+		}
+		filters = append(filters, re)
+	}
+	return
+}
+
+func intervalForCR(cr *registryapi.ImageReplication) time.Duration {
+	if cr.Spec.Interval != nil && cr.Spec.Interval.Duration > 0 {
+		return cr.Spec.Interval.Duration
+	}
+	return defaultInterval
+}
@@ -0,0 +1,80 @@
+package imagereplication
+
+import (
+	"sort"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestTriggeredRepositories(t *testing.T) {
+	r := &ReconcileImageReplication{}
+	key := types.NamespacedName{Name: "myreplication", Namespace: "myns"}
+	otherKey := types.NamespacedName{Name: "other", Namespace: "myns"}
+	r.addTriggered(key, "repo-b")
+	r.addTriggered(key, "repo-a", "repo-b")
+	r.addTriggered(otherKey, "repo-c")
+	require.Equal(t, []string{"repo-a", "repo-b"}, r.takeTriggered(key), "triggered repositories")
+	require.Empty(t, r.takeTriggered(key), "triggered repositories after take")
+	require.Equal(t, []string{"repo-c"}, r.takeTriggered(otherKey), "other replication's triggered repositories")
+}
+
+func TestMergeRepositoryStatus(t *testing.T) {
+	status := []registryapi.RepositorySyncStatus{{Name: "repo-a", Tags: 1}, {Name: "repo-b", Error: "failed"}}
+	status = mergeRepositoryStatus(status, registryapi.RepositorySyncStatus{Name: "repo-b", Tags: 2})
+	status = mergeRepositoryStatus(status, registryapi.RepositorySyncStatus{Name: "repo-c", Tags: 3})
+	require.Equal(t, []registryapi.RepositorySyncStatus{{Name: "repo-a", Tags: 1}, {Name: "repo-b", Tags: 2}, {Name: "repo-c", Tags: 3}}, status)
+}
+
+func TestTriggerReplications(t *testing.T) {
+	registry := &registryapi.ImageRegistry{}
+	registry.Name = "registry"
+	registry.Namespace = "myns"
+	registry.Spec.Hostnames = []string{"registry.example.org", "alias.example.org"}
+	registry.Status.Hostname = "registry.example.org"
+	newReplication := func(name string, trigger registryapi.ReplicationTrigger, source registryapi.ReplicationEndpointSpec) *registryapi.ImageReplication {
+		cr := &registryapi.ImageReplication{}
+		cr.Name = name
+		cr.Namespace = "myns"
+		cr.Spec.Trigger = trigger
+		cr.Spec.Source = source
+		return cr
+	}
+	ref := registryapi.ReplicationEndpointSpec{RegistryRef: &registryapi.ImageRegistryRef{Name: registry.Name}}
+	external := registryapi.ReplicationEndpointSpec{External: &registryapi.ExternalRegistrySpec{Hostname: "external.example.org"}}
+	scheme := runtime.NewScheme()
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	r := &ReconcileImageReplication{client: fake.NewFakeClientWithScheme(scheme, registry,
+		newReplication("push", registryapi.ReplicationTriggerPush, ref),
+		newReplication("scheduled", registryapi.ReplicationTriggerSchedule, ref),
+		newReplication("external", registryapi.ReplicationTriggerPush, external),
+	)}
+	for _, c := range []struct {
+		host     string
+		expected []string
+	}{
+		{"registry.example.org", []string{"push"}},
+		{"alias.example.org:443", []string{"push"}},
+		{"external.example.org", []string{"external"}},
+		{"other.example.org", nil},
+	} {
+		t.Run(c.host, func(t *testing.T) {
+			triggers := make(chan event.GenericEvent, 3)
+			r.triggerReplications(c.host, "myrepo", triggers)
+			close(triggers)
+			var triggered []string
+			for evt := range triggers {
+				triggered = append(triggered, evt.Meta.GetName())
+				key := types.NamespacedName{Name: evt.Meta.GetName(), Namespace: evt.Meta.GetNamespace()}
+				require.Equal(t, []string{"myrepo"}, r.takeTriggered(key), "triggered repositories")
+			}
+			sort.Strings(triggered)
+			require.Equal(t, c.expected, triggered, "triggered replications")
+		})
+	}
+}
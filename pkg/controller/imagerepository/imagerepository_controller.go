@@ -0,0 +1,324 @@
+package imagerepository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagesecret"
+	"github.com/mgoltzsche/image-registry-operator/pkg/notifications"
+	"github.com/mgoltzsche/image-registry-operator/pkg/registryclient"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_imagerepository")
+
+const (
+	defaultInterval     = 10 * time.Minute
+	requeueDelayPending = 15 * time.Second
+	maxNameLength       = 253
+	nameHashLength      = 8
+)
+
+// Add creates a new catalog Controller that synchronizes the repositories of an ImageRegistry
+// into ImageRepository resources and adds it to the Manager.
+func Add(mgr manager.Manager, events *notifications.Dispatcher) error {
+	triggers := make(chan event.GenericEvent)
+	r := &ReconcileImageRepository{
+		client:  mgr.GetClient(),
+		scheme:  mgr.GetScheme(),
+		dnsZone: imageregistry.DNSZone(),
+	}
+
+	// Create a new controller
+	c, err := controller.New("imagerepository-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource ImageRegistry that affect the catalog synchronization
+	err = c.Watch(&source.Kind{Type: &registryapi.ImageRegistry{}}, &handler.EnqueueRequestForObject{}, registryPredicate)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to the ImagePullSecret providing the catalog account
+	err = c.Watch(&source.Kind{Type: &registryapi.ImagePullSecret{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryapi.ImageRegistry{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Synchronize the catalog on push events received by the operator
+	err = c.Watch(&source.Channel{Source: triggers}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+	events.AddListener(func(evt notifications.Event) {
+		if evt.Action == notifications.ActionPush {
+			go r.recordPush(evt, triggers)
+		}
+	})
+	return nil
+}
+
+// blank assignment to verify that ReconcileImageRepository implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileImageRepository{}
+
+// ReconcileImageRepository synchronizes an ImageRegistry's catalog
+type ReconcileImageRepository struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client  client.Client
+	scheme  *runtime.Scheme
+	dnsZone string
+	// lastPush maps <namespace>/<registry>/<repository> to the time of the latest received push event
+	lastPush sync.Map
+}
+
+// Reconcile lists the repositories and tags of an ImageRegistry
+// and maintains an ImageRepository per repository within the registry's namespace.
+func (r *ReconcileImageRepository) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+
+	// Fetch the ImageRegistry instance
+	instance := &registryapi.ImageRegistry{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Owned objects are automatically garbage collected.
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	pullSecret := &registryapi.ImagePullSecret{}
+	pullSecret.Name = pullSecretNameForCR(instance)
+	pullSecret.Namespace = instance.Namespace
+	if instance.Spec.Catalog == nil {
+		// Catalog synchronization disabled
+		if err = r.deleteRepositories(instance, nil); err != nil {
+			return reconcile.Result{}, err
+		}
+		err = r.client.Delete(context.TODO(), pullSecret)
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	reqLogger.Info("Synchronizing catalog")
+
+	// Maintain the catalog account
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, pullSecret, func() error {
+		pullSecret.Spec.RegistryRef = &registryapi.ImageRegistryRef{Name: instance.Name, Namespace: instance.Namespace}
+		return controllerutil.SetControllerReference(instance, pullSecret, r.scheme)
+	})
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !pullSecret.Status.Conditions.IsTrueFor(registryapi.ConditionReady) {
+		return reconcile.Result{RequeueAfter: requeueDelayPending}, nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: imagesecret.SecretNameForCR(pullSecret), Namespace: instance.Namespace}
+	if err = r.client.Get(context.TODO(), key, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{RequeueAfter: requeueDelayPending}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	c, err := registryclient.New(string(secret.Data[registryapi.SecretKeyRegistry]), registryclient.Credentials{
+		Username: string(secret.Data[registryapi.SecretKeyUsername]),
+		Password: string(secret.Data[registryapi.SecretKeyPassword]),
+	}, secret.Data[registryapi.SecretKeyCaCert])
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Synchronize repositories
+	repos, err := c.Catalog()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	names := map[string]bool{}
+	for _, repo := range repos {
+		names[repositoryName(instance, repo)] = true
+		if err = r.syncRepository(instance, c, repo, reqLogger); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	if err = r.deleteRepositories(instance, names); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: intervalForCR(instance)}, nil
+}
+
+func (r *ReconcileImageRepository) syncRepository(registry *registryapi.ImageRegistry, c *registryclient.Client, repo string, reqLogger logr.Logger) error {
+	tagNames, err := c.Tags(repo)
+	if err != nil {
+		return err
+	}
+	tags := make([]registryapi.ImageTagStatus, 0, len(tagNames))
+	for _, tag := range tagNames {
+		m, err := c.Manifest(repo, tag)
+		if err != nil {
+			if registryclient.IsNotFound(err) {
+				continue // deleted meanwhile
+			}
+			return err
+		}
+		size := int64(0)
+		for _, blob := range m.Blobs {
+			size += blob.Size
+		}
+		tags = append(tags, registryapi.ImageTagStatus{Name: tag, Digest: m.Digest, MediaType: m.MediaType, Size: size})
+	}
+	obj := &registryapi.ImageRepository{}
+	obj.Name = repositoryName(registry, repo)
+	obj.Namespace = registry.Namespace
+	result, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, obj, func() error {
+		if obj.Labels == nil {
+			obj.Labels = map[string]string{}
+		}
+		obj.Labels[registryapi.LabelImageRegistry] = registry.Name
+		st := registryapi.ImageRepositoryStatus{
+			Registry:     c.Host(),
+			Repository:   repo,
+			Tags:         tags,
+			TagCount:     len(tags),
+			LastPushTime: obj.Status.LastPushTime,
+		}
+		if t, ok := r.lastPush.Load(lastPushKey(registry, repo)); ok {
+			pushTime := metav1.NewTime(t.(time.Time).Truncate(time.Second))
+			st.LastPushTime = &pushTime
+		}
+		updateStatus(obj, st, metav1.Now())
+		return controllerutil.SetControllerReference(registry, obj, r.scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("upsert ImageRepository %s: %w", obj.Name, err)
+	}
+	if result == controllerutil.OperationResultCreated {
+		reqLogger.Info("Created ImageRepository", "ImageRepository.Name", obj.Name)
+	}
+	return nil
+}
+
+// updateStatus sets the ImageRepository's status and its LastSyncTime if the status changed.
+// The LastSyncTime is not updated when nothing changed to avoid an update per repository and sync interval.
+func updateStatus(obj *registryapi.ImageRepository, st registryapi.ImageRepositoryStatus, now metav1.Time) {
+	st.LastSyncTime = obj.Status.LastSyncTime
+	if st.LastSyncTime == nil || !equality.Semantic.DeepEqual(st, obj.Status) {
+		st.LastSyncTime = &now
+	}
+	obj.Status = st
+}
+
+// deleteRepositories deletes the registry's ImageRepositories except those contained in keep
+func (r *ReconcileImageRepository) deleteRepositories(registry *registryapi.ImageRegistry, keep map[string]bool) error {
+	list := &registryapi.ImageRepositoryList{}
+	err := r.client.List(context.TODO(), list, client.InNamespace(registry.Namespace), client.MatchingLabels{registryapi.LabelImageRegistry: registry.Name})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		repo := &list.Items[i]
+		if !keep[repo.Name] {
+			if err = r.client.Delete(context.TODO(), repo); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			r.lastPush.Delete(lastPushKey(registry, repo.Status.Repository))
+		}
+	}
+	return nil
+}
+
+// recordPush remembers the push time and triggers the synchronization of the corresponding registry
+func (r *ReconcileImageRepository) recordPush(evt notifications.Event, triggers chan<- event.GenericEvent) {
+	list := &registryapi.ImageRegistryList{}
+	if err := r.client.List(context.TODO(), list); err != nil {
+		log.Error(err, "failed to list ImageRegistries")
+		return
+	}
+	host := strings.Split(evt.Request.Host, ":")[0]
+	for i := range list.Items {
+		registry := &list.Items[i]
+		if registry.Spec.Catalog == nil {
+			continue
+		}
+		for _, hostname := range imageregistry.RegistryHostnames(registry, r.dnsZone) {
+			if hostname == host {
+				r.lastPush.Store(lastPushKey(registry, evt.Target.Repository), evt.Timestamp)
+				triggers <- event.GenericEvent{Meta: registry, Object: registry}
+				return
+			}
+		}
+	}
+}
+
+func lastPushKey(registry *registryapi.ImageRegistry, repo string) string {
+	return fmt.Sprintf("%s/%s/%s", registry.Namespace, registry.Name, repo)
+}
+
+// repositoryName maps a repository to a valid resource name: <REGISTRY>.<REPOSITORY>-<HASH>.
+// Since the mapping of the repository name is lossy the hash of the repository name is appended to avoid collisions.
+func repositoryName(registry *registryapi.ImageRegistry, repo string) string {
+	h := sha256.Sum256([]byte(repo))
+	suffix := "-" + hex.EncodeToString(h[:])[:nameHashLength]
+	name := registry.Name + "." + strings.NewReplacer("/", ".", "_", "-").Replace(repo)
+	if len(name) > maxNameLength-len(suffix) {
+		name = name[:maxNameLength-len(suffix)]
+	}
+	return strings.TrimRight(name, ".-") + suffix
+}
+
+// registryPredicate passes ImageRegistry updates that affect the catalog synchronization only
+// since every reconcile request lists the whole catalog.
+// Pushes trigger the synchronization via the notification listener and the catalog is synchronized periodically anyway.
+var registryPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCR, ok := e.ObjectOld.(*registryapi.ImageRegistry)
+		if !ok {
+			return true
+		}
+		newCR, ok := e.ObjectNew.(*registryapi.ImageRegistry)
+		if !ok {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldCR.Spec.Catalog, newCR.Spec.Catalog) ||
+			oldCR.Status.Hostname != newCR.Status.Hostname ||
+			oldCR.Status.Conditions.IsTrueFor(registryapi.ConditionReady) != newCR.Status.Conditions.IsTrueFor(registryapi.ConditionReady)
+	},
+}
+
+func pullSecretNameForCR(cr *registryapi.ImageRegistry) string {
+	return "imageregistry-" + cr.Name + "-catalog"
+}
+
+func intervalForCR(cr *registryapi.ImageRegistry) time.Duration {
+	if i := cr.Spec.Catalog.Interval; i != nil && i.Duration > 0 {
+		return i.Duration
+	}
+	return defaultInterval
+}
@@ -0,0 +1,90 @@
+package imagerepository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestRepositoryName(t *testing.T) {
+	registry := &registryapi.ImageRegistry{}
+	registry.Name = "registry"
+	names := map[string]string{}
+	for _, repo := range []string{"team/app", "team.app", "team_app", "team-app", "library/alpine", strings.Repeat("a", 300)} {
+		name := repositoryName(registry, repo)
+		require.Empty(t, validation.IsDNS1123Subdomain(name), "invalid name %q for repository %q", name, repo)
+		require.True(t, strings.HasPrefix(name, "registry."), "name %q should start with the registry name", name)
+		require.Equal(t, name, repositoryName(registry, repo), "name should be stable")
+		if other, ok := names[name]; ok {
+			t.Errorf("repositories %q and %q are mapped to the same name %q", repo, other, name)
+		}
+		names[name] = repo
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	synced := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	now := metav1.NewTime(time.Now().Truncate(time.Second))
+	st := registryapi.ImageRepositoryStatus{
+		Registry:   "registry.example.org",
+		Repository: "team/app",
+		Tags:       []registryapi.ImageTagStatus{{Name: "latest", Digest: "sha256:1"}},
+		TagCount:   1,
+	}
+	obj := &registryapi.ImageRepository{}
+	obj.Status = *st.DeepCopy()
+	obj.Status.LastSyncTime = &synced
+
+	updateStatus(obj, *st.DeepCopy(), now)
+	require.Equal(t, &synced, obj.Status.LastSyncTime, "lastSyncTime should not change when the status did not change")
+
+	st.Tags = append(st.Tags, registryapi.ImageTagStatus{Name: "v1", Digest: "sha256:2"})
+	st.TagCount = 2
+	updateStatus(obj, *st.DeepCopy(), now)
+	require.Equal(t, &now, obj.Status.LastSyncTime, "lastSyncTime should change when the tags changed")
+	require.Equal(t, 2, obj.Status.TagCount, "tagCount")
+}
+
+func TestRegistryPredicate(t *testing.T) {
+	newRegistry := func(modify func(*registryapi.ImageRegistry)) *registryapi.ImageRegistry {
+		cr := &registryapi.ImageRegistry{}
+		cr.Name = "registry"
+		cr.Spec.Catalog = &registryapi.CatalogSpec{}
+		cr.Status.Hostname = "registry.example.org"
+		cr.Status.Conditions = status.Conditions{}
+		cr.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionReady, Status: corev1.ConditionTrue})
+		modify(cr)
+		return cr
+	}
+	for _, c := range []struct {
+		name     string
+		modify   func(*registryapi.ImageRegistry)
+		expected bool
+	}{
+		{"unchanged", func(cr *registryapi.ImageRegistry) {}, false},
+		{"replica status", func(cr *registryapi.ImageRegistry) { cr.Status.ReadyReplicas = 2 }, false},
+		{"catalog disabled", func(cr *registryapi.ImageRegistry) { cr.Spec.Catalog = nil }, true},
+		{"catalog interval", func(cr *registryapi.ImageRegistry) {
+			cr.Spec.Catalog.Interval = &metav1.Duration{Duration: time.Minute}
+		}, true},
+		{"hostname", func(cr *registryapi.ImageRegistry) { cr.Status.Hostname = "other.example.org" }, true},
+		{"unready", func(cr *registryapi.ImageRegistry) {
+			cr.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionReady, Status: corev1.ConditionFalse})
+		}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCR := newRegistry(func(*registryapi.ImageRegistry) {})
+			newCR := newRegistry(c.modify)
+			e := event.UpdateEvent{MetaOld: oldCR, ObjectOld: oldCR, MetaNew: newCR, ObjectNew: newCR}
+			require.Equal(t, c.expected, registryPredicate.Update(e))
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -12,22 +14,28 @@ import (
 	"github.com/mgoltzsche/image-registry-operator/pkg/backrefs"
 	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
 	"github.com/mgoltzsche/image-registry-operator/pkg/merge"
+	"github.com/mgoltzsche/image-registry-operator/pkg/metrics"
 	"github.com/mgoltzsche/image-registry-operator/pkg/passwordgen"
 	"github.com/mgoltzsche/image-registry-operator/pkg/registriesconf"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -39,12 +47,15 @@ const (
 	EnvDefaultRegistryNamespace = "OPERATOR_DEFAULT_REGISTRY_NAMESPACE"
 	EnvSecretTTL                = "OPERATOR_SECRET_TTL"
 	annotationSecretRotation    = "registry.mgoltzsche.github.com/rotation"
+	annotationSecretAliases     = "registry.mgoltzsche.github.com/aliases"
 	defaultAccountTTL           = 24 * time.Hour
 	finalizer                   = "registry.mgoltzsche.github.com/accounts"
+	indexRegistryKey            = "registryKey"
 )
 
 // WatchSecondaryResources watches resources created or referenced by a secret CR
-func WatchSecondaryResources(c controller.Controller, ownerType runtime.Object, accountLabel string) (err error) {
+func WatchSecondaryResources(mgr manager.Manager, c controller.Controller, r *ReconcileImageSecret) (err error) {
+	ownerType := r.cfg.CRFactory()
 	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    ownerType,
@@ -59,25 +70,56 @@ func WatchSecondaryResources(c controller.Controller, ownerType runtime.Object,
 	if err != nil {
 		return
 	}
-	accountToSecret := backrefs.LabelToRequest(accountLabel)
+	accountToSecret := backrefs.LabelToRequest(r.cfg.AccountLabel)
 	err = c.Watch(&source.Kind{Type: &registryapi.ImageRegistryAccount{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: accountToSecret})
 	if err != nil {
 		return
 	}
+	// Enqueue all secret CRs that refer to a changed ImageRegistry
+	err = mgr.GetFieldIndexer().IndexField(ownerType, indexRegistryKey, r.indexRegistryKey)
+	if err != nil {
+		return
+	}
+	err = c.Watch(&source.Kind{Type: &registryapi.ImageRegistry{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.registryToRequests),
+	}, registryPredicate)
 	return
 }
 
+// registryPredicate passes ImageRegistry updates that affect the generated secrets only
+// since every update would otherwise enqueue all secret CRs that refer to the registry.
+// It passes hostname, CA and Ready condition changes.
+var registryPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCR, ok := e.ObjectOld.(*registryapi.ImageRegistry)
+		if !ok {
+			return true
+		}
+		newCR, ok := e.ObjectNew.(*registryapi.ImageRegistry)
+		if !ok {
+			return true
+		}
+		return oldCR.Status.Hostname != newCR.Status.Hostname ||
+			!equality.Semantic.DeepEqual(oldCR.Spec.Hostnames, newCR.Spec.Hostnames) ||
+			oldCR.Status.TLSSecretName != newCR.Status.TLSSecretName ||
+			!equality.Semantic.DeepEqual(oldCR.Status.TLSCertificate, newCR.Status.TLSCertificate) ||
+			!equality.Semantic.DeepEqual(oldCR.Status.CARotation, newCR.Status.CARotation) ||
+			oldCR.Status.Conditions.IsTrueFor(registryapi.ConditionReady) != newCR.Status.Conditions.IsTrueFor(registryapi.ConditionReady)
+	},
+}
+
 // ReconcileImageSecretConfig image secret CR reconciler
 type ReconcileImageSecretConfig struct {
 	Intent          registryapi.ImageSecretType
 	SecretType      corev1.SecretType
 	DockerConfigKey string
 	CRFactory       SecretResourceFactory
+	CRListFactory   func() runtime.Object
 	AccountLabel    string
 }
 
 // NewReconciler returns a new reconcile.Reconciler
-func NewReconciler(mgr manager.Manager, logger logr.Logger, cfg ReconcileImageSecretConfig) reconcile.Reconciler {
+func NewReconciler(mgr manager.Manager, logger logr.Logger, cfg ReconcileImageSecretConfig) *ReconcileImageSecret {
 	defaultRegistryRef := registryapi.ImageRegistryRef{
 		Name:      os.Getenv(EnvDefaultRegistryName),
 		Namespace: os.Getenv(EnvDefaultRegistryNamespace),
@@ -111,6 +153,7 @@ func NewReconciler(mgr manager.Manager, logger logr.Logger, cfg ReconcileImageSe
 		client:           mgr.GetClient(),
 		scheme:           mgr.GetScheme(),
 		cache:            mgr.GetCache(),
+		recorder:         mgr.GetEventRecorderFor(fmt.Sprintf("image%ssecret-controller", cfg.Intent)),
 		logger:           logger,
 		cfg:              cfg,
 		defaultRegistry:  defaultRegistryRef,
@@ -129,6 +172,7 @@ type ReconcileImageSecret struct {
 	client           client.Client
 	scheme           *runtime.Scheme
 	cache            cache.Cache
+	recorder         record.EventRecorder
 	logger           logr.Logger
 	cfg              ReconcileImageSecretConfig
 	defaultRegistry  registryapi.ImageRegistryRef
@@ -154,6 +198,7 @@ func (r *ReconcileImageSecret) Reconcile(request reconcile.Request) (reconcile.R
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			metrics.SecretNextRotation.Delete(request.Namespace, request.Name, string(r.cfg.Intent))
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -175,6 +220,7 @@ func (r *ReconcileImageSecret) Reconcile(request reconcile.Request) (reconcile.R
 			}
 			controllerutil.RemoveFinalizer(instance, finalizer)
 			err = r.client.Update(context.TODO(), instance)
+			metrics.SecretNextRotation.Delete(request.Namespace, request.Name, string(r.cfg.Intent))
 		}
 		return reconcile.Result{}, err
 	}
@@ -191,6 +237,11 @@ func (r *ReconcileImageSecret) Reconcile(request reconcile.Request) (reconcile.R
 		return reconcile.Result{}, nil
 	}
 
+	if err = validateRepositoryPrefix(instance); err != nil {
+		// No requeue since a spec change triggers another reconcile request anyway
+		return reconcile.Result{}, r.setSyncStatus(instance, registryapi.ConditionReady, corev1.ConditionFalse, registryapi.ReasonInvalidSpec, err.Error())
+	}
+
 	// Fetch the registry
 	registry, err := r.getRegistry(r.getRegistryKeyForCR(instance))
 	if err != nil {
@@ -210,7 +261,7 @@ func (r *ReconcileImageSecret) Reconcile(request reconcile.Request) (reconcile.R
 
 	// Fetch Secret
 	secret := &corev1.Secret{}
-	secret.Name = secretNameForCR(instance)
+	secret.Name = SecretNameForCR(instance)
 	secret.Namespace = instance.GetNamespace()
 	secretExists, err := r.get(context.TODO(), secret)
 	if err != nil {
@@ -218,17 +269,33 @@ func (r *ReconcileImageSecret) Reconcile(request reconcile.Request) (reconcile.R
 	}
 
 	// Update ImageRegistryAccount & Secret
-	hostnameCaChanged := string(secret.Data[registryapi.SecretKeyRegistry]) != registry.Hostname || string(secret.Data["ca.crt"]) != string(registry.CA)
+	hostnameCaChanged := string(secret.Data[registryapi.SecretKeyRegistry]) != registry.Hostname ||
+		secret.Annotations[annotationSecretAliases] != strings.Join(registry.Aliases, ",") ||
+		string(secret.Data["ca.crt"]) != string(registry.CA)
 	now := time.Now()
+	nextRotation := account.CreationTimestamp.Time.Add(r.rotationInterval)
 	needsRenewal := time.Now().Sub(account.CreationTimestamp.Time) > r.rotationInterval
 	secretOutOfSync := secret.Annotations == nil || secret.Annotations[annotationSecretRotation] != strconv.FormatInt(instance.GetStatus().Rotation, 10)
-	if !accountExists || !secretExists || secretOutOfSync || needsRenewal || hostnameCaChanged {
-		err = r.rotatePassword(instance, registry, secret, reqLogger)
+	catalogAccess, err := r.hasCatalogAccess(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	accountLabels := accountLabelsForCR(instance, catalogAccess)
+	accountLabelsChanged := !reflect.DeepEqual(account.Spec.Labels, accountLabels)
+	if !accountExists || !secretExists || secretOutOfSync || needsRenewal || hostnameCaChanged || accountLabelsChanged {
+		err = r.rotatePassword(instance, registry, secret, accountLabels, reqLogger)
 		if err != nil {
+			metrics.SecretRotationFailures.WithLabelValues(string(r.cfg.Intent)).Inc()
+			r.recorder.Eventf(instance, corev1.EventTypeWarning, registryapi.EventReasonRotationFailed, "Failed to rotate password: %s", err)
 			err = r.setSyncStatus(instance, registryapi.ConditionReady, corev1.ConditionFalse, registryapi.ReasonFailedSync, err.Error())
 			return reconcile.Result{}, err
 		}
+		metrics.SecretRotations.WithLabelValues(string(r.cfg.Intent)).Inc()
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, registryapi.EventReasonPasswordRotated,
+			"Rotated password using ImageRegistryAccount %s/%s", registry.Namespace, accountNameForCR(instance))
+		nextRotation = now.Add(r.rotationInterval)
 	}
+	metrics.SecretNextRotation.Set(nextRotation, request.Namespace, request.Name, string(r.cfg.Intent))
 
 	err = r.setSyncStatus(instance, registryapi.ConditionReady, corev1.ConditionTrue, "", "")
 	if err != nil {
@@ -276,7 +343,7 @@ func (r *ReconcileImageSecret) get(ctx context.Context, obj runtime.Object) (boo
 	return true, err
 }
 
-func (r *ReconcileImageSecret) rotatePassword(instance registryapi.ImageSecretInterface, registry *targetRegistry, secret *corev1.Secret, reqLogger logr.Logger) (err error) {
+func (r *ReconcileImageSecret) rotatePassword(instance registryapi.ImageSecretInterface, registry *targetRegistry, secret *corev1.Secret, accountLabels map[string][]string, reqLogger logr.Logger) (err error) {
 	newPassword := passwordgen.GeneratePassword()
 	newPasswordHash, err := passwordgen.BcryptPassword(newPassword)
 	if err != nil {
@@ -306,11 +373,7 @@ func (r *ReconcileImageSecret) rotatePassword(instance registryapi.ImageSecretIn
 	account.Labels = map[string]string{r.cfg.AccountLabel: backrefs.ToMapValue(crName)}
 	account.Spec.TTL = &metav1.Duration{r.accountTTL}
 	account.Spec.Password = string(newPasswordHash)
-	account.Spec.Labels = map[string][]string{
-		"namespace":  []string{instance.GetNamespace()},
-		"name":       []string{instance.GetName()},
-		"accessMode": []string{string(instance.GetRegistryAccessMode())},
-	}
+	account.Spec.Labels = accountLabels
 	reqLogger.Info("Creating ImageRegistryAccount", "ImageRegistryAccount.Namespace", account.Namespace, "ImageRegistryAccount.Name", account.Name)
 	err = r.client.Create(context.TODO(), account)
 	if err != nil {
@@ -318,21 +381,29 @@ func (r *ReconcileImageSecret) rotatePassword(instance registryapi.ImageSecretIn
 		// (doing the next attempt with incremented rotation count/name)
 		return
 	}
+	metrics.AccountsCreated.WithLabelValues(string(instance.GetRegistryAccessMode())).Inc()
 
 	if secret.Annotations == nil {
 		secret.Annotations = map[string]string{}
 	}
 	dockerConfig := (&registriesconf.DockerConfig{}).
-		AddAuth(registry.Hostname, account.Name, string(newPassword)).
-		JSON()
+		AddAuth(registry.Hostname, account.Name, string(newPassword))
+	for _, alias := range registry.Aliases {
+		dockerConfig.AddAuth(alias, account.Name, string(newPassword))
+	}
 	secret.Type = r.cfg.SecretType
 	secret.Annotations[annotationSecretRotation] = strconv.FormatInt(instance.GetStatus().Rotation, 10)
+	if len(registry.Aliases) > 0 {
+		secret.Annotations[annotationSecretAliases] = strings.Join(registry.Aliases, ",")
+	} else {
+		delete(secret.Annotations, annotationSecretAliases)
+	}
 	secret.Data = map[string][]byte{}
 	secret.Data[registryapi.SecretKeyUsername] = []byte(account.Name)
 	secret.Data[registryapi.SecretKeyPassword] = newPassword
 	secret.Data[registryapi.SecretKeyRegistry] = []byte(registry.Hostname)
 	secret.Data[registryapi.SecretKeyCaCert] = registry.CA
-	secret.Data[r.cfg.DockerConfigKey] = dockerConfig
+	secret.Data[r.cfg.DockerConfigKey] = dockerConfig.JSON()
 	if err = controllerutil.SetControllerReference(instance, secret, r.scheme); err != nil {
 		return
 	}
@@ -346,24 +417,132 @@ func (r *ReconcileImageSecret) rotatePassword(instance registryapi.ImageSecretIn
 	return
 }
 
+// accountLabelsForCR returns the labels the auth server's ACL matches an account of the secret CR by
+func accountLabelsForCR(cr registryapi.ImageSecretInterface, catalogAccess bool) map[string][]string {
+	labels := map[string][]string{
+		"namespace":  []string{cr.GetNamespace()},
+		"name":       []string{cr.GetName()},
+		"accessMode": []string{string(cr.GetRegistryAccessMode())},
+	}
+	if prefix := cr.GetRepositoryPrefix(); prefix != "" && cr.GetRegistryAccessMode() == registryapi.TypePush && validateRepositoryPrefix(cr) == nil {
+		labels["repositoryPrefix"] = []string{prefix}
+	}
+	if catalogAccess {
+		labels["catalog"] = []string{"true"}
+	}
+	return labels
+}
+
+// validateRepositoryPrefix returns an error if the secret CR's repository prefix is not below its namespace.
+// Otherwise the account could delete other namespaces' repositories.
+func validateRepositoryPrefix(cr registryapi.ImageSecretInterface) error {
+	prefix := cr.GetRepositoryPrefix()
+	if prefix == "" || cr.GetRegistryAccessMode() != registryapi.TypePush {
+		return nil
+	}
+	if !strings.HasPrefix(prefix, cr.GetNamespace()+"/") || strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("repositoryPrefix %q must start with the namespace followed by a slash (%s/...)", prefix, cr.GetNamespace())
+	}
+	return nil
+}
+
+// hasCatalogAccess returns true if the secret CR is maintained by an existing
+// ImageReplication or ImageRegistry (catalog inventory) which need to list the catalog.
+func (r *ReconcileImageSecret) hasCatalogAccess(cr registryapi.ImageSecretInterface) (bool, error) {
+	ref := metav1.GetControllerOf(cr)
+	if ref == nil || ref.APIVersion != registryapi.SchemeGroupVersion.String() {
+		return false, nil
+	}
+	var owner backrefs.Object
+	switch ref.Kind {
+	case "ImageReplication":
+		owner = &registryapi.ImageReplication{}
+	case "ImageRegistry":
+		owner = &registryapi.ImageRegistry{}
+	default:
+		return false, nil
+	}
+	key := types.NamespacedName{Name: ref.Name, Namespace: cr.GetNamespace()}
+	if err := r.client.Get(context.TODO(), key, owner); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return owner.GetUID() == ref.UID, nil
+}
+
 func accountNameForCR(cr registryapi.ImageSecretInterface) string {
 	return fmt.Sprintf("%s.%s.%s.%d", cr.GetRegistryAccessMode(), cr.GetNamespace(), cr.GetName(), cr.GetStatus().Rotation)
 }
 
-func secretNameForCR(cr registryapi.ImageSecretInterface) string {
+// ParseAccountName returns the access mode and the key of the secret CR
+// an ImageRegistryAccount name has been generated for.
+func ParseAccountName(accountName string) (mode registryapi.ImageSecretType, key types.NamespacedName, err error) {
+	// Namespaces cannot contain dots but names can
+	s := strings.Split(accountName, ".")
+	if len(s) < 4 {
+		return mode, key, fmt.Errorf("unsupported account name %q", accountName)
+	}
+	if _, e := strconv.ParseInt(s[len(s)-1], 10, 64); e != nil {
+		return mode, key, fmt.Errorf("unsupported account name %q: rotation suffix expected", accountName)
+	}
+	mode = registryapi.ImageSecretType(s[0])
+	if mode != registryapi.TypePull && mode != registryapi.TypePush {
+		return mode, key, fmt.Errorf("unsupported account name %q: unknown access mode %q", accountName, mode)
+	}
+	key.Namespace = s[1]
+	key.Name = strings.Join(s[2:len(s)-1], ".")
+	return
+}
+
+// SecretNameForCR returns the name of the Secret generated for a secret CR
+func SecretNameForCR(cr registryapi.ImageSecretInterface) string {
 	return fmt.Sprintf("image%ssecret-%s", cr.GetRegistryAccessMode(), cr.GetName())
 }
 
 func (r *ReconcileImageSecret) getRegistryKeyForCR(cr registryapi.ImageSecretInterface) (reg types.NamespacedName) {
-	registry := cr.GetRegistryRef()
-	if registry == nil {
-		registry = &r.defaultRegistry
-	} else if registry.Namespace == "" {
-		registry.Namespace = cr.GetNamespace()
+	registry := r.defaultRegistry
+	if ref := cr.GetRegistryRef(); ref != nil {
+		registry = *ref
+		if registry.Namespace == "" {
+			registry.Namespace = cr.GetNamespace()
+		}
 	}
 	return types.NamespacedName{Name: registry.Name, Namespace: registry.Namespace}
 }
 
+// indexRegistryKey returns the key of the ImageRegistry a secret CR refers to
+func (r *ReconcileImageSecret) indexRegistryKey(o runtime.Object) []string {
+	cr, ok := o.(registryapi.ImageSecretInterface)
+	if !ok {
+		return nil
+	}
+	return []string{r.getRegistryKeyForCR(cr).String()}
+}
+
+// registryToRequests maps an ImageRegistry to requests for all secret CRs that refer to it
+func (r *ReconcileImageSecret) registryToRequests(o handler.MapObject) (requests []reconcile.Request) {
+	registryKey := types.NamespacedName{Name: o.Meta.GetName(), Namespace: o.Meta.GetNamespace()}
+	list := r.cfg.CRListFactory()
+	err := r.client.List(context.TODO(), list, client.MatchingFields{indexRegistryKey: registryKey.String()})
+	if err != nil {
+		r.logger.Error(err, "failed to list secret CRs for ImageRegistry", "ImageRegistry.Namespace", registryKey.Namespace, "ImageRegistry.Name", registryKey.Name)
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		r.logger.Error(err, "failed to list secret CRs for ImageRegistry", "ImageRegistry.Namespace", registryKey.Namespace, "ImageRegistry.Name", registryKey.Name)
+		return nil
+	}
+	for _, item := range items {
+		if cr, ok := item.(metav1.Object); ok {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: cr.GetName(), Namespace: cr.GetNamespace()}})
+		}
+	}
+	return
+}
+
 func (r *ReconcileImageSecret) getRegistry(registryKey types.NamespacedName) (reg *targetRegistry, err error) {
 	ctx := context.TODO()
 	registryCR := &registryapi.ImageRegistry{}
@@ -381,9 +560,11 @@ func (r *ReconcileImageSecret) getRegistry(registryKey types.NamespacedName) (re
 	if err = r.cache.Get(ctx, key, secret); err != nil {
 		return
 	}
+	hostnames := imageregistry.RegistryHostnames(registryCR, r.dnsZone)
 	return &targetRegistry{
 		Namespace: registryCR.GetNamespace(),
-		Hostname:  imageregistry.RegistryHostname(registryCR, r.dnsZone),
+		Hostname:  hostnames[0],
+		Aliases:   hostnames[1:],
 		CA:        secret.Data[registryapi.SecretKeyCaCert],
 	}, nil
 }
@@ -391,5 +572,6 @@ func (r *ReconcileImageSecret) getRegistry(registryKey types.NamespacedName) (re
 type targetRegistry struct {
 	Namespace string
 	Hostname  string
+	Aliases   []string
 	CA        []byte
 }
@@ -0,0 +1,236 @@
+package imagesecret
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestIndexRegistryKey(t *testing.T) {
+	r := &ReconcileImageSecret{defaultRegistry: registryapi.ImageRegistryRef{Name: "registry", Namespace: "opns"}}
+	for _, c := range []struct {
+		name     string
+		ref      *registryapi.ImageRegistryRef
+		expected string
+	}{
+		{"default registry", nil, "opns/registry"},
+		{"same namespace", &registryapi.ImageRegistryRef{Name: "myregistry"}, "myns/myregistry"},
+		{"other namespace", &registryapi.ImageRegistryRef{Name: "myregistry", Namespace: "otherns"}, "otherns/myregistry"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cr := &registryapi.ImagePushSecret{}
+			cr.Name = "mysecret"
+			cr.Namespace = "myns"
+			cr.Spec.RegistryRef = c.ref
+			var refBefore *registryapi.ImageRegistryRef
+			if c.ref != nil {
+				refBefore = c.ref.DeepCopy()
+			}
+			require.Equal(t, []string{c.expected}, r.indexRegistryKey(cr))
+			require.Equal(t, refBefore, cr.Spec.RegistryRef, "indexer must not modify the CR")
+		})
+	}
+	require.Nil(t, r.indexRegistryKey(&registryapi.ImageRegistry{}), "non-secret object")
+}
+
+// indexedClient emulates the cache's field index since the fake client ignores field selectors
+type indexedClient struct {
+	client.Client
+	field   string
+	indexer func(runtime.Object) []string
+}
+
+func (c *indexedClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector == nil {
+		return nil
+	}
+	value, found := listOpts.FieldSelector.RequiresExactMatch(c.field)
+	if !found {
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	filtered := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		for _, v := range c.indexer(item) {
+			if v == value {
+				filtered = append(filtered, item)
+				break
+			}
+		}
+	}
+	return meta.SetList(list, filtered)
+}
+
+func TestRegistryToRequests(t *testing.T) {
+	newSecret := func(name, namespace string, ref *registryapi.ImageRegistryRef) *registryapi.ImagePushSecret {
+		cr := &registryapi.ImagePushSecret{}
+		cr.Name = name
+		cr.Namespace = namespace
+		cr.Spec.RegistryRef = ref
+		return cr
+	}
+	scheme := runtime.NewScheme()
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	r := &ReconcileImageSecret{
+		defaultRegistry: registryapi.ImageRegistryRef{Name: "registry", Namespace: "opns"},
+		cfg:             ReconcileImageSecretConfig{CRListFactory: func() runtime.Object { return &registryapi.ImagePushSecretList{} }},
+		logger:          logf.Log,
+	}
+	r.client = &indexedClient{
+		Client: fake.NewFakeClientWithScheme(scheme,
+			newSecret("default", "myns", nil),
+			newSecret("same-ns", "opns", &registryapi.ImageRegistryRef{Name: "registry"}),
+			newSecret("other-registry", "opns", &registryapi.ImageRegistryRef{Name: "other"}),
+			newSecret("cross-ns", "myns", &registryapi.ImageRegistryRef{Name: "registry", Namespace: "opns"}),
+			newSecret("other-ns", "myns", &registryapi.ImageRegistryRef{Name: "registry"}),
+		),
+		field:   indexRegistryKey,
+		indexer: r.indexRegistryKey,
+	}
+	for _, c := range []struct {
+		name      string
+		namespace string
+		expected  []string
+	}{
+		{"registry", "opns", []string{"myns/cross-ns", "myns/default", "opns/same-ns"}},
+		{"registry", "myns", []string{"myns/other-ns"}},
+		{"other", "opns", []string{"opns/other-registry"}},
+		{"unreferenced", "opns", nil},
+	} {
+		t.Run(c.namespace+"/"+c.name, func(t *testing.T) {
+			registry := &registryapi.ImageRegistry{}
+			registry.Name = c.name
+			registry.Namespace = c.namespace
+			var requested []string
+			for _, req := range r.registryToRequests(handler.MapObject{Meta: registry, Object: registry}) {
+				requested = append(requested, req.NamespacedName.String())
+			}
+			sort.Strings(requested)
+			require.Equal(t, c.expected, requested)
+		})
+	}
+}
+
+func TestRegistryPredicate(t *testing.T) {
+	newRegistry := func(modify func(*registryapi.ImageRegistry)) *registryapi.ImageRegistry {
+		cr := &registryapi.ImageRegistry{}
+		cr.Name = "registry"
+		cr.Status.Hostname = "registry.example.org"
+		cr.Status.TLSSecretName = "registry-tls"
+		cr.Status.TLSCertificate = &registryapi.CertificateStatus{SecretName: "registry-tls", Issuer: "CN=ca"}
+		cr.Status.Conditions = status.Conditions{}
+		cr.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionReady, Status: corev1.ConditionTrue})
+		modify(cr)
+		return cr
+	}
+	for _, c := range []struct {
+		name     string
+		modify   func(*registryapi.ImageRegistry)
+		expected bool
+	}{
+		{"unchanged", func(cr *registryapi.ImageRegistry) {}, false},
+		{"replica status", func(cr *registryapi.ImageRegistry) { cr.Status.ReadyReplicas = 2 }, false},
+		{"other condition", func(cr *registryapi.ImageRegistry) {
+			cr.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionTLSCertificate, Status: corev1.ConditionTrue})
+		}, false},
+		{"hostname", func(cr *registryapi.ImageRegistry) { cr.Status.Hostname = "other.example.org" }, true},
+		{"hostname aliases", func(cr *registryapi.ImageRegistry) {
+			cr.Spec.Hostnames = []string{"registry.example.org", "alias.example.org"}
+		}, true},
+		{"tls secret", func(cr *registryapi.ImageRegistry) { cr.Status.TLSSecretName = "other-tls" }, true},
+		{"certificate issuer", func(cr *registryapi.ImageRegistry) { cr.Status.TLSCertificate.Issuer = "CN=newca" }, true},
+		{"ca rotation", func(cr *registryapi.ImageRegistry) { cr.Status.CARotation = &registryapi.CARotationStatus{} }, true},
+		{"unready", func(cr *registryapi.ImageRegistry) {
+			cr.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionReady, Status: corev1.ConditionFalse})
+		}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			oldCR := newRegistry(func(*registryapi.ImageRegistry) {})
+			newCR := newRegistry(c.modify)
+			e := event.UpdateEvent{MetaOld: oldCR, ObjectOld: oldCR, MetaNew: newCR, ObjectNew: newCR}
+			require.Equal(t, c.expected, registryPredicate.Update(e))
+		})
+	}
+}
+
+func TestAccountLabelsCatalogAccess(t *testing.T) {
+	replication := &registryapi.ImageReplication{}
+	replication.Name = "myreplication"
+	replication.Namespace = "myns"
+	replication.UID = "replication-uid"
+	scheme := runtime.NewScheme()
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	r := &ReconcileImageSecret{client: fake.NewFakeClientWithScheme(scheme, replication)}
+	isController := true
+	for _, c := range []struct {
+		name     string
+		owner    *metav1.OwnerReference
+		expected bool
+	}{
+		{"no owner", nil, false},
+		{"replication", &metav1.OwnerReference{APIVersion: registryapi.SchemeGroupVersion.String(), Kind: "ImageReplication", Name: "myreplication", UID: "replication-uid", Controller: &isController}, true},
+		{"forged replication uid", &metav1.OwnerReference{APIVersion: registryapi.SchemeGroupVersion.String(), Kind: "ImageReplication", Name: "myreplication", UID: "other-uid", Controller: &isController}, false},
+		{"missing replication", &metav1.OwnerReference{APIVersion: registryapi.SchemeGroupVersion.String(), Kind: "ImageReplication", Name: "missing", UID: "replication-uid", Controller: &isController}, false},
+		{"other kind", &metav1.OwnerReference{APIVersion: registryapi.SchemeGroupVersion.String(), Kind: "ImageBuildEnv", Name: "myreplication", UID: "replication-uid", Controller: &isController}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cr := &registryapi.ImagePullSecret{}
+			cr.Name = "mysecret"
+			cr.Namespace = "myns"
+			if c.owner != nil {
+				cr.OwnerReferences = []metav1.OwnerReference{*c.owner}
+			}
+			catalogAccess, err := r.hasCatalogAccess(cr)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, catalogAccess, "catalog access")
+			_, hasLabel := accountLabelsForCR(cr, catalogAccess)["catalog"]
+			require.Equal(t, c.expected, hasLabel, "catalog label")
+		})
+	}
+}
+
+func TestAccountLabelsRepositoryPrefix(t *testing.T) {
+	for _, c := range []struct {
+		prefix   string
+		valid    bool
+		expected []string
+	}{
+		{"", true, nil},
+		{"myns/cache", true, []string{"myns/cache"}},
+		{"otherns/cache", false, nil},
+		{"mynsx/cache", false, nil},
+		{"myns", false, nil},
+		{"myns/", false, nil},
+	} {
+		t.Run(c.prefix, func(t *testing.T) {
+			cr := &registryapi.ImagePushSecret{}
+			cr.Name = "mysecret"
+			cr.Namespace = "myns"
+			cr.Spec.RepositoryPrefix = c.prefix
+			err := validateRepositoryPrefix(cr)
+			require.Equal(t, c.valid, err == nil, "valid (error: %v)", err)
+			require.Equal(t, c.expected, accountLabelsForCR(cr, false)["repositoryPrefix"], "repositoryPrefix label")
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package registrybackup
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	modeBackup        = "backup"
+	modeRestore       = "restore"
+	registryMountPath = "/var/lib/registry"
+	backupMountPath   = "/backup"
+	jobBackoffLimit   = int32(2)
+	labelJobName      = "job-name"
+	// maxJobNameLength is the max length of a label value since the Job's name is used as its Pods' job-name label value
+	maxJobNameLength    = 63
+	topologyKeyHostname = "kubernetes.io/hostname"
+)
+
+// jobSpec specifies a backup or restore Job
+type jobSpec struct {
+	Name        string
+	Namespace   string
+	Image       string
+	Mode        string
+	RegistryPVC string
+	// RegistryPodLabels select the registry Pods the Job must run next to since they mount the PVC (optional)
+	RegistryPodLabels map[string]string
+	Storage           *registryapi.BackupStorageSpec
+	Archive           string
+	Checksum          string
+}
+
+// newJobSpec returns a Job spec for the registry's storage.
+// Unless the registry's PVC can be mounted on multiple nodes the Job is scheduled on a registry Pod's node.
+func newJobSpec(mode, name string, registry *registryapi.ImageRegistry) *jobSpec {
+	spec := &jobSpec{
+		Name:        jobName("registry"+mode, name),
+		Mode:        mode,
+		RegistryPVC: imageregistry.PVCNameForCR(registry),
+	}
+	if !imageregistry.PVCSharedForCR(registry) {
+		spec.RegistryPodLabels = imageregistry.PodLabelsForCR(registry)
+	}
+	return spec
+}
+
+// jobName returns the Job's name.
+// Names that exceed the label value length are truncated and suffixed with a hash of the CR's name to keep them unique.
+func jobName(prefix, crName string) string {
+	name := prefix + "-" + crName
+	if len(name) <= maxJobNameLength {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(crName)))[:8]
+	return strings.TrimRight(name[:maxJobNameLength-len(hash)-1], "-.") + "-" + hash
+}
+
+// archiveResult is written by the Job into its termination message
+type archiveResult struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+func newJob(spec *jobSpec) (*batchv1.Job, error) {
+	storage := spec.Storage
+	env := []corev1.EnvVar{
+		{Name: "REGISTRY_DIR", Value: registryMountPath},
+		{Name: "BACKUP_ARCHIVE", Value: spec.Archive},
+		{Name: "BACKUP_CHECKSUM", Value: spec.Checksum},
+	}
+	volumes := []corev1.Volume{
+		{
+			Name: "registry",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: spec.RegistryPVC,
+				ReadOnly:  spec.Mode == modeBackup,
+			}},
+		},
+	}
+	backupVolume := corev1.Volume{Name: "backup"}
+	switch {
+	case storage.PersistentVolumeClaim != nil:
+		backupVolume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: storage.PersistentVolumeClaim.ClaimName,
+			ReadOnly:  spec.Mode == modeRestore,
+		}
+		env = append(env, corev1.EnvVar{Name: "BACKUP_DIR", Value: path.Join(backupMountPath, storage.PersistentVolumeClaim.Path)})
+	case storage.S3 != nil:
+		s3 := storage.S3
+		backupVolume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		env = append(env,
+			corev1.EnvVar{Name: "BACKUP_DIR", Value: backupMountPath},
+			corev1.EnvVar{Name: "S3_BUCKET", Value: s3.Bucket},
+			corev1.EnvVar{Name: "S3_PREFIX", Value: s3.Prefix},
+			corev1.EnvVar{Name: "S3_ENDPOINT", Value: s3.Endpoint},
+			corev1.EnvVar{Name: "AWS_DEFAULT_REGION", Value: s3.Region},
+		)
+		if s3.SecretName != "" {
+			for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+				env = append(env, corev1.EnvVar{
+					Name: key,
+					ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: s3.SecretName},
+						Key:                  key,
+					}},
+				})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("neither persistentVolumeClaim nor s3 backup storage specified")
+	}
+	volumes = append(volumes, backupVolume)
+	backoffLimit := jobBackoffLimit
+	job := &batchv1.Job{}
+	job.Name = spec.Name
+	job.Namespace = spec.Namespace
+	job.Spec.BackoffLimit = &backoffLimit
+	job.Spec.Template.Spec = corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Affinity:      registryPodAffinity(spec.RegistryPodLabels),
+		Volumes:       volumes,
+		Containers: []corev1.Container{
+			{
+				Name:            spec.Mode,
+				Image:           spec.Image,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Command:         []string{"registry-backup", spec.Mode},
+				Env:             env,
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "registry", MountPath: registryMountPath},
+					{Name: "backup", MountPath: backupMountPath},
+				},
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			},
+		},
+	}
+	return job, nil
+}
+
+// registryPodAffinity schedules the Job on the node of a Pod matching the labels
+// since a ReadWriteOnce volume cannot be attached to multiple nodes
+func registryPodAffinity(labels map[string]string) *corev1.Affinity {
+	if len(labels) == 0 {
+		return nil
+	}
+	return &corev1.Affinity{PodAffinity: &corev1.PodAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+			TopologyKey:   topologyKeyHostname,
+		}},
+	}}
+}
+
+// jobFinished returns true if the Job succeeded or failed
+func jobFinished(job *batchv1.Job) (finished bool, failed bool, msg string) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, false, ""
+		case batchv1.JobFailed:
+			return true, true, c.Message
+		}
+	}
+	return false, false, ""
+}
+
+// parseArchiveResult reads the archive's size and checksum from the succeeded Pod's termination message
+func parseArchiveResult(pods []corev1.Pod) (*archiveResult, error) {
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.State.Terminated == nil || c.State.Terminated.Message == "" {
+				continue
+			}
+			result := &archiveResult{}
+			if err := json.Unmarshal([]byte(c.State.Terminated.Message), result); err != nil {
+				return nil, fmt.Errorf("parse termination message of pod %s: %w", pod.Name, err)
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no succeeded pod with termination message found")
+}
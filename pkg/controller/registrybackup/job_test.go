@@ -0,0 +1,57 @@
+package registrybackup
+
+import (
+	"strings"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestJobName(t *testing.T) {
+	require.Equal(t, "registrybackup-mybackup", jobName("registrybackup", "mybackup"), "short name")
+	names := map[string]bool{}
+	for _, name := range []string{strings.Repeat("a", 47), strings.Repeat("a", 48), strings.Repeat("a", 60), strings.Repeat("a", 52) + "-b"} {
+		jobName := jobName("registryrestore", name)
+		require.Empty(t, validation.IsValidLabelValue(jobName), "invalid label value %q", jobName)
+		require.Empty(t, validation.IsDNS1123Label(jobName), "invalid name %q", jobName)
+		require.False(t, names[jobName], "name %q is not unique", jobName)
+		names[jobName] = true
+	}
+}
+
+func TestNewJobRegistryPodAffinity(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		accessModes []corev1.PersistentVolumeAccessMode
+		affinity    bool
+	}{
+		{"default", nil, false},
+		{"ReadWriteMany", []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}, false},
+		{"ReadWriteOnce", []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			registry := &registryapi.ImageRegistry{}
+			registry.Name = "registry"
+			registry.Spec.PersistentVolumeClaim.AccessModes = c.accessModes
+			spec := newJobSpec(modeBackup, "mybackup", registry)
+			spec.Storage = &registryapi.BackupStorageSpec{PersistentVolumeClaim: &registryapi.BackupPVCSpec{ClaimName: "backups"}}
+			job, err := newJob(spec)
+			require.NoError(t, err)
+			require.Equal(t, "registrybackup-mybackup", job.Name, "name")
+			affinity := job.Spec.Template.Spec.Affinity
+			if !c.affinity {
+				require.Nil(t, affinity, "affinity")
+				return
+			}
+			require.NotNil(t, affinity, "affinity")
+			require.NotNil(t, affinity.PodAffinity, "podAffinity")
+			terms := affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+			require.Len(t, terms, 1, "required pod affinity terms")
+			require.Equal(t, topologyKeyHostname, terms[0].TopologyKey, "topologyKey")
+			require.Equal(t, map[string]string{"app": "imageregistry-registry"}, terms[0].LabelSelector.MatchLabels, "matchLabels")
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package registrybackup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/merge"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	EnvImageBackup = "OPERATOR_IMAGE_BACKUP"
+	// annotationMaintenanceOwner lists the backups and restores that require an ImageRegistry to be read-only in registration order
+	annotationMaintenanceOwner = "registry.mgoltzsche.github.com/maintenance-owner"
+	// annotationMaintenanceManaged is set when the operator switched an ImageRegistry into read-only mode
+	// and must switch it back once all owners released it
+	annotationMaintenanceManaged = "registry.mgoltzsche.github.com/maintenance-managed"
+	finalizer                    = "registry.mgoltzsche.github.com/maintenance"
+	requeueDelayUnavailable      = 30 * time.Second
+	kindRestore                  = "RegistryRestore"
+)
+
+type namespacedObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// maintenanceJob adapts a RegistryBackup or RegistryRestore to the common Job reconciliation:
+// the registry is switched into read-only mode while the Job runs.
+type maintenanceJob struct {
+	cr         namespacedObject
+	kind       string
+	registry   string
+	phase      *registryapi.BackupPhase
+	conditions *status.Conditions
+	archive    *registryapi.BackupArchiveStatus
+	// newRegistry specifies the ImageRegistry to create if it does not exist (optional)
+	newRegistry *registryapi.ImageRegistrySpec
+	// jobSpec returns the Job to run once the registry is read-only
+	jobSpec func(registry *registryapi.ImageRegistry) (*jobSpec, error)
+}
+
+type jobReconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+	image  string
+}
+
+func newJobReconciler(client client.Client, scheme *runtime.Scheme) jobReconciler {
+	image := os.Getenv(EnvImageBackup)
+	if image == "" {
+		image = "mgoltzsche/image-registry-operator:latest-backup"
+	}
+	return jobReconciler{client, scheme, image}
+}
+
+func (r *jobReconciler) reconcileJob(t *maintenanceJob, reqLogger logr.Logger) (reconcile.Result, error) {
+	ctx := context.TODO()
+	owner := fmt.Sprintf("%s/%s", t.kind, t.cr.GetName())
+
+	// finalize: Release the registry's read-only mode
+	if !t.cr.GetDeletionTimestamp().IsZero() {
+		if merge.HasFinalizer(t.cr, finalizer) {
+			reqLogger.Info("Finalizing")
+			if err := r.releaseRegistry(t, owner, reqLogger); err != nil {
+				return reconcile.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(t.cr, finalizer)
+			return reconcile.Result{}, r.client.Update(ctx, t.cr)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if *t.phase == registryapi.BackupPhaseSucceeded || *t.phase == registryapi.BackupPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	// Add finalizer
+	if !merge.HasFinalizer(t.cr, finalizer) {
+		controllerutil.AddFinalizer(t.cr, finalizer)
+		// Stop here since update triggers another reconcile request anyway
+		return reconcile.Result{}, r.client.Update(ctx, t.cr)
+	}
+
+	// Fetch the registry
+	registry := &registryapi.ImageRegistry{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: t.registry, Namespace: t.cr.GetNamespace()}, registry)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if t.newRegistry != nil && t.archive.JobName == "" {
+				return reconcile.Result{}, r.createRegistry(t, owner, reqLogger)
+			}
+			err = r.updateStatus(t, registryapi.BackupPhasePending, corev1.ConditionFalse, registryapi.ReasonRegistryUnavailable, err.Error())
+			return reconcile.Result{RequeueAfter: requeueDelayUnavailable}, err
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Start Job once the registry is read-only
+	if t.archive.JobName == "" {
+		readOnly, err := r.acquireReadOnly(registry, owner, fmt.Sprintf("%s in progress", owner), reqLogger)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if !readOnly {
+			msg := "waiting for the registry to become read-only"
+			if !mayStart(maintenanceOwners(registry), owner) {
+				msg = "waiting for other maintenance jobs to finish"
+			}
+			err = r.updateStatus(t, registryapi.BackupPhasePending, corev1.ConditionFalse, registryapi.ReasonPending, msg)
+			return reconcile.Result{}, err
+		}
+		spec, err := t.jobSpec(registry)
+		if err != nil {
+			return reconcile.Result{}, r.finish(t, registryapi.BackupPhaseFailed, registryapi.ReasonFailedSync, err.Error(), owner, reqLogger)
+		}
+		spec.Namespace = t.cr.GetNamespace()
+		if spec.Image == "" {
+			spec.Image = r.image
+		}
+		job, err := newJob(spec)
+		if err != nil {
+			return reconcile.Result{}, r.finish(t, registryapi.BackupPhaseFailed, registryapi.ReasonFailedSync, err.Error(), owner, reqLogger)
+		}
+		if err = controllerutil.SetControllerReference(t.cr, job, r.scheme); err != nil {
+			return reconcile.Result{}, err
+		}
+		reqLogger.Info("Creating Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		if err = r.client.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+			return reconcile.Result{}, err
+		}
+		now := metav1.Now()
+		t.archive.JobName = job.Name
+		t.archive.Archive = spec.Archive
+		t.archive.Checksum = spec.Checksum
+		t.archive.StartTime = &now
+		err = r.updateStatus(t, registryapi.BackupPhaseRunning, corev1.ConditionFalse, registryapi.ReasonPending, "job running")
+		return reconcile.Result{}, err
+	}
+
+	// Wait for the Job to finish
+	job := &batchv1.Job{}
+	err = r.client.Get(ctx, types.NamespacedName{Name: t.archive.JobName, Namespace: t.cr.GetNamespace()}, job)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, r.finish(t, registryapi.BackupPhaseFailed, registryapi.ReasonJobFailed, "job has been deleted", owner, reqLogger)
+		}
+		return reconcile.Result{}, err
+	}
+	finished, failed, msg := jobFinished(job)
+	if !finished {
+		return reconcile.Result{}, nil
+	}
+	if failed {
+		return reconcile.Result{}, r.finish(t, registryapi.BackupPhaseFailed, registryapi.ReasonJobFailed, msg, owner, reqLogger)
+	}
+	pods := &corev1.PodList{}
+	err = r.client.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{labelJobName: job.Name})
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	result, err := parseArchiveResult(pods.Items)
+	if err != nil {
+		return reconcile.Result{}, r.finish(t, registryapi.BackupPhaseFailed, registryapi.ReasonJobFailed, err.Error(), owner, reqLogger)
+	}
+	t.archive.Size = result.Size
+	t.archive.Checksum = result.Checksum
+	return reconcile.Result{}, r.finish(t, registryapi.BackupPhaseSucceeded, "", "", owner, reqLogger)
+}
+
+// finish releases the registry and writes the final status
+func (r *jobReconciler) finish(t *maintenanceJob, phase registryapi.BackupPhase, reason status.ConditionReason, msg, owner string, reqLogger logr.Logger) (err error) {
+	if err = r.releaseRegistry(t, owner, reqLogger); err != nil {
+		return
+	}
+	now := metav1.Now()
+	t.archive.CompletionTime = &now
+	ready := corev1.ConditionTrue
+	if phase == registryapi.BackupPhaseFailed {
+		ready = corev1.ConditionFalse
+	}
+	if err = r.updateStatus(t, phase, ready, reason, msg); err != nil {
+		return
+	}
+	controllerutil.RemoveFinalizer(t.cr, finalizer)
+	return r.client.Update(context.TODO(), t.cr)
+}
+
+func (r *jobReconciler) updateStatus(t *maintenanceJob, phase registryapi.BackupPhase, ready corev1.ConditionStatus, reason status.ConditionReason, msg string) error {
+	t.conditions.SetCondition(status.Condition{
+		Type:    registryapi.ConditionReady,
+		Status:  ready,
+		Reason:  reason,
+		Message: msg,
+	})
+	*t.phase = phase
+	return r.client.Status().Update(context.TODO(), t.cr)
+}
+
+// createRegistry creates the ImageRegistry a restore refers to in read-only mode on behalf of the owner
+func (r *jobReconciler) createRegistry(t *maintenanceJob, owner string, reqLogger logr.Logger) error {
+	registry := &registryapi.ImageRegistry{}
+	registry.Name = t.registry
+	registry.Namespace = t.cr.GetNamespace()
+	registry.Spec = *t.newRegistry.DeepCopy()
+	registry.Annotations = map[string]string{}
+	setMaintenanceOwners(registry, []string{owner})
+	if !registry.Spec.ReadOnly {
+		registry.Annotations[annotationMaintenanceManaged] = "true"
+		registry.Spec.ReadOnly = true
+		registry.Spec.MaintenanceMessage = fmt.Sprintf("%s in progress", owner)
+	}
+	reqLogger.Info("Creating read-only ImageRegistry", "ImageRegistry.Name", registry.Name)
+	if err := r.client.Create(context.TODO(), registry); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return r.updateStatus(t, registryapi.BackupPhasePending, corev1.ConditionFalse, registryapi.ReasonPending, "waiting for the registry to become read-only")
+}
+
+// acquireReadOnly registers the owner at the registry and switches the registry into read-only mode.
+// It returns true once the read-only registry has been rolled out and the owner may start its Job.
+// The registry's update conflicts if another owner registered concurrently which lets the reconcile request be retried.
+func (r *jobReconciler) acquireReadOnly(registry *registryapi.ImageRegistry, owner, msg string, reqLogger logr.Logger) (bool, error) {
+	owners := maintenanceOwners(registry)
+	if !containsOwner(owners, owner) || !registry.Spec.ReadOnly {
+		if registry.Annotations == nil {
+			registry.Annotations = map[string]string{}
+		}
+		if !containsOwner(owners, owner) {
+			setMaintenanceOwners(registry, append(owners, owner))
+		}
+		if !registry.Spec.ReadOnly {
+			registry.Annotations[annotationMaintenanceManaged] = "true"
+			registry.Spec.ReadOnly = true
+			registry.Spec.MaintenanceMessage = msg
+		}
+		reqLogger.Info("Switching ImageRegistry into read-only mode", "ImageRegistry.Name", registry.Name)
+		return false, r.client.Update(context.TODO(), registry)
+	}
+	return mayStart(owners, owner) &&
+		registry.Status.ObservedGeneration == registry.Generation &&
+		registry.Status.Conditions.IsTrueFor(registryapi.ConditionMaintenance) &&
+		registry.Status.Conditions.IsTrueFor(registryapi.ConditionReady), nil
+}
+
+// releaseRegistry unregisters the owner and disables the registry's read-only mode
+// if it has been enabled by the operator and no other owner requires it anymore
+func (r *jobReconciler) releaseRegistry(t *maintenanceJob, owner string, reqLogger logr.Logger) error {
+	registry := &registryapi.ImageRegistry{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: t.registry, Namespace: t.cr.GetNamespace()}, registry)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	owners := maintenanceOwners(registry)
+	if !containsOwner(owners, owner) {
+		return nil
+	}
+	remaining := make([]string, 0, len(owners))
+	for _, o := range owners {
+		if o != owner {
+			remaining = append(remaining, o)
+		}
+	}
+	setMaintenanceOwners(registry, remaining)
+	if len(remaining) == 0 && registry.Annotations[annotationMaintenanceManaged] == "true" {
+		delete(registry.Annotations, annotationMaintenanceManaged)
+		registry.Spec.ReadOnly = false
+		registry.Spec.MaintenanceMessage = ""
+		reqLogger.Info("Switching ImageRegistry into read-write mode", "ImageRegistry.Name", registry.Name)
+	}
+	return r.client.Update(context.TODO(), registry)
+}
+
+// mayStart returns true if the owner's Job may run concurrently with the preceding owners' Jobs:
+// backups may run concurrently but a restore requires exclusive access to the registry's storage.
+func mayStart(owners []string, owner string) bool {
+	for _, o := range owners {
+		if o == owner {
+			return true
+		}
+		if isRestore(o) || isRestore(owner) {
+			return false
+		}
+	}
+	return false
+}
+
+func isRestore(owner string) bool {
+	return strings.HasPrefix(owner, kindRestore+"/")
+}
+
+func maintenanceOwners(registry *registryapi.ImageRegistry) (owners []string) {
+	for _, o := range strings.Split(registry.Annotations[annotationMaintenanceOwner], ",") {
+		if o != "" {
+			owners = append(owners, o)
+		}
+	}
+	return
+}
+
+func setMaintenanceOwners(registry *registryapi.ImageRegistry, owners []string) {
+	if len(owners) == 0 {
+		delete(registry.Annotations, annotationMaintenanceOwner)
+		return
+	}
+	registry.Annotations[annotationMaintenanceOwner] = strings.Join(owners, ",")
+}
+
+func containsOwner(owners []string, owner string) bool {
+	for _, o := range owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
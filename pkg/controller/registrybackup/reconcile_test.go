@@ -0,0 +1,133 @@
+package registrybackup
+
+import (
+	"context"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMayStart(t *testing.T) {
+	for _, c := range []struct {
+		owners   []string
+		owner    string
+		expected bool
+	}{
+		{[]string{"RegistryBackup/a"}, "RegistryBackup/a", true},
+		{[]string{"RegistryBackup/a", "RegistryBackup/b"}, "RegistryBackup/b", true},
+		{[]string{"RegistryBackup/a", "RegistryRestore/b"}, "RegistryRestore/b", false},
+		{[]string{"RegistryRestore/a", "RegistryBackup/b"}, "RegistryRestore/a", true},
+		{[]string{"RegistryRestore/a", "RegistryBackup/b"}, "RegistryBackup/b", false},
+		{[]string{"RegistryBackup/a"}, "RegistryBackup/b", false},
+	} {
+		require.Equal(t, c.expected, mayStart(c.owners, c.owner), "mayStart(%v, %s)", c.owners, c.owner)
+	}
+}
+
+func TestAcquireAndReleaseReadOnly(t *testing.T) {
+	registry := &registryapi.ImageRegistry{}
+	registry.Name = "registry"
+	registry.Namespace = "myns"
+	r := newTestJobReconciler(t, registry)
+	backup := testMaintenanceJob("RegistryBackup", "backup", registry.Name)
+	restore := testMaintenanceJob(kindRestore, "restore", registry.Name)
+	backupOwner, restoreOwner := "RegistryBackup/backup", "RegistryRestore/restore"
+	logger := logf.Log
+
+	acquire := func(owner string) bool {
+		reg := getRegistry(t, r.client, registry.Name)
+		reg.Status.Conditions = status.Conditions{}
+		reg.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionMaintenance, Status: corev1.ConditionTrue})
+		reg.Status.Conditions.SetCondition(status.Condition{Type: registryapi.ConditionReady, Status: corev1.ConditionTrue})
+		readOnly, err := r.acquireReadOnly(reg, owner, owner+" in progress", logger)
+		require.NoError(t, err)
+		return readOnly
+	}
+
+	require.False(t, acquire(backupOwner), "backup: first acquire should switch registry into read-only mode")
+	require.False(t, acquire(restoreOwner), "restore: first acquire should register owner")
+	require.True(t, acquire(backupOwner), "backup should start before the later restore")
+	require.False(t, acquire(restoreOwner), "restore should wait for the preceding backup")
+	reg := getRegistry(t, r.client, registry.Name)
+	require.Equal(t, backupOwner+","+restoreOwner, reg.Annotations[annotationMaintenanceOwner], "owners")
+
+	require.NoError(t, r.releaseRegistry(backup, backupOwner, logger))
+	reg = getRegistry(t, r.client, registry.Name)
+	require.True(t, reg.Spec.ReadOnly, "registry should stay read-only while the restore is pending")
+	require.True(t, acquire(restoreOwner), "restore should start once the backup released the registry")
+
+	require.NoError(t, r.releaseRegistry(restore, restoreOwner, logger))
+	reg = getRegistry(t, r.client, registry.Name)
+	require.False(t, reg.Spec.ReadOnly, "registry should be writeable after the last owner released it")
+	require.Empty(t, reg.Spec.MaintenanceMessage, "maintenanceMessage")
+	require.Empty(t, reg.Annotations[annotationMaintenanceOwner], "owners")
+	require.Empty(t, reg.Annotations[annotationMaintenanceManaged], "managed annotation")
+}
+
+func TestReleaseKeepsUserEnabledReadOnly(t *testing.T) {
+	registry := &registryapi.ImageRegistry{}
+	registry.Name = "registry"
+	registry.Namespace = "myns"
+	registry.Spec.ReadOnly = true
+	registry.Spec.MaintenanceMessage = "migration"
+	r := newTestJobReconciler(t, registry)
+	backup := testMaintenanceJob("RegistryBackup", "backup", registry.Name)
+	owner := "RegistryBackup/backup"
+	_, err := r.acquireReadOnly(getRegistry(t, r.client, registry.Name), owner, "backup in progress", logf.Log)
+	require.NoError(t, err)
+	require.NoError(t, r.releaseRegistry(backup, owner, logf.Log))
+	reg := getRegistry(t, r.client, registry.Name)
+	require.True(t, reg.Spec.ReadOnly, "readOnly")
+	require.Equal(t, "migration", reg.Spec.MaintenanceMessage, "maintenanceMessage")
+}
+
+func TestRestoreCreatesRegistry(t *testing.T) {
+	restore := &registryapi.RegistryRestore{}
+	restore.Name = "restore"
+	restore.Namespace = "myns"
+	restore.Spec.RegistryRef.Name = "registry"
+	restore.Spec.Registry = &registryapi.ImageRegistrySpec{Hostnames: []string{"registry.example.org"}}
+	restore.Spec.Source = &registryapi.RestoreSourceSpec{Archive: "registry.tar.gz"}
+	restore.Spec.Source.Storage.PersistentVolumeClaim = &registryapi.BackupPVCSpec{ClaimName: "backups"}
+	r := &ReconcileRegistryRestore{newTestJobReconciler(t, restore)}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}}
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(req)
+		require.NoError(t, err)
+	}
+	reg := getRegistry(t, r.client, "registry")
+	require.Equal(t, []string{"registry.example.org"}, reg.Spec.Hostnames, "hostnames")
+	require.True(t, reg.Spec.ReadOnly, "readOnly")
+	require.Equal(t, "RegistryRestore/restore", reg.Annotations[annotationMaintenanceOwner], "owners")
+	require.NoError(t, r.client.Get(context.TODO(), req.NamespacedName, restore))
+	require.Equal(t, registryapi.BackupPhasePending, restore.Status.Phase, "phase")
+}
+
+func newTestJobReconciler(t *testing.T, objs ...runtime.Object) jobReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	return jobReconciler{client: fake.NewFakeClientWithScheme(scheme, objs...), scheme: scheme}
+}
+
+func testMaintenanceJob(kind, name, registry string) *maintenanceJob {
+	cr := &registryapi.RegistryBackup{}
+	cr.Name = name
+	cr.Namespace = "myns"
+	return &maintenanceJob{cr: cr, kind: kind, registry: registry}
+}
+
+func getRegistry(t *testing.T, c client.Client, name string) *registryapi.ImageRegistry {
+	reg := &registryapi.ImageRegistry{}
+	err := c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, reg)
+	require.NoError(t, err)
+	return reg
+}
@@ -0,0 +1,124 @@
+package registrybackup
+
+import (
+	"context"
+	"fmt"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_registrybackup")
+
+// Add creates a new RegistryBackup Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	r := &ReconcileRegistryBackup{newJobReconciler(mgr.GetClient(), mgr.GetScheme())}
+
+	// Create a new controller
+	c, err := controller.New("registrybackup-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource RegistryBackup
+	err = c.Watch(&source.Kind{Type: &registryapi.RegistryBackup{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource Job and requeue the owner RegistryBackup
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryapi.RegistryBackup{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for ImageRegistry changes to continue once the registry is read-only
+	return c.Watch(&source.Kind{Type: &registryapi.ImageRegistry{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: registryToRequests(func(ns string) ([]registryRef, error) {
+			l := &registryapi.RegistryBackupList{}
+			err := mgr.GetClient().List(context.TODO(), l, client.InNamespace(ns))
+			refs := make([]registryRef, len(l.Items))
+			for i, b := range l.Items {
+				refs[i] = registryRef{b.Name, b.Spec.RegistryRef.Name, b.Status.Phase}
+			}
+			return refs, err
+		}),
+	})
+}
+
+// blank assignment to verify that ReconcileRegistryBackup implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileRegistryBackup{}
+
+// ReconcileRegistryBackup reconciles a RegistryBackup object
+type ReconcileRegistryBackup struct {
+	jobReconciler
+}
+
+// Reconcile reads that state of the cluster for a RegistryBackup object and makes changes based on the state read
+// and what is in the RegistryBackup.Spec
+func (r *ReconcileRegistryBackup) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling RegistryBackup")
+
+	// Fetch the RegistryBackup instance
+	instance := &registryapi.RegistryBackup{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	return r.reconcileJob(&maintenanceJob{
+		cr:         instance,
+		kind:       "RegistryBackup",
+		registry:   instance.Spec.RegistryRef.Name,
+		phase:      &instance.Status.Phase,
+		conditions: &instance.Status.Conditions,
+		archive:    &instance.Status.BackupArchiveStatus,
+		jobSpec: func(registry *registryapi.ImageRegistry) (*jobSpec, error) {
+			spec := newJobSpec(modeBackup, instance.Name, registry)
+			spec.Image = instance.Spec.Image
+			spec.Storage = &instance.Spec.Target
+			spec.Archive = fmt.Sprintf("%s-%s.tar.gz", registry.Name, instance.CreationTimestamp.UTC().Format("20060102-150405"))
+			return spec, nil
+		},
+	}, reqLogger)
+}
+
+type registryRef struct {
+	Name     string
+	Registry string
+	Phase    registryapi.BackupPhase
+}
+
+// registryToRequests maps an ImageRegistry to the unfinished backups/restores referring to it
+func registryToRequests(list func(ns string) ([]registryRef, error)) handler.ToRequestsFunc {
+	return func(o handler.MapObject) (requests []reconcile.Request) {
+		refs, err := list(o.Meta.GetNamespace())
+		if err != nil {
+			log.Error(err, "failed to map ImageRegistry to requests")
+			return nil
+		}
+		for _, ref := range refs {
+			if ref.Registry == o.Meta.GetName() && ref.Phase != registryapi.BackupPhaseSucceeded && ref.Phase != registryapi.BackupPhaseFailed {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ref.Name, Namespace: o.Meta.GetNamespace()}})
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,138 @@
+package registrybackup
+
+import (
+	"context"
+	"fmt"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// AddRestore creates a new RegistryRestore Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func AddRestore(mgr manager.Manager) error {
+	r := &ReconcileRegistryRestore{newJobReconciler(mgr.GetClient(), mgr.GetScheme())}
+
+	// Create a new controller
+	c, err := controller.New("registryrestore-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource RegistryRestore
+	err = c.Watch(&source.Kind{Type: &registryapi.RegistryRestore{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource Job and requeue the owner RegistryRestore
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &registryapi.RegistryRestore{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for ImageRegistry changes to continue once the registry is read-only
+	return c.Watch(&source.Kind{Type: &registryapi.ImageRegistry{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: registryToRequests(func(ns string) ([]registryRef, error) {
+			l := &registryapi.RegistryRestoreList{}
+			err := mgr.GetClient().List(context.TODO(), l, client.InNamespace(ns))
+			refs := make([]registryRef, len(l.Items))
+			for i, b := range l.Items {
+				refs[i] = registryRef{b.Name, b.Spec.RegistryRef.Name, b.Status.Phase}
+			}
+			return refs, err
+		}),
+	})
+}
+
+// blank assignment to verify that ReconcileRegistryRestore implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileRegistryRestore{}
+
+// ReconcileRegistryRestore reconciles a RegistryRestore object
+type ReconcileRegistryRestore struct {
+	jobReconciler
+}
+
+// Reconcile reads that state of the cluster for a RegistryRestore object and makes changes based on the state read
+// and what is in the RegistryRestore.Spec
+func (r *ReconcileRegistryRestore) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling RegistryRestore")
+
+	// Fetch the RegistryRestore instance
+	instance := &registryapi.RegistryRestore{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Resolve the archive before the registry is switched into read-only mode
+	source := instance.Spec.Source
+	pending := instance.Status.JobName == "" && instance.Status.Phase != registryapi.BackupPhaseFailed
+	if instance.Spec.BackupRef != nil && pending && instance.GetDeletionTimestamp().IsZero() {
+		source, err = r.sourceFromBackup(instance)
+		if err != nil {
+			t := r.maintenanceJobForCR(instance, nil)
+			if e := r.updateStatus(t, registryapi.BackupPhasePending, corev1.ConditionFalse, registryapi.ReasonPending, err.Error()); e != nil {
+				return reconcile.Result{}, e
+			}
+			return reconcile.Result{RequeueAfter: requeueDelayUnavailable}, nil
+		}
+	}
+
+	return r.reconcileJob(r.maintenanceJobForCR(instance, source), reqLogger)
+}
+
+func (r *ReconcileRegistryRestore) maintenanceJobForCR(instance *registryapi.RegistryRestore, source *registryapi.RestoreSourceSpec) *maintenanceJob {
+	return &maintenanceJob{
+		cr:          instance,
+		kind:        kindRestore,
+		registry:    instance.Spec.RegistryRef.Name,
+		phase:       &instance.Status.Phase,
+		conditions:  &instance.Status.Conditions,
+		archive:     &instance.Status.BackupArchiveStatus,
+		newRegistry: instance.Spec.Registry,
+		jobSpec: func(registry *registryapi.ImageRegistry) (*jobSpec, error) {
+			if source == nil {
+				return nil, fmt.Errorf("neither backupRef nor source specified")
+			}
+			spec := newJobSpec(modeRestore, instance.Name, registry)
+			spec.Image = instance.Spec.Image
+			spec.Storage = &source.Storage
+			spec.Archive = source.Archive
+			spec.Checksum = source.Checksum
+			return spec, nil
+		},
+	}
+}
+
+func (r *ReconcileRegistryRestore) sourceFromBackup(instance *registryapi.RegistryRestore) (*registryapi.RestoreSourceSpec, error) {
+	backup := &registryapi.RegistryBackup{}
+	key := types.NamespacedName{Name: instance.Spec.BackupRef.Name, Namespace: instance.Namespace}
+	if err := r.client.Get(context.TODO(), key, backup); err != nil {
+		return nil, fmt.Errorf("backupRef: %w", err)
+	}
+	if backup.Status.Phase != registryapi.BackupPhaseSucceeded {
+		return nil, fmt.Errorf("RegistryBackup %s has not succeeded", backup.Name)
+	}
+	return &registryapi.RestoreSourceSpec{
+		Storage:  backup.Spec.Target,
+		Archive:  backup.Status.Archive,
+		Checksum: backup.Status.Checksum,
+	}, nil
+}
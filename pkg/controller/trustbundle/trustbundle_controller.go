@@ -0,0 +1,262 @@
+package trustbundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imageregistry"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var log = logf.Log.WithName("controller_trustbundle")
+
+const (
+	// EnvNamespaceSelector is a label selector that specifies the namespaces the CA bundle ConfigMap is published into
+	EnvNamespaceSelector = "OPERATOR_TRUST_BUNDLE_NAMESPACE_SELECTOR"
+	// EnvNodeDaemonSet enables the DaemonSet that configures the CA bundle for the registries on every node
+	EnvNodeDaemonSet  = "OPERATOR_TRUST_BUNDLE_DAEMONSET"
+	EnvImageNodeTrust = "OPERATOR_IMAGE_NODE_TRUST"
+	// ConfigMapName is the name of the ConfigMap that contains the CA bundle
+	ConfigMapName     = "image-registry-ca-bundle"
+	configMapKeyCACrt = "ca.crt"
+	configMapKeyHosts = "hosts"
+	labelManagedBy    = "app.kubernetes.io/managed-by"
+	operatorName      = "image-registry-operator"
+	resyncInterval    = 10 * time.Minute
+)
+
+var singletonRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "trust-bundle"}}
+
+// Add creates a new trust bundle Controller and adds it to the Manager if it is enabled.
+// The controller publishes the root CA and the registries' CA certificates into the selected namespaces
+// and optionally onto the nodes.
+func Add(mgr manager.Manager) error {
+	r, err := newReconciler(mgr)
+	if err != nil || r == nil {
+		return err
+	}
+
+	// Create a new controller
+	c, err := controller.New("trustbundle-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch all resources that contribute to the bundle and requeue the singleton request
+	toRequest := &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+		return []reconcile.Request{singletonRequest}
+	})}
+	err = c.Watch(&source.Kind{Type: &registryapi.ImageRegistry{}}, toRequest)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+		if secret, ok := o.Object.(*corev1.Secret); ok && secret.Type == corev1.SecretTypeTLS {
+			return []reconcile.Request{singletonRequest}
+		}
+		return nil
+	})})
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+		if o.Meta.GetLabels()[labelManagedBy] == operatorName {
+			return []reconcile.Request{singletonRequest}
+		}
+		return nil
+	})})
+	if err != nil {
+		return err
+	}
+	if r.namespaceSelector != nil {
+		err = c.Watch(&source.Kind{Type: &corev1.Namespace{}}, toRequest)
+		if err != nil {
+			return err
+		}
+	}
+	if r.nodeDaemonSet {
+		err = c.Watch(&source.Kind{Type: &appsv1.DaemonSet{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			if o.Meta.GetName() == nodeTrustName && o.Meta.GetNamespace() == r.namespace {
+				return []reconcile.Request{singletonRequest}
+			}
+			return nil
+		})})
+	}
+	return err
+}
+
+// blank assignment to verify that ReconcileTrustBundle implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileTrustBundle{}
+
+// ReconcileTrustBundle distributes the CA bundle
+type ReconcileTrustBundle struct {
+	client            client.Client
+	rootCASecretName  types.NamespacedName
+	namespace         string
+	dnsZone           string
+	namespaceSelector labels.Selector
+	nodeDaemonSet     bool
+	imageNodeTrust    string
+}
+
+// newReconciler returns a new reconciler or nil if the trust bundle distribution is disabled
+func newReconciler(mgr manager.Manager) (*ReconcileTrustBundle, error) {
+	r := &ReconcileTrustBundle{
+		client:           mgr.GetClient(),
+		rootCASecretName: certs.RootCASecretName(),
+		dnsZone:          imageregistry.DNSZone(),
+		imageNodeTrust:   os.Getenv(EnvImageNodeTrust),
+	}
+	r.namespace = r.rootCASecretName.Namespace
+	if r.imageNodeTrust == "" {
+		r.imageNodeTrust = "busybox:1.31"
+	}
+	if selector := os.Getenv(EnvNamespaceSelector); selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in env var %s: %w", EnvNamespaceSelector, err)
+		}
+		r.namespaceSelector = s
+	}
+	if daemonSet := os.Getenv(EnvNodeDaemonSet); daemonSet != "" {
+		enabled, err := strconv.ParseBool(daemonSet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in env var %s: %w", EnvNodeDaemonSet, err)
+		}
+		r.nodeDaemonSet = enabled
+	}
+	if r.namespaceSelector == nil && !r.nodeDaemonSet {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// Reconcile collects the root CA and the registries' CA certificates
+// and publishes them as ConfigMap into the selected namespaces and onto the nodes.
+func (r *ReconcileTrustBundle) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Name", request.Name)
+	bundle, hosts, err := r.collect()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if r.namespaceSelector != nil {
+		if err = r.reconcileConfigMaps(bundle, reqLogger); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	if r.nodeDaemonSet {
+		if err = r.reconcileNodeTrust(bundle, hosts, reqLogger); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{RequeueAfter: resyncInterval}, nil
+}
+
+// collect returns the CA bundle and the hostnames of all registries
+func (r *ReconcileTrustBundle) collect() (bundle []byte, hosts []string, err error) {
+	var caCerts [][]byte
+	rootCA := &corev1.Secret{}
+	if err = r.client.Get(context.TODO(), r.rootCASecretName, rootCA); err != nil && !errors.IsNotFound(err) {
+		return
+	}
+	caCerts = append(caCerts, rootCA.Data[configMapKeyCACrt])
+	registries := &registryapi.ImageRegistryList{}
+	if err = r.client.List(context.TODO(), registries); err != nil {
+		return
+	}
+	hostSet := map[string]bool{}
+	for _, registry := range registries.Items {
+		for _, host := range imageregistry.RegistryHostnames(&registry, r.dnsZone) {
+			hostSet[host] = true
+		}
+		if registry.Status.TLSSecretName == "" {
+			continue
+		}
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Name: registry.Status.TLSSecretName, Namespace: registry.Namespace}
+		if err = r.client.Get(context.TODO(), key, secret); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return
+		}
+		caCerts = append(caCerts, secret.Data[configMapKeyCACrt])
+	}
+	for host := range hostSet {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return certs.MergeCertificates(caCerts), hosts, nil
+}
+
+func (r *ReconcileTrustBundle) reconcileConfigMaps(bundle []byte, reqLogger logr.Logger) error {
+	namespaces := &corev1.NamespaceList{}
+	err := r.client.List(context.TODO(), namespaces, client.MatchingLabelsSelector{Selector: r.namespaceSelector})
+	if err != nil {
+		return err
+	}
+	selected := map[string]bool{}
+	for _, ns := range namespaces.Items {
+		selected[ns.Name] = true
+		cm := &corev1.ConfigMap{}
+		cm.Name = ConfigMapName
+		cm.Namespace = ns.Name
+		result, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, cm, func() error {
+			if cm.Labels == nil {
+				cm.Labels = map[string]string{}
+			}
+			cm.Labels[labelManagedBy] = operatorName
+			cm.Data = map[string]string{configMapKeyCACrt: string(bundle)}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("upsert ConfigMap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+		if result != controllerutil.OperationResultNone {
+			reqLogger.Info("Published CA bundle", "ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name, "Operation", result)
+		}
+	}
+
+	// Delete ConfigMaps from namespaces that are not selected anymore
+	list := &corev1.ConfigMapList{}
+	if err = r.client.List(context.TODO(), list, client.MatchingLabels{labelManagedBy: operatorName}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if cm.Name == ConfigMapName && !selected[cm.Namespace] {
+			reqLogger.Info("Deleting CA bundle", "ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name)
+			if err = r.client.Delete(context.TODO(), cm); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hostsFileContent(hosts []string) string {
+	if len(hosts) == 0 {
+		return ""
+	}
+	return strings.Join(hosts, "\n") + "\n"
+}
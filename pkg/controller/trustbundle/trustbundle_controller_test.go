@@ -0,0 +1,194 @@
+package trustbundle
+
+import (
+	"context"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/certs"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestReconciler(t *testing.T, objs ...runtime.Object) *ReconcileTrustBundle {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	require.NoError(t, registryapi.SchemeBuilder.AddToScheme(scheme))
+	return &ReconcileTrustBundle{
+		client:           fake.NewFakeClientWithScheme(scheme, objs...),
+		rootCASecretName: types.NamespacedName{Name: "image-registry-root-ca", Namespace: "operatorns"},
+		namespace:        "operatorns",
+		dnsZone:          "svc.cluster.local",
+		imageNodeTrust:   "busybox:1.31",
+	}
+}
+
+func newCACertPEM(t *testing.T, name string) []byte {
+	ca, err := certs.NewSelfSignedCAKeyPair(name, certs.KeyParams{Algorithm: certs.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	return ca.CertPEM()
+}
+
+func newCASecret(name, namespace string, caCert []byte) *corev1.Secret {
+	secret := &corev1.Secret{}
+	secret.Name = name
+	secret.Namespace = namespace
+	secret.Type = corev1.SecretTypeTLS
+	secret.Data = map[string][]byte{configMapKeyCACrt: caCert}
+	return secret
+}
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	ns := &corev1.Namespace{}
+	ns.Name = name
+	ns.Labels = labels
+	return ns
+}
+
+func TestCollect(t *testing.T) {
+	rootCA := newCACertPEM(t, "root")
+	registryCA := newCACertPEM(t, "registry")
+	registry := &registryapi.ImageRegistry{}
+	registry.Name = "registry"
+	registry.Namespace = "myns"
+	registry.Status.TLSSecretName = "registry-tls"
+	aliased := &registryapi.ImageRegistry{}
+	aliased.Name = "aliased"
+	aliased.Namespace = "myns"
+	aliased.Spec.Hostnames = []string{"registry.example.org", "alias.example.org"}
+	aliased.Status.TLSSecretName = "aliased-tls"
+	missingSecret := &registryapi.ImageRegistry{}
+	missingSecret.Name = "missing"
+	missingSecret.Namespace = "otherns"
+	missingSecret.Spec.Hostnames = []string{"missing.example.org"}
+	missingSecret.Status.TLSSecretName = "missing-tls"
+	r := newTestReconciler(t, registry, aliased, missingSecret,
+		newCASecret("image-registry-root-ca", "operatorns", rootCA),
+		newCASecret("registry-tls", "myns", append(append([]byte{}, registryCA...), rootCA...)),
+		newCASecret("aliased-tls", "myns", rootCA),
+	)
+
+	bundle, hosts, err := r.collect()
+	require.NoError(t, err)
+	require.Equal(t, string(append(append([]byte{}, rootCA...), registryCA...)), string(bundle), "bundle")
+	require.Equal(t, []string{
+		"alias.example.org",
+		"missing.example.org",
+		"registry.example.org",
+		"registry.myns.svc.cluster.local",
+	}, hosts, "hosts")
+}
+
+func TestCollectWithoutRootCA(t *testing.T) {
+	bundle, hosts, err := newTestReconciler(t).collect()
+	require.NoError(t, err)
+	require.Empty(t, bundle, "bundle")
+	require.Empty(t, hosts, "hosts")
+}
+
+func TestReconcileConfigMaps(t *testing.T) {
+	selector, err := labels.Parse("trust=true")
+	require.NoError(t, err)
+	stale := &corev1.ConfigMap{}
+	stale.Name = ConfigMapName
+	stale.Namespace = "unselected"
+	stale.Labels = map[string]string{labelManagedBy: operatorName}
+	stale.Data = map[string]string{configMapKeyCACrt: "outdated"}
+	unmanaged := &corev1.ConfigMap{}
+	unmanaged.Name = ConfigMapName
+	unmanaged.Namespace = "unmanaged"
+	outdated := stale.DeepCopy()
+	outdated.Namespace = "selected-b"
+	r := newTestReconciler(t, stale, unmanaged, outdated,
+		newNamespace("selected-a", map[string]string{"trust": "true"}),
+		newNamespace("selected-b", map[string]string{"trust": "true"}),
+		newNamespace("unselected", nil),
+		newNamespace("unmanaged", nil),
+	)
+	r.namespaceSelector = selector
+	bundle := newCACertPEM(t, "root")
+
+	require.NoError(t, r.reconcileConfigMaps(bundle, logf.Log))
+	for _, ns := range []string{"selected-a", "selected-b"} {
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, r.client.Get(context.TODO(), types.NamespacedName{Name: ConfigMapName, Namespace: ns}, cm), "get ConfigMap in %s", ns)
+		require.Equal(t, map[string]string{configMapKeyCACrt: string(bundle)}, cm.Data, "ConfigMap data in %s", ns)
+		require.Equal(t, operatorName, cm.Labels[labelManagedBy], "managed-by label in %s", ns)
+	}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: ConfigMapName, Namespace: "unselected"}, &corev1.ConfigMap{})
+	require.True(t, errors.IsNotFound(err), "ConfigMap should be deleted from unselected namespace but got %v", err)
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: ConfigMapName, Namespace: "unmanaged"}, &corev1.ConfigMap{})
+	require.NoError(t, err, "ConfigMap that is not managed by the operator should be kept")
+
+	// Unselect a namespace
+	ns := &corev1.Namespace{}
+	require.NoError(t, r.client.Get(context.TODO(), types.NamespacedName{Name: "selected-a"}, ns))
+	ns.Labels = nil
+	require.NoError(t, r.client.Update(context.TODO(), ns))
+	require.NoError(t, r.reconcileConfigMaps(bundle, logf.Log))
+	list := &corev1.ConfigMapList{}
+	require.NoError(t, r.client.List(context.TODO(), list, client.MatchingLabels{labelManagedBy: operatorName}))
+	published := []string{}
+	for _, cm := range list.Items {
+		published = append(published, cm.Namespace)
+	}
+	require.Equal(t, []string{"selected-b"}, published, "namespaces containing the CA bundle")
+}
+
+func TestReconcileNodeTrust(t *testing.T) {
+	r := newTestReconciler(t)
+	bundle := newCACertPEM(t, "root")
+	hosts := []string{"alias.example.org", "registry.example.org"}
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, r.reconcileNodeTrust(bundle, hosts, logf.Log), "reconcile #%d", i+1)
+	}
+	key := types.NamespacedName{Name: nodeTrustName, Namespace: "operatorns"}
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, r.client.Get(context.TODO(), key, cm))
+	require.Equal(t, map[string]string{
+		configMapKeyCACrt: string(bundle),
+		configMapKeyHosts: "alias.example.org\nregistry.example.org\n",
+	}, cm.Data, "ConfigMap data")
+	require.Equal(t, nodeTrustLabels(), cm.Labels, "ConfigMap labels")
+
+	ds := &appsv1.DaemonSet{}
+	require.NoError(t, r.client.Get(context.TODO(), key, ds))
+	require.Equal(t, nodeTrustLabels(), ds.Spec.Selector.MatchLabels, "selector")
+	require.Equal(t, nodeTrustLabels(), ds.Spec.Template.Labels, "pod labels")
+	spec := ds.Spec.Template.Spec
+	require.Equal(t, []corev1.Toleration{{Operator: corev1.TolerationOpExists}}, spec.Tolerations, "tolerations")
+	volumes := map[string]corev1.VolumeSource{}
+	for _, v := range spec.Volumes {
+		volumes[v.Name] = v.VolumeSource
+	}
+	require.NotNil(t, volumes["trust"].ConfigMap, "trust volume ConfigMap")
+	require.Equal(t, nodeTrustName, volumes["trust"].ConfigMap.Name, "trust volume ConfigMap")
+	require.NotNil(t, volumes["containerd-certs"].HostPath, "containerd-certs volume hostPath")
+	require.Equal(t, "/etc/containerd/certs.d", volumes["containerd-certs"].HostPath.Path, "containerd-certs volume hostPath")
+	require.NotNil(t, volumes["docker-certs"].HostPath, "docker-certs volume hostPath")
+	require.Equal(t, "/etc/docker/certs.d", volumes["docker-certs"].HostPath.Path, "docker-certs volume hostPath")
+	require.Len(t, spec.Containers, 1, "containers")
+	container := spec.Containers[0]
+	require.Equal(t, "busybox:1.31", container.Image, "image")
+	require.Equal(t, []string{"/bin/sh", "-c", nodeTrustScript}, container.Command, "command")
+	require.Equal(t, []corev1.VolumeMount{
+		{Name: "trust", MountPath: "/trust", ReadOnly: true},
+		{Name: "containerd-certs", MountPath: "/host/containerd"},
+		{Name: "docker-certs", MountPath: "/host/docker"},
+	}, container.VolumeMounts, "volume mounts")
+
+	// Update the hosts
+	require.NoError(t, r.reconcileNodeTrust(bundle, nil, logf.Log))
+	require.NoError(t, r.client.Get(context.TODO(), key, cm))
+	require.Equal(t, "", cm.Data[configMapKeyHosts], "hosts after all registries have been removed")
+}
@@ -0,0 +1,137 @@
+package trustbundle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	nodeTrustName = "image-registry-node-trust"
+	// nodeTrustScript periodically writes the CA bundle into the containerd and docker certs.d directories
+	// for every registry hostname and removes the directories of hosts it has configured previously
+	// that are not listed anymore. Directories that have not been created by the script are left untouched.
+	nodeTrustScript = `
+marker=.image-registry-operator
+sync() {
+	hosts="$(cat /trust/hosts)"
+	for d in /host/containerd/*/ /host/docker/*/; do
+		[ -f "$d/$marker" ] || continue
+		echo "$hosts" | grep -qxF "$(basename "$d")" || rm -rf "$d"
+	done
+	for h in $hosts; do
+		for d in /host/containerd/$h /host/docker/$h; do
+			[ ! -d "$d" ] || [ -f "$d/$marker" ] || continue
+			mkdir -p "$d" && touch "$d/$marker"
+			cmp -s /trust/ca.crt "$d/ca.crt" || cp /trust/ca.crt "$d/ca.crt"
+		done
+		[ ! -f "/host/containerd/$h/$marker" ] ||
+			printf 'server = "https://%s"\n\n[host."https://%s"]\n  ca = "/etc/containerd/certs.d/%s/ca.crt"\n' \
+				"$h" "$h" "$h" > "/host/containerd/$h/hosts.toml"
+	done
+}
+while true; do
+	sync
+	sleep 60
+done
+`
+)
+
+// reconcileNodeTrust maintains the DaemonSet that configures the CA bundle for all registry hostnames on the nodes
+func (r *ReconcileTrustBundle) reconcileNodeTrust(bundle []byte, hosts []string, reqLogger logr.Logger) error {
+	cm := &corev1.ConfigMap{}
+	cm.Name = nodeTrustName
+	cm.Namespace = r.namespace
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, cm, func() error {
+		cm.Labels = nodeTrustLabels()
+		cm.Data = map[string]string{
+			configMapKeyCACrt: string(bundle),
+			configMapKeyHosts: hostsFileContent(hosts),
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upsert ConfigMap %s/%s: %w", cm.Namespace, cm.Name, err)
+	}
+
+	ds := &appsv1.DaemonSet{}
+	ds.Name = nodeTrustName
+	ds.Namespace = r.namespace
+	result, err := controllerutil.CreateOrUpdate(context.TODO(), r.client, ds, func() error {
+		ds.Labels = nodeTrustLabels()
+		ds.Spec.Selector = &metav1.LabelSelector{MatchLabels: nodeTrustLabels()}
+		ds.Spec.Template.Labels = nodeTrustLabels()
+		r.nodeTrustPodSpec(&ds.Spec.Template.Spec)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upsert DaemonSet %s/%s: %w", ds.Namespace, ds.Name, err)
+	}
+	if result != controllerutil.OperationResultNone {
+		reqLogger.Info("Reconciled node trust DaemonSet", "DaemonSet.Namespace", ds.Namespace, "DaemonSet.Name", ds.Name, "Operation", result)
+	}
+	return nil
+}
+
+func (r *ReconcileTrustBundle) nodeTrustPodSpec(spec *corev1.PodSpec) {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	spec.Tolerations = []corev1.Toleration{{Operator: corev1.TolerationOpExists}}
+	spec.Volumes = []corev1.Volume{
+		{
+			Name: "trust",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: nodeTrustName},
+			}},
+		},
+		{
+			Name: "containerd-certs",
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+				Path: "/etc/containerd/certs.d",
+				Type: &hostPathType,
+			}},
+		},
+		{
+			Name: "docker-certs",
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+				Path: "/etc/docker/certs.d",
+				Type: &hostPathType,
+			}},
+		},
+	}
+	spec.Containers = []corev1.Container{
+		{
+			Name:            "node-trust",
+			Image:           r.imageNodeTrust,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"/bin/sh", "-c", nodeTrustScript},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "trust", MountPath: "/trust", ReadOnly: true},
+				{Name: "containerd-certs", MountPath: "/host/containerd"},
+				{Name: "docker-certs", MountPath: "/host/docker"},
+			},
+			Resources: corev1.ResourceRequirements{
+				Limits: map[corev1.ResourceName]resource.Quantity{
+					corev1.ResourceCPU:    resource.MustParse("50m"),
+					corev1.ResourceMemory: resource.MustParse("16Mi"),
+				},
+				Requests: map[corev1.ResourceName]resource.Quantity{
+					corev1.ResourceCPU:    resource.MustParse("10m"),
+					corev1.ResourceMemory: resource.MustParse("16Mi"),
+				},
+			},
+		},
+	}
+}
+
+func nodeTrustLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name": nodeTrustName,
+		labelManagedBy:           operatorName,
+	}
+}
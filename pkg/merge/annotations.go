@@ -0,0 +1,35 @@
+package merge
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations sets the given annotations on the object and removes the ones
+// that have been set previously but are not specified anymore.
+// The applied keys are recorded within the tracking annotation.
+func Annotations(o metav1.Object, annotations map[string]string, trackingAnnotation string) {
+	a := o.GetAnnotations()
+	if a == nil {
+		a = map[string]string{}
+	}
+	for _, k := range strings.Split(a[trackingAnnotation], ",") {
+		if _, ok := annotations[k]; !ok && k != "" {
+			delete(a, k)
+		}
+	}
+	keys := make([]string, 0, len(annotations))
+	for k, v := range annotations {
+		a[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		a[trackingAnnotation] = strings.Join(keys, ",")
+	} else {
+		delete(a, trackingAnnotation)
+	}
+	o.SetAnnotations(a)
+}
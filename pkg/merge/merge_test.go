@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestAddContainer(t *testing.T) {
@@ -18,3 +19,53 @@ func TestAddContainer(t *testing.T) {
 	require.Equal(t, 1, len(pod.Containers), "len(pod.Containers)")
 	require.Equal(t, image2, pod.Containers[0].Image)
 }
+
+func TestPodTemplate(t *testing.T) {
+	template := &corev1.PodTemplateSpec{}
+	template.Labels = map[string]string{"app": "myapp"}
+	template.Spec.Containers = []corev1.Container{
+		{
+			Name:  "app",
+			Image: "myappimg:latest",
+			Env:   []corev1.EnvVar{{Name: "A", Value: "a"}},
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+		},
+		{Name: "sidecar", Image: "sidecarimg:latest"},
+	}
+	override := &corev1.PodTemplateSpec{}
+	override.Labels = map[string]string{"team": "a"}
+	override.Spec.NodeSelector = map[string]string{"disk": "ssd"}
+	override.Spec.Containers = []corev1.Container{
+		{
+			Name: "app",
+			Env:  []corev1.EnvVar{{Name: "B", Value: "b"}},
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	err := PodTemplate(template, override)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "myapp", "team": "a"}, template.Labels, "labels")
+	require.Equal(t, map[string]string{"disk": "ssd"}, template.Spec.NodeSelector, "nodeSelector")
+	require.Equal(t, 2, len(template.Spec.Containers), "len(containers)")
+	c := template.Spec.Containers[0]
+	require.Equal(t, "myappimg:latest", c.Image, "image")
+	require.Equal(t, []corev1.EnvVar{{Name: "B", Value: "b"}, {Name: "A", Value: "a"}}, c.Env, "env")
+	mem := c.Resources.Limits[corev1.ResourceMemory]
+	require.Equal(t, "1Gi", mem.String(), "memory limit")
+	require.Equal(t, "sidecarimg:latest", template.Spec.Containers[1].Image, "sidecar image")
+}
+
+func TestAnnotations(t *testing.T) {
+	svc := &corev1.Service{}
+	svc.Annotations = map[string]string{"other": "value"}
+	Annotations(svc, map[string]string{"a": "1", "b": "2"}, "applied")
+	require.Equal(t, map[string]string{"other": "value", "a": "1", "b": "2", "applied": "a,b"}, svc.Annotations, "added")
+	Annotations(svc, map[string]string{"b": "3"}, "applied")
+	require.Equal(t, map[string]string{"other": "value", "b": "3", "applied": "b"}, svc.Annotations, "removed")
+	Annotations(svc, nil, "applied")
+	require.Equal(t, map[string]string{"other": "value"}, svc.Annotations, "removed all")
+}
@@ -0,0 +1,31 @@
+package merge
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// PodTemplate merges the override into the given template
+// using strategic merge patch semantics (containers are merged by name).
+func PodTemplate(template *corev1.PodTemplateSpec, override *corev1.PodTemplateSpec) error {
+	original, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return err
+	}
+	result := corev1.PodTemplateSpec{}
+	if err = json.Unmarshal(merged, &result); err != nil {
+		return err
+	}
+	*template = result
+	return nil
+}
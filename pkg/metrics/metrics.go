@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "image_registry_operator"
+
+var (
+	// SecretRotations counts the password rotations per secret type (pull/push)
+	SecretRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "secret_rotations_total",
+		Help:      "Number of ImagePullSecret/ImagePushSecret password rotations",
+	}, []string{"type"})
+	// SecretRotationFailures counts the failed password rotations per secret type (pull/push)
+	SecretRotationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "secret_rotation_failures_total",
+		Help:      "Number of failed ImagePullSecret/ImagePushSecret password rotations",
+	}, []string{"type"})
+	// AccountsCreated counts the created ImageRegistryAccounts per access mode (pull/push)
+	AccountsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accounts_created_total",
+		Help:      "Number of created ImageRegistryAccounts",
+	}, []string{"type"})
+	// AccountsExpired counts the deleted expired ImageRegistryAccounts per access mode (pull/push)
+	AccountsExpired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accounts_expired_total",
+		Help:      "Number of expired ImageRegistryAccounts that have been deleted",
+	}, []string{"type"})
+	// SecretNextRotation exposes the time until an image secret's password is rotated next
+	SecretNextRotation = NewTimeUntilVec(prometheus.BuildFQName(namespace, "", "secret_next_rotation_seconds"),
+		"Seconds until the next password rotation of an ImagePullSecret/ImagePushSecret",
+		"namespace", "name", "type")
+	// CertificateExpiry exposes the time until an operator-managed certificate expires
+	CertificateExpiry = NewTimeUntilVec(prometheus.BuildFQName(namespace, "", "certificate_expiry_seconds"),
+		"Seconds until an operator-managed certificate (root CA, registry TLS, auth token CA) expires",
+		"namespace", "secret")
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(
+		SecretRotations,
+		SecretRotationFailures,
+		AccountsCreated,
+		AccountsExpired,
+		SecretNextRotation,
+		CertificateExpiry,
+	)
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var timeNow = time.Now
+
+// TimeUntilVec is a gauge collector exposing the seconds until a point in time per label set.
+// In contrast to a plain gauge the value is computed when the metric is collected.
+type TimeUntilVec struct {
+	desc   *prometheus.Desc
+	lock   sync.Mutex
+	values map[string]timeUntilValue
+}
+
+type timeUntilValue struct {
+	labelValues []string
+	time        time.Time
+}
+
+// NewTimeUntilVec creates a new TimeUntilVec
+func NewTimeUntilVec(name, help string, labelNames ...string) *TimeUntilVec {
+	return &TimeUntilVec{
+		desc:   prometheus.NewDesc(name, help, labelNames, nil),
+		values: map[string]timeUntilValue{},
+	}
+}
+
+// Set sets the point in time for the given label values
+func (v *TimeUntilVec) Set(t time.Time, labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.values[labelKey(labelValues)] = timeUntilValue{labelValues, t}
+}
+
+// Delete removes the metric for the given label values
+func (v *TimeUntilVec) Delete(labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	delete(v.values, labelKey(labelValues))
+}
+
+// Describe implements prometheus.Collector
+func (v *TimeUntilVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+// Collect implements prometheus.Collector
+func (v *TimeUntilVec) Collect(ch chan<- prometheus.Metric) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	now := timeNow()
+	for _, value := range v.values {
+		ch <- prometheus.MustNewConstMetric(v.desc, prometheus.GaugeValue, value.time.Sub(now).Seconds(), value.labelValues...)
+	}
+}
+
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\x00")
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeUntilVec(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+	v := NewTimeUntilVec("test_expiry_seconds", "Test help", "name")
+	v.Set(now.Add(time.Hour), "a")
+	v.Set(now.Add(-time.Minute), "b")
+	v.Set(now.Add(2*time.Hour), "c")
+	v.Delete("c")
+	expected := `
+# HELP test_expiry_seconds Test help
+# TYPE test_expiry_seconds gauge
+test_expiry_seconds{name="a"} 3600
+test_expiry_seconds{name="b"} -60
+`
+	err := testutil.CollectAndCompare(v, strings.NewReader(expected))
+	require.NoError(t, err)
+}
@@ -0,0 +1,205 @@
+package notifications
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/mgoltzsche/image-registry-operator/pkg/controller/imagesecret"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// EnvReceiverAddr specifies the address the operator's notification receiver listens on
+	EnvReceiverAddr = "OPERATOR_NOTIFICATIONS_ADDR"
+	ActionPush      = "push"
+	ActionPull      = "pull"
+	ActionDelete    = "delete"
+	ReasonPushed    = "ImagePushed"
+	ReasonPulled    = "ImagePulled"
+	ReasonDeleted   = "ImageDeleted"
+)
+
+// Envelope is the notification request body sent by the registry.
+// See https://docs.docker.com/registry/notifications/
+type Envelope struct {
+	Events []Event `json:"events"`
+}
+
+type Event struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Target    Target    `json:"target"`
+	Actor     Actor     `json:"actor"`
+	Request   Request   `json:"request"`
+}
+
+type Target struct {
+	MediaType  string `json:"mediaType"`
+	Digest     string `json:"digest"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+}
+
+type Actor struct {
+	Name string `json:"name,omitempty"`
+}
+
+type Request struct {
+	Addr string `json:"addr"`
+	Host string `json:"host"`
+}
+
+// Listener is called for every registry event the operator receives
+type Listener func(Event)
+
+// Dispatcher passes received registry events on to the registered Listeners
+type Dispatcher struct {
+	listeners []Listener
+	lock      sync.Mutex
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// AddListener registers a Listener that is called for every received registry event.
+func (d *Dispatcher) AddListener(l Listener) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.listeners = append(d.listeners, l)
+}
+
+// Notify calls all registered Listeners with the given event
+func (d *Dispatcher) Notify(evt Event) {
+	d.lock.Lock()
+	l := d.listeners
+	d.lock.Unlock()
+	for _, listener := range l {
+		listener(evt)
+	}
+}
+
+// Receiver turns registry notifications into Kubernetes Events
+// on the ImagePushSecret/ImagePullSecret whose account performed the action.
+// Requests must provide the shared token as bearer token.
+// Events are passed on to the listener after they have been recorded successfully.
+type Receiver struct {
+	client   client.Client
+	recorder record.EventRecorder
+	listener Listener
+	token    string
+	log      logr.Logger
+}
+
+func NewReceiver(client client.Client, recorder record.EventRecorder, listener Listener, token string, log logr.Logger) *Receiver {
+	return &Receiver{client, recorder, listener, token, log}
+}
+
+func (h *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(req) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	envelope := Envelope{}
+	if err := json.NewDecoder(req.Body).Decode(&envelope); err != nil {
+		http.Error(w, fmt.Sprintf("invalid notification: %s", err), http.StatusBadRequest)
+		return
+	}
+	for _, evt := range envelope.Events {
+		if err := h.recordEvent(req.Context(), &evt); err != nil {
+			h.log.Error(err, "failed to record registry event", "event", evt.ID)
+			// Let the registry retry
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	// Notify listeners only once the registry won't retry the envelope
+	if h.listener != nil {
+		for _, evt := range envelope.Events {
+			h.listener(evt)
+		}
+	}
+}
+
+func (h *Receiver) authorized(req *http.Request) bool {
+	expected := []byte("Bearer " + h.token)
+	actual := []byte(req.Header.Get("Authorization"))
+	return h.token != "" && subtle.ConstantTimeCompare(expected, actual) == 1
+}
+
+func (h *Receiver) recordEvent(ctx context.Context, evt *Event) error {
+	if evt.Actor.Name == "" {
+		return nil // anonymous or unsupported
+	}
+	mode, key, err := imagesecret.ParseAccountName(evt.Actor.Name)
+	if err != nil {
+		h.log.V(1).Info("ignoring registry event of unknown account", "account", evt.Actor.Name)
+		return nil
+	}
+	var cr registryapi.ImageSecretInterface = &registryapi.ImagePullSecret{}
+	if mode == registryapi.TypePush {
+		cr = &registryapi.ImagePushSecret{}
+	}
+	if err = h.client.Get(ctx, key, cr); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	var reason, verb string
+	switch evt.Action {
+	case ActionPush:
+		reason, verb = ReasonPushed, "Pushed"
+	case ActionPull:
+		reason, verb = ReasonPulled, "Pulled"
+	case ActionDelete:
+		reason, verb = ReasonDeleted, "Deleted"
+	default:
+		return nil
+	}
+	image := evt.Target.Repository
+	if evt.Target.Tag != "" {
+		image += ":" + evt.Target.Tag
+	}
+	if evt.Target.Digest != "" {
+		image += "@" + evt.Target.Digest
+	}
+	h.recorder.Eventf(cr, corev1.EventTypeNormal, reason, "%s %s/%s as %s", verb, evt.Request.Host, image, evt.Actor.Name)
+	return nil
+}
+
+// NewServer returns a Runnable that serves the Receiver on the given address
+// until the manager stops.
+func NewServer(addr string, receiver *Receiver) manager.Runnable {
+	return manager.RunnableFunc(func(stop <-chan struct{}) error {
+		mux := http.NewServeMux()
+		mux.Handle("/events", receiver)
+		srv := &http.Server{Addr: addr, Handler: mux}
+		go func() {
+			<-stop
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(ctx)
+		}()
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	registryapi "github.com/mgoltzsche/image-registry-operator/pkg/apis/registry/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReceiver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	err := registryapi.SchemeBuilder.AddToScheme(scheme)
+	require.NoError(t, err, "add scheme")
+	pushSecret := &registryapi.ImagePushSecret{}
+	pushSecret.Name = "my.secret"
+	pushSecret.Namespace = "myns"
+	client := fake.NewFakeClientWithScheme(scheme, pushSecret)
+	recorder := record.NewFakeRecorder(10)
+	var notified []Event
+	listener := func(evt Event) { notified = append(notified, evt) }
+	testee := NewReceiver(client, recorder, listener, "secret-token", logf.Log)
+
+	envelope := Envelope{Events: []Event{
+		{Action: ActionPush, Actor: Actor{Name: "push.myns.my.secret.3"}, Target: Target{Repository: "myrepo", Tag: "latest"}, Request: Request{Host: "registry.example.org"}},
+		{Action: ActionPull, Actor: Actor{Name: "pull.myns.missing.1"}, Target: Target{Repository: "myrepo"}},
+		{Action: ActionPull, Actor: Actor{Name: "unknown-user"}, Target: Target{Repository: "myrepo"}},
+		{Action: ActionPull, Target: Target{Repository: "myrepo"}},
+	}}
+	body, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	for _, auth := range []string{"", "Bearer wrong-token", "secret-token"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+		req.Header.Set("Authorization", auth)
+		testee.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code, "status code for Authorization %q", auth)
+	}
+	require.Equal(t, 0, len(recorder.Events), "recorded events of unauthorized requests")
+	require.Equal(t, 0, len(notified), "notified events of unauthorized requests")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	testee.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "status code")
+	require.Equal(t, 1, len(recorder.Events), "recorded events")
+	require.Equal(t, envelope.Events, notified, "notified events")
+	require.Equal(t, "Normal ImagePushed Pushed registry.example.org/myrepo:latest as push.myns.my.secret.3", <-recorder.Events)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader([]byte("invalid")))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	testee.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code, "status code for invalid body")
+}
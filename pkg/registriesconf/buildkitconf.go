@@ -0,0 +1,25 @@
+package registriesconf
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+)
+
+// BuildKitDir is the directory buildkitd reads its buildkitd.toml from.
+// The Secret containing the BuildKit configuration is expected to be mounted there.
+const BuildKitDir = "/etc/buildkit"
+
+// BuildKitdTOML returns a buildkitd.toml that configures the registries' CA certificates within the BuildKitDir.
+// BuildKit reads the credentials from the client's docker config.json.
+// See https://github.com/moby/buildkit/blob/master/docs/buildkitd.toml.md
+func (r Registries) BuildKitdTOML() []byte {
+	var buf bytes.Buffer
+	for _, reg := range r {
+		if len(reg.CA) > 0 {
+			fmt.Fprintf(&buf, "[registry.%s]\n  ca = [%s]\n\n", strconv.Quote(reg.Host), strconv.Quote(path.Join(BuildKitDir, CAFileName(reg.Host))))
+		}
+	}
+	return buf.Bytes()
+}
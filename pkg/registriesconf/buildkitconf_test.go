@@ -0,0 +1,9 @@
+package registriesconf
+
+import (
+	"testing"
+)
+
+func TestBuildKitConfig(t *testing.T) {
+	requireGolden(t, "buildkitd.toml", testRegistries().BuildKitdTOML())
+}
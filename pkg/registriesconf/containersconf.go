@@ -0,0 +1,39 @@
+package registriesconf
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+)
+
+// ContainersAuthJSON returns the containers auth.json (used by buildah and podman) containing the registries' credentials.
+// The format is compatible with docker's config.json.
+// See https://github.com/containers/image/blob/master/docs/containers-auth.json.5.md
+func (r Registries) ContainersAuthJSON() []byte {
+	return r.DockerConfig().JSON()
+}
+
+// ContainersRegistriesConf returns a containers registries.conf (v2) that lists the registries.
+// See https://github.com/containers/image/blob/master/docs/containers-registries.conf.5.md
+func (r Registries) ContainersRegistriesConf() []byte {
+	var buf bytes.Buffer
+	for _, reg := range r {
+		fmt.Fprintf(&buf, "[[registry]]\nlocation = %s\ninsecure = false\n\n", strconv.Quote(reg.Host))
+	}
+	return buf.Bytes()
+}
+
+// ContainersCertsDir maps the paths within a containers certs.d directory
+// (e.g. /etc/containers/certs.d) to the corresponding CA certificate file names.
+// The mapping can be used to project the Secret's keys into the directory layout.
+// See https://github.com/containers/image/blob/master/docs/containers-certs.d.5.md
+func (r Registries) ContainersCertsDir() map[string]string {
+	m := map[string]string{}
+	for _, reg := range r {
+		if len(reg.CA) > 0 {
+			m[path.Join(reg.Host, "ca.crt")] = CAFileName(reg.Host)
+		}
+	}
+	return m
+}
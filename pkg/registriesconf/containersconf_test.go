@@ -0,0 +1,28 @@
+package registriesconf
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainersConfig(t *testing.T) {
+	registries := testRegistries()
+	requireGolden(t, "auth.json", registries.ContainersAuthJSON())
+	requireGolden(t, "registries.conf", registries.ContainersRegistriesConf())
+	certsDir, err := json.MarshalIndent(registries.ContainersCertsDir(), "", "  ")
+	require.NoError(t, err)
+	requireGolden(t, "certs.d.json", certsDir)
+	require.Equal(t, []string{"ca_registry.example.org.crt", "ca_registry.infra.svc_5000.crt"}, keys(registries.CAFiles()), "CA files")
+}
+
+func keys(m map[string][]byte) []string {
+	l := make([]string, 0, len(m))
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
@@ -0,0 +1,32 @@
+package registriesconf
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files within testdata")
+
+func testRegistries() Registries {
+	return Registries{
+		{Host: "index.docker.io", AuthKey: "https://index.docker.io/v1/", Auth: "dXNlcjpwYXNzd29yZA=="},
+		{Host: "registry.example.org", Auth: "dXNlcjI6cGFzc3dvcmQy", CA: []byte("-----BEGIN CERTIFICATE-----\nZXhhbXBsZQ==\n-----END CERTIFICATE-----\n")},
+		{Host: "registry.infra.svc:5000", Auth: "dXNlcjM6cGFzc3dvcmQz", CA: []byte("-----BEGIN CERTIFICATE-----\naW5mcmE=\n-----END CERTIFICATE-----\n")},
+	}
+}
+
+// requireGolden compares the output with the golden file testdata/<name> (updated when run with -update)
+func requireGolden(t *testing.T, name string, actual []byte) {
+	file := filepath.Join("testdata", name)
+	if *updateGolden {
+		err := ioutil.WriteFile(file, actual, 0644)
+		require.NoError(t, err, "update golden file")
+	}
+	expected, err := ioutil.ReadFile(file)
+	require.NoError(t, err, "read golden file")
+	require.Equal(t, string(expected), string(actual), "output does not match %s", file)
+}
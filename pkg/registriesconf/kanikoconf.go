@@ -0,0 +1,24 @@
+package registriesconf
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+)
+
+// KanikoDir is the directory kaniko reads its docker config.json from.
+// The Secret containing the kaniko configuration is expected to be mounted there.
+const KanikoDir = "/kaniko/.docker"
+
+// KanikoArgs returns kaniko's --registry-certificate arguments (one per line)
+// mapping each registry to its CA certificate file within the KanikoDir.
+// The credentials are provided by the config.json within the same directory.
+func (r Registries) KanikoArgs() []byte {
+	var buf bytes.Buffer
+	for _, reg := range r {
+		if len(reg.CA) > 0 {
+			fmt.Fprintf(&buf, "--registry-certificate=%s=%s\n", reg.Host, path.Join(KanikoDir, CAFileName(reg.Host)))
+		}
+	}
+	return buf.Bytes()
+}
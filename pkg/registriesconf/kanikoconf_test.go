@@ -0,0 +1,11 @@
+package registriesconf
+
+import (
+	"testing"
+)
+
+func TestKanikoConfig(t *testing.T) {
+	registries := testRegistries()
+	requireGolden(t, "kaniko-config.json", registries.DockerConfig().JSON())
+	requireGolden(t, "kaniko.args", registries.KanikoArgs())
+}
@@ -22,7 +22,11 @@ type MakisuSecurity struct {
 }
 
 type MakisuTLS struct {
-	CA MakisuCert `yaml:"cert"`
+	CA MakisuCA `yaml:"ca"`
+}
+
+type MakisuCA struct {
+	Cert MakisuCert `yaml:"cert"`
 }
 
 type MakisuCert struct {
@@ -48,13 +52,28 @@ func ToMakisuBasicAuth(auth string) (u MakisuBasicAuth, err error) {
 	return
 }
 
+const (
+	// MakisuConfigDir is the directory the Secret containing the makisu configuration is expected to be mounted at
+	MakisuConfigDir = "/makisu-internal/config"
+	// MakisuDefaultCACerts is makisu's default CA bundle
+	MakisuDefaultCACerts = "/makisu-internal/certs/cacerts.pem"
+)
+
 func (r MakisuRegistries) AddRegistry(registry, image string, auth MakisuBasicAuth) {
+	r.AddRegistryWithCA(registry, image, auth, MakisuDefaultCACerts)
+}
+
+// AddRegistryWithCA adds a registry entry that verifies the registry's TLS certificate using the given CA file
+func (r MakisuRegistries) AddRegistryWithCA(registry, image string, auth MakisuBasicAuth, caFile string) {
 	e := r[registry]
 	if e == nil {
 		e = map[string]MakisuRepo{}
 		r[registry] = e
 	}
-	e[image] = MakisuRepo{Security: MakisuSecurity{BasicAuth: auth}}
+	e[image] = MakisuRepo{Security: MakisuSecurity{
+		TLS:       &MakisuTLS{CA: MakisuCA{Cert: MakisuCert{Path: caFile}}},
+		BasicAuth: auth,
+	}}
 }
 
 func (r MakisuRegistries) YAML() []byte {
@@ -0,0 +1,69 @@
+package registriesconf
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var invalidKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// Registry specifies the credentials and CA certificate a builder uses to access a registry
+type Registry struct {
+	// Host is the registry's hostname (optionally with port)
+	Host string
+	// AuthKey is the key of the registry's credentials within a docker config (defaults to Host)
+	AuthKey string
+	// Auth contains the base64 encoded basic auth credentials
+	Auth string
+	// CA contains the PEM encoded CA certificate the registry's TLS certificate is signed with (optional)
+	CA []byte
+}
+
+// Registries is a list of registries sorted by host
+type Registries []Registry
+
+// RegistryHost returns the hostname (optionally with port) of a docker config auths key
+// which may also be specified as URL (e.g. https://index.docker.io/v1/)
+func RegistryHost(key string) string {
+	if i := strings.Index(key, "://"); i >= 0 {
+		key = key[i+3:]
+	}
+	return strings.SplitN(key, "/", 2)[0]
+}
+
+// CAFileName returns the name of a registry's CA certificate file (and Secret key)
+func CAFileName(host string) string {
+	return "ca_" + invalidKeyChars.ReplaceAllString(host, "_") + ".crt"
+}
+
+// CAFiles maps the CA certificate file names to their contents
+func (r Registries) CAFiles() map[string][]byte {
+	m := map[string][]byte{}
+	for _, reg := range r {
+		if len(reg.CA) > 0 {
+			m[CAFileName(reg.Host)] = reg.CA
+		}
+	}
+	return m
+}
+
+// Sort sorts the registries by host
+func (r Registries) Sort() {
+	sort.Slice(r, func(i, j int) bool { return r[i].Host < r[j].Host })
+}
+
+// DockerConfig returns the docker config containing the registries' credentials
+func (r Registries) DockerConfig() *DockerConfig {
+	c := &DockerConfig{Auths: map[string]DockerConfigUrlAuth{}}
+	for _, reg := range r {
+		if reg.Auth != "" {
+			key := reg.AuthKey
+			if key == "" {
+				key = reg.Host
+			}
+			c.Auths[key] = DockerConfigUrlAuth{Auth: reg.Auth}
+		}
+	}
+	return c
+}
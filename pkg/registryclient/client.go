@@ -0,0 +1,436 @@
+// Package registryclient provides a minimal docker registry v2 API client
+// supporting docker token authentication.
+package registryclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MediaTypeManifestV2   = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageConfig  = "application/vnd.docker.container.image.v1+json"
+	MediaTypeOCIConfig    = "application/vnd.oci.image.config.v1+json"
+	headerContentDigest   = "Docker-Content-Digest"
+	headerWWWAuthenticate = "WWW-Authenticate"
+	scopeCatalog          = "registry:catalog:*"
+	catalogPageSize       = 100
+)
+
+var manifestMediaTypes = []string{MediaTypeManifestV2, MediaTypeManifestList, MediaTypeOCIManifest, MediaTypeOCIIndex}
+
+// Credentials to authenticate with a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Client accesses a single registry's v2 API
+type Client struct {
+	baseURL     *url.URL
+	credentials Credentials
+	http        *http.Client
+	tokens      map[string]string
+	lock        sync.Mutex
+}
+
+// New creates a registry client for the given host.
+// The optional caCert PEM is trusted in addition to the system's CAs.
+func New(host string, credentials Credentials, caCert []byte) (*Client, error) {
+	u, err := url.Parse("https://" + host)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf := &tls.Config{}
+	if len(caCert) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("registry %s: invalid CA certificate", host)
+		}
+		tlsConf.RootCAs = pool
+	}
+	httpClient := &http.Client{
+		Timeout:   15 * time.Minute,
+		Transport: &http.Transport{TLSClientConfig: tlsConf, Proxy: http.ProxyFromEnvironment},
+	}
+	return NewWithHTTPClient(u, credentials, httpClient), nil
+}
+
+// NewWithHTTPClient creates a registry client using the provided base URL and HTTP client
+func NewWithHTTPClient(baseURL *url.URL, credentials Credentials, httpClient *http.Client) *Client {
+	return &Client{
+		baseURL:     baseURL,
+		credentials: credentials,
+		http:        httpClient,
+		tokens:      map[string]string{},
+	}
+}
+
+// Host returns the registry's host name
+func (c *Client) Host() string {
+	return c.baseURL.Host
+}
+
+// Catalog lists all repositories
+func (c *Client) Catalog() (repos []string, err error) {
+	last := ""
+	for {
+		query := url.Values{"n": []string{fmt.Sprintf("%d", catalogPageSize)}}
+		if last != "" {
+			query.Set("last", last)
+		}
+		page := struct {
+			Repositories []string `json:"repositories"`
+		}{}
+		if err = c.getJSON("/v2/_catalog?"+query.Encode(), scopeCatalog, &page); err != nil {
+			return nil, fmt.Errorf("list catalog: %w", err)
+		}
+		repos = append(repos, page.Repositories...)
+		if len(page.Repositories) < catalogPageSize {
+			return
+		}
+		last = page.Repositories[len(page.Repositories)-1]
+	}
+}
+
+// Tags lists all tags of a repository
+func (c *Client) Tags(repo string) ([]string, error) {
+	tags := struct {
+		Tags []string `json:"tags"`
+	}{}
+	err := c.getJSON("/v2/"+repo+"/tags/list", pullScope(repo), &tags)
+	if err != nil {
+		return nil, fmt.Errorf("list tags of %s: %w", repo, err)
+	}
+	return tags.Tags, nil
+}
+
+// Manifest fetches a manifest by tag or digest
+func (c *Client) Manifest(repo, ref string) (m *Manifest, err error) {
+	req, err := c.newRequest(http.MethodGet, "/v2/"+repo+"/manifests/"+ref, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	resp, err := c.do(req, pullScope(repo), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get manifest %s:%s: %w", repo, ref, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	return parseManifest(b, resp.Header.Get("Content-Type"), resp.Header.Get(headerContentDigest))
+}
+
+// ManifestDigest returns the digest of a manifest or an empty string if it does not exist
+func (c *Client) ManifestDigest(repo, ref string) (digest string, err error) {
+	req, err := c.newRequest(http.MethodHead, "/v2/"+repo+"/manifests/"+ref, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	resp, err := c.do(req, pullScope(repo), nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get(headerContentDigest), nil
+}
+
+// PutManifest uploads a manifest to the given tag or digest
+func (c *Client) PutManifest(repo, ref string, m *Manifest) error {
+	body := func() io.Reader { return strings.NewReader(string(m.Raw)) }
+	req, err := c.newRequest(http.MethodPut, "/v2/"+repo+"/manifests/"+ref, body())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", m.MediaType)
+	req.ContentLength = int64(len(m.Raw))
+	resp, err := c.do(req, pushScope(repo), body)
+	if err != nil {
+		return fmt.Errorf("put manifest %s:%s: %w", repo, ref, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeleteManifest deletes a manifest by digest
+func (c *Client) DeleteManifest(repo, digest string) error {
+	req, err := c.newRequest(http.MethodDelete, "/v2/"+repo+"/manifests/"+digest, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, deleteScope(repo), nil)
+	if err != nil {
+		return fmt.Errorf("delete manifest %s@%s: %w", repo, digest, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// BlobExists returns true if the given blob exists within the repository
+func (c *Client) BlobExists(repo, digest string) (bool, error) {
+	req, err := c.newRequest(http.MethodHead, "/v2/"+repo+"/blobs/"+digest, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req, pullScope(repo), nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Blob returns a reader for the given blob. The caller must close it.
+func (c *Client) Blob(repo, digest string) (io.ReadCloser, error) {
+	req, err := c.newRequest(http.MethodGet, "/v2/"+repo+"/blobs/"+digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req, pullScope(repo), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get blob %s@%s: %w", repo, digest, err)
+	}
+	return resp.Body, nil
+}
+
+// PutBlob uploads a blob monolithically.
+// Since the streamed upload cannot be retried with a renewed token
+// a fresh token is requested per blob to make it last for the whole upload.
+func (c *Client) PutBlob(repo, digest string, size int64, blob io.Reader) error {
+	req, err := c.newRequest(http.MethodPost, "/v2/"+repo+"/blobs/uploads/", nil)
+	if err != nil {
+		return err
+	}
+	c.dropToken(pushScope(repo))
+	resp, err := c.do(req, pushScope(repo), nil)
+	if err != nil {
+		return fmt.Errorf("start blob upload %s@%s: %w", repo, digest, err)
+	}
+	resp.Body.Close()
+	location, err := c.baseURL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("blob upload %s@%s: invalid location: %w", repo, digest, err)
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+	req, err = http.NewRequest(http.MethodPut, location.String(), blob)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.ContentLength = size
+	// The upload cannot be retried since the blob is streamed
+	resp, err = c.do(req, pushScope(repo), nil)
+	if err != nil {
+		return fmt.Errorf("put blob %s@%s: %w", repo, digest, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) getJSON(path, scope string, v interface{}) error {
+	req, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, scope, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	u, err := c.baseURL.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, u.String(), body)
+}
+
+// do sends the request and authenticates when challenged.
+// A request with body is only retried after authentication when getBody is provided.
+func (c *Client) do(req *http.Request, scope string, getBody func() io.Reader) (resp *http.Response, err error) {
+	if token := c.token(scope); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if resp, err = c.http.Do(req); err != nil {
+		return
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get(headerWWWAuthenticate)
+		resp.Body.Close()
+		if err = c.authenticate(challenge, scope); err != nil {
+			return nil, err
+		}
+		retry := req.Clone(req.Context())
+		if req.Body != nil && req.Body != http.NoBody {
+			if getBody == nil {
+				// streamed body cannot be sent twice
+				return nil, &HTTPError{req.Method, req.URL.Path, http.StatusUnauthorized}
+			}
+			retry.Body = ioutil.NopCloser(getBody())
+		}
+		retry.Header.Set("Authorization", "Bearer "+c.token(scope))
+		if resp, err = c.http.Do(retry); err != nil {
+			return
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &HTTPError{req.Method, req.URL.Path, resp.StatusCode}
+	}
+	return
+}
+
+func (c *Client) token(scope string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tokens[scope]
+}
+
+func (c *Client) dropToken(scope string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.tokens, scope)
+}
+
+func (c *Client) authenticate(challenge, scope string) error {
+	scheme, params := ParseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") || params["realm"] == "" {
+		return fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	realm, err := c.baseURL.Parse(params["realm"])
+	if err != nil {
+		return fmt.Errorf("invalid auth realm: %w", err)
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.credentials.Username != "" {
+		req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request token for scope %s: %w", scope, &HTTPError{req.Method, realm.Path, resp.StatusCode})
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("decode token response: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	c.lock.Lock()
+	c.tokens[scope] = token.Token
+	c.lock.Unlock()
+	return nil
+}
+
+// ParseChallenge parses a WWW-Authenticate header value
+func ParseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = map[string]string{}
+	s := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	scheme = s[0]
+	if len(s) < 2 {
+		return
+	}
+	rest := s[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			return
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = rest[eq+1:]
+		value := ""
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value = rest[:end]
+			rest = rest[end:]
+		} else {
+			value = rest
+			rest = ""
+		}
+		params[strings.ToLower(key)] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return
+}
+
+func pullScope(repo string) string {
+	return "repository:" + repo + ":pull"
+}
+
+func pushScope(repo string) string {
+	return "repository:" + repo + ":pull,push"
+}
+
+func deleteScope(repo string) string {
+	return "repository:" + repo + ":delete"
+}
+
+// HTTPError is returned for unexpected HTTP response status codes
+type HTTPError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// IsNotFound returns true if the error is an HTTP 404 error
+func IsNotFound(err error) bool {
+	var e *HTTPError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
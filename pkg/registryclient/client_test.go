@@ -0,0 +1,107 @@
+package registryclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChallenge(t *testing.T) {
+	for _, c := range []struct {
+		input          string
+		expectedScheme string
+		expectedParams map[string]string
+	}{
+		{`Bearer realm="https://auth.example.org/token",service="registry",scope="repository:a/b:pull,push"`, "Bearer",
+			map[string]string{"realm": "https://auth.example.org/token", "service": "registry", "scope": "repository:a/b:pull,push"}},
+		{`Basic realm=registry`, "Basic", map[string]string{"realm": "registry"}},
+		{`Bearer`, "Bearer", map[string]string{}},
+	} {
+		scheme, params := ParseChallenge(c.input)
+		require.Equal(t, c.expectedScheme, scheme, "scheme of %q", c.input)
+		require.Equal(t, c.expectedParams, params, "params of %q", c.input)
+	}
+}
+
+func TestClientTokenAuth(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/token":
+			usr, pw, ok := req.BasicAuth()
+			if !ok || usr != "myuser" || pw != "mypasswd" || req.URL.Query().Get("scope") != scopeCatalog {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "mytoken"})
+		case "/v2/_catalog":
+			if req.Header.Get("Authorization") != "Bearer mytoken" {
+				w.Header().Set(headerWWWAuthenticate, `Bearer realm="`+srv.URL+`/token",service="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string][]string{"repositories": {"a", "b/c"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	testee := NewWithHTTPClient(u, Credentials{"myuser", "mypasswd"}, srv.Client())
+
+	repos, err := testee.Catalog()
+	require.NoError(t, err, "Catalog()")
+	require.Equal(t, []string{"a", "b/c"}, repos, "repositories")
+
+	exists, err := testee.BlobExists("a", "sha256:unknown")
+	require.NoError(t, err, "BlobExists()")
+	require.False(t, exists, "BlobExists()")
+}
+
+func TestClientPutBlobRequestsTokenPerBlob(t *testing.T) {
+	var (
+		srv       *httptest.Server
+		issued    int
+		putTokens []string
+	)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			issued++
+			json.NewEncoder(w).Encode(map[string]string{"token": fmt.Sprintf("token-%d", issued)})
+			return
+		}
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			w.Header().Set(headerWWWAuthenticate, `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/v2/a/blobs/uploads/":
+			w.Header().Set("Location", "/v2/a/blobs/uploads/upload-id")
+			w.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPut && req.URL.Path == "/v2/a/blobs/uploads/upload-id":
+			putTokens = append(putTokens, token)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	testee := NewWithHTTPClient(u, Credentials{"myuser", "mypasswd"}, srv.Client())
+
+	for _, blob := range []string{"blob-a", "blob-b"} {
+		err = testee.PutBlob("a", "sha256:"+blob, int64(len(blob)), strings.NewReader(blob))
+		require.NoError(t, err, "PutBlob(%s)", blob)
+	}
+	require.Equal(t, []string{"token-1", "token-2"}, putTokens, "tokens used for the uploads")
+}
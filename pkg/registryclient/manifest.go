@@ -0,0 +1,54 @@
+package registryclient
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Manifest is an image manifest or manifest list
+type Manifest struct {
+	MediaType string
+	Digest    string
+	Raw       []byte
+	// Blobs lists the config and layer blobs referenced by an image manifest
+	Blobs []Descriptor
+	// Manifests lists the manifests referenced by a manifest list
+	Manifests []Descriptor
+}
+
+// Descriptor refers to a blob or manifest
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// IsList returns true if the manifest is a manifest list or image index
+func (m *Manifest) IsList() bool {
+	return m.MediaType == MediaTypeManifestList || m.MediaType == MediaTypeOCIIndex
+}
+
+func parseManifest(raw []byte, mediaType, digest string) (*Manifest, error) {
+	parsed := struct {
+		MediaType string       `json:"mediaType"`
+		Config    *Descriptor  `json:"config"`
+		Layers    []Descriptor `json:"layers"`
+		Manifests []Descriptor `json:"manifests"`
+	}{}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if mediaType == "" {
+		mediaType = parsed.MediaType
+	}
+	if digest == "" {
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
+	}
+	m := &Manifest{MediaType: mediaType, Digest: digest, Raw: raw, Manifests: parsed.Manifests}
+	if parsed.Config != nil {
+		m.Blobs = append(m.Blobs, *parsed.Config)
+	}
+	m.Blobs = append(m.Blobs, parsed.Layers...)
+	return m, nil
+}